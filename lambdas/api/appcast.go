@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+/**
+the title to show for a product in feeds: its display name if it has been registered with one, or else its name
+*/
+func (s *Service) productTitle(productName string) (string, error) {
+	if s.Products == nil {
+		return productName, nil
+	}
+	product, getErr := s.Products.GetProduct(productName)
+	if getErr != nil {
+		return "", getErr
+	}
+	if product == nil || product.DisplayName == "" {
+		return productName, nil
+	}
+	return product.DisplayName, nil
+}
+
+/**
+the newest releases on a branch that can be offered to a macOS client, narrowed down to the download for its arch
+and turned into appcast items. Withdrawn releases are skipped.
+*/
+func (s *Service) appcastItems(productName string, branch string, arch string, clientId string, lang string) ([]common.AppcastItem, error) {
+	items := make([]common.AppcastItem, 0, common.MaxAppcastItems)
+	var itemErr error
+	err := common.EachRelease(s.Store, common.ReleaseQuery{ProductName: productName, Branch: branch}, func(ev *common.NewReleaseEvent) bool {
+		if ev.Withdrawn || !ev.OfferedTo(clientId) {
+			return true
+		}
+		release := ev.ForPlatform("macos", arch)
+		if release == nil {
+			return true
+		}
+
+		var item *common.AppcastItem
+		item, itemErr = common.NewAppcastItem(release, lang)
+		if itemErr != nil {
+			return false
+		}
+		items = append(items, *item)
+		return len(items) < common.MaxAppcastItems
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, itemErr
+}
+
+/**
+handler for GET /appcast, which lists the newest releases on a branch as a Sparkle appcast for macOS clients
+*/
+func (s *Service) Appcast(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	params := request.QueryStringParameters
+	productName := params["productName"]
+	branch := params["branch"]
+	if productName == "" || branch == "" {
+		return events.APIGatewayProxyResponse{Body: "productName and branch must be specified", StatusCode: 400}, nil
+	}
+	lang := params["lang"]
+	if lang == "" {
+		lang = preferredLanguage(request)
+	}
+
+	title, titleErr := s.productTitle(productName)
+	if titleErr != nil {
+		log.Printf("Could not get product from database: %s", titleErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	items, getErr := s.appcastItems(productName, branch, params["arch"], params["clientId"], lang)
+	if getErr != nil {
+		log.Printf("Could not get data from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	output, renderErr := common.RenderAppcast(title, items)
+	if renderErr != nil {
+		log.Printf("Could not render appcast: %s", renderErr)
+		return events.APIGatewayProxyResponse{Body: "Could not render appcast", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{
+		Body:       string(output),
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "application/rss+xml; charset=utf-8"},
+	}, nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_Appcast(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{ProductName: "test product", DisplayName: "Test Product", DefaultBranch: "master"})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 10, Branch: "master", ProductName: "test product", Semver: "1.0.0", Artifacts: []common.Artifact{
+		{Os: "macos", Url: "https://some/url/10.zip", Size: 1234},
+		{Os: "windows", Url: "https://some/url/10.exe"},
+	}})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 11, Branch: "master", ProductName: "test product", Artifacts: []common.Artifact{
+		{Os: "windows", Url: "https://some/url/11.exe"},
+	}})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 12, Branch: "master", ProductName: "test product", DownloadUrl: "https://some/url/12.zip", Withdrawn: true})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 13, Branch: "develop", ProductName: "test product", DownloadUrl: "https://some/url/13.zip"})
+	service := NewService(store)
+
+	response, _ := service.Appcast(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if response.StatusCode != 200 || response.Headers["Content-Type"] != "application/rss+xml; charset=utf-8" {
+		t.Fatalf("appcast should have returned 200 with an RSS content type but got %d %v", response.StatusCode, response.Headers)
+	}
+	if !strings.Contains(response.Body, "<title>Test Product</title>") || !strings.Contains(response.Body, `url="https://some/url/10.zip" length="1234"`) {
+		t.Errorf("appcast should have listed the macOS download of build 10 but got %s", response.Body)
+	}
+	if strings.Count(response.Body, "<item>") != 1 {
+		t.Errorf("appcast should have skipped releases without a macOS download, withdrawn releases and other branches but got %s", response.Body)
+	}
+
+	missing, _ := service.Appcast(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product"},
+	})
+	if missing.StatusCode != 400 {
+		t.Errorf("appcast without a branch should have returned 400 but got %d", missing.StatusCode)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+/**
+handler for POST /branchsettings, which saves the settings for a branch
+*/
+func (s *Service) UpdateBranchSettings(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Settings == nil {
+		return events.APIGatewayProxyResponse{Body: "Branch settings are not supported by this storage backend", StatusCode: 501}, nil
+	}
+
+	var settings common.BranchSettings
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &settings)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not understand request body", StatusCode: 400}, nil
+	}
+
+	validationErr := settings.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	putErr := s.Settings.PutBranchSettings(&settings)
+	if putErr != nil {
+		log.Printf("Could not write branch settings to database: %s", putErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(settings)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
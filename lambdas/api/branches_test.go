@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"testing"
+)
+
+func TestService_UpdateBranchSettings(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+
+	response, _ := service.UpdateBranchSettings(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","branch":"master","minimumBuildId":12}`,
+	})
+	if response.StatusCode != 200 {
+		t.Fatalf("update should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+	}
+	saved, _ := store.GetBranchSettings("test product", "master")
+	if saved == nil || saved.MinimumBuildId != 12 {
+		t.Errorf("update should have saved the settings but got %v", saved)
+	}
+
+	invalid, _ := service.UpdateBranchSettings(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","minimumBuildId":12}`,
+	})
+	if invalid.StatusCode != 400 {
+		t.Errorf("update without a branch should have returned 400 but got %d", invalid.StatusCode)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"net/http"
+)
+
+/**
+handler for /channels in Lambda, where one function serves every method of the resource
+*/
+func (s *Service) ManageChannels(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		return s.ListChannels(ctx, request)
+	case http.MethodPost:
+		return s.PutChannel(ctx, request)
+	case http.MethodDelete:
+		return s.DeleteChannel(ctx, request)
+	default:
+		return events.APIGatewayProxyResponse{Body: "Method not allowed", StatusCode: 405}, nil
+	}
+}
+
+/**
+handler for GET /channels?productName=..., which lists the channels of a product
+*/
+func (s *Service) ListChannels(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Channels == nil {
+		return events.APIGatewayProxyResponse{Body: errNoChannels.Error(), StatusCode: 501}, nil
+	}
+
+	productName := request.QueryStringParameters["productName"]
+	if productName == "" {
+		return events.APIGatewayProxyResponse{Body: "productName must be specified", StatusCode: 400}, nil
+	}
+
+	channels, listErr := s.Channels.ListChannels(productName)
+	if listErr != nil {
+		log.Printf("Could not get channels from database: %s", listErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(channels)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
+
+/**
+handler for POST /channels, which creates or replaces a channel
+*/
+func (s *Service) PutChannel(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Channels == nil {
+		return events.APIGatewayProxyResponse{Body: errNoChannels.Error(), StatusCode: 501}, nil
+	}
+
+	var channel common.Channel
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &channel)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not understand request body", StatusCode: 400}, nil
+	}
+
+	validationErr := channel.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	var fallbackChain []common.Channel
+	if channel.Fallback != "" {
+		var chainErr error
+		fallbackChain, chainErr = common.ChannelChain(s.Channels, channel.ProductName, channel.Fallback)
+		if chainErr != nil {
+			log.Printf("Could not get channel from database: %s", chainErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+	}
+	fallbackErr := common.CheckFallback(&channel, fallbackChain)
+	if fallbackErr != nil {
+		return events.APIGatewayProxyResponse{Body: fallbackErr.Error(), StatusCode: 400}, nil
+	}
+
+	putErr := s.Channels.PutChannel(&channel)
+	if putErr != nil {
+		log.Printf("Could not write channel to database: %s", putErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(channel)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
+
+/**
+handler for DELETE /channels?productName=...&name=..., which removes a channel. Channels that fall back to it
+stop falling back at that point.
+*/
+func (s *Service) DeleteChannel(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Channels == nil {
+		return events.APIGatewayProxyResponse{Body: errNoChannels.Error(), StatusCode: 501}, nil
+	}
+
+	productName := request.QueryStringParameters["productName"]
+	name := request.QueryStringParameters["name"]
+	if productName == "" || name == "" {
+		return events.APIGatewayProxyResponse{Body: "productName and name must be specified", StatusCode: 400}, nil
+	}
+
+	deleteErr := s.Channels.DeleteChannel(productName, name)
+	if deleteErr != nil {
+		log.Printf("Could not delete channel from database: %s", deleteErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{StatusCode: 204}, nil
+}
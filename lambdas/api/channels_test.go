@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_ManageChannels(t *testing.T) {
+	service := NewService(common.NewMemoryStore())
+
+	stable, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"productName":"test product","name":"stable","branches":["master"]}`,
+	})
+	if stable.StatusCode != 200 {
+		t.Fatalf("put of stable should have returned 200 but got %d: %s", stable.StatusCode, stable.Body)
+	}
+
+	beta, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"productName":"test product","name":"beta","branches":["develop"],"fallback":"stable"}`,
+	})
+	if beta.StatusCode != 200 {
+		t.Fatalf("put of beta should have returned 200 but got %d: %s", beta.StatusCode, beta.Body)
+	}
+
+	missingFallback, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"productName":"test product","name":"nightly","branches":["develop"],"fallback":"alpha"}`,
+	})
+	if missingFallback.StatusCode != 400 {
+		t.Errorf("put with a missing fallback should have returned 400 but got %d", missingFallback.StatusCode)
+	}
+
+	loop, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"productName":"test product","name":"stable","branches":["master"],"fallback":"beta"}`,
+	})
+	if loop.StatusCode != 400 {
+		t.Errorf("put that makes a fallback loop should have returned 400 but got %d", loop.StatusCode)
+	}
+
+	list, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"productName": "test product"},
+	})
+	if list.StatusCode != 200 || !strings.Contains(list.Body, `"name":"beta"`) || !strings.Contains(list.Body, `"name":"stable"`) {
+		t.Errorf("list should have returned both channels but got %d: %s", list.StatusCode, list.Body)
+	}
+
+	deleted, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"productName": "test product", "name": "beta"},
+	})
+	if deleted.StatusCode != 204 {
+		t.Errorf("delete should have returned 204 but got %d", deleted.StatusCode)
+	}
+
+	afterDelete, _ := service.ListChannels(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product"},
+	})
+	if strings.Contains(afterDelete.Body, `"name":"beta"`) {
+		t.Errorf("list after delete should not have returned beta but got %s", afterDelete.Body)
+	}
+
+	unsupported, _ := service.ManageChannels(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "PATCH"})
+	if unsupported.StatusCode != 405 {
+		t.Errorf("unsupported method should have returned 405 but got %d", unsupported.StatusCode)
+	}
+}
+
+func TestService_LookupVersionByChannel(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.PutChannel(&common.Channel{ProductName: "test product", Name: "stable", Branches: []string{"master"}})
+	store.PutChannel(&common.Channel{ProductName: "test product", Name: "beta", Branches: []string{"develop"}, Fallback: "stable"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "develop", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 12, Branch: "master", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+
+	beta, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "channel": "beta"},
+	})
+	if beta.StatusCode != 200 || !strings.Contains(beta.Body, `"buildId":12`) {
+		t.Errorf("beta lookup should have returned the newer build from the stable fallback but got %d: %s", beta.StatusCode, beta.Body)
+	}
+
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 13, Branch: "develop", DownloadUrl: "https://some/url/13", ProductName: "test product"})
+	newerBeta, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "channel": "beta"},
+	})
+	if !strings.Contains(newerBeta.Body, `"buildId":13`) {
+		t.Errorf("beta lookup should have returned the newer develop build but got %s", newerBeta.Body)
+	}
+
+	stable, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "channel": "stable"},
+	})
+	if !strings.Contains(stable.Body, `"buildId":12`) {
+		t.Errorf("stable lookup should not have seen develop builds but got %s", stable.Body)
+	}
+
+	missing, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "channel": "nightly"},
+	})
+	if missing.StatusCode != 404 {
+		t.Errorf("lookup of an unknown channel should have returned 404 but got %d", missing.StatusCode)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"strconv"
+)
+
+var errNoDownloads = errors.New("Download statistics are not supported by this storage backend")
+
+//browsers can reuse a redirect for a few minutes, but shared caches must not, or the downloads they answer would not
+//be counted
+const downloadCacheControl = "private, max-age=300"
+
+//errors from /download must not be cached, so that the button works as soon as a release is logged
+const downloadErrorCacheControl = "no-store"
+
+func downloadError(message string, statusCode int) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		Body:       message,
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Cache-Control": downloadErrorCacheControl},
+	}
+}
+
+/**
+add one to the download count of a release. Statistics aren't worth failing a download over, so problems are
+only logged.
+*/
+func (s *Service) countDownload(release *common.NewReleaseEvent, platform string) {
+	if s.Downloads == nil {
+		return
+	}
+	if countErr := s.Downloads.CountDownload(release.ProductName, release.BuildId, platform); countErr != nil {
+		log.Printf("Could not count download of %s build %d: %s", release.ProductName, release.BuildId, countErr)
+	}
+}
+
+/**
+handler for GET /download/{productName}/{branch+}, which redirects to the download of the newest release on the branch
+so that web pages don't need to know build URLs. Without an os query parameter it redirects to the release's
+DownloadUrl, or to its artifact that works on any platform; with one it redirects to the artifact for that platform,
+as /lookup would pick it. Every redirect is counted for download statistics, see DownloadStats.
+*/
+func (s *Service) Download(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	productName := request.PathParameters["productName"]
+	branch := request.PathParameters["branch"]
+	os := request.QueryStringParameters["os"]
+	arch := request.QueryStringParameters["arch"]
+	if productName == "" || branch == "" {
+		return downloadError("productName and branch must be specified", 400), nil
+	}
+	if arch != "" && os == "" {
+		return downloadError("os must be specified along with arch", 400), nil
+	}
+
+	release, getErr := common.FindRelease(s.Store, productName, branch, common.OrderByBuildId, func(ev *common.NewReleaseEvent) bool {
+		if !ev.OfferedTo(request.QueryStringParameters["clientId"]) {
+			return false
+		}
+		return os == "" || ev.FindArtifact(os, arch) != nil
+	})
+	if getErr != nil {
+		log.Printf("Could not get data from database: %s", getErr)
+		return downloadError("Could not get info from database", 500), nil
+	}
+	if release == nil {
+		return downloadError("Nothing found for product and branch", 404), nil
+	}
+
+	//an older build must not be served in place of the newest one, so a release without a download for every platform
+	//is a 404 rather than skipped
+	artifact := release.FindArtifact(os, arch)
+	if os == "" && release.DownloadUrl != "" {
+		artifact = &common.Artifact{Url: release.DownloadUrl}
+	}
+	if artifact == nil {
+		return downloadError("The newest release on the branch has no download for every platform, os and arch must be specified", 404), nil
+	}
+
+	//downloads are counted against what was served rather than what was asked for, so callers can't make up platforms
+	s.countDownload(release, common.DownloadPlatform(artifact))
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 302,
+		Headers: map[string]string{
+			"Location":      artifact.Url,
+			"Cache-Control": downloadCacheControl,
+		},
+	}, nil
+}
+
+/**
+handler for GET /downloads?productName=...&buildId=..., which returns how many times a release has been downloaded
+through /download, in total and for each platform
+*/
+func (s *Service) DownloadStats(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Downloads == nil {
+		return events.APIGatewayProxyResponse{Body: errNoDownloads.Error(), StatusCode: 501}, nil
+	}
+
+	productName := request.QueryStringParameters["productName"]
+	buildId, parseErr := strconv.Atoi(request.QueryStringParameters["buildId"])
+	if productName == "" || parseErr != nil {
+		return events.APIGatewayProxyResponse{Body: "productName and a numeric buildId must be specified", StatusCode: 400}, nil
+	}
+
+	counts, getErr := s.Downloads.DownloadCounts(productName, buildId)
+	if getErr != nil {
+		log.Printf("Could not get download counts from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(common.NewDownloadStats(productName, buildId, counts))
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_Download(t *testing.T) {
+	rollout := 0
+	store := common.NewMemoryStore()
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 10, Branch: "master", ProductName: "test product", Artifacts: []common.Artifact{
+		{Os: "macos", Arch: "arm64", Url: "https://some/url/10-arm64.dmg"},
+	}})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 11, Branch: "master", ProductName: "test product", DownloadUrl: "https://some/url/11"})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 12, Branch: "master", ProductName: "test product", DownloadUrl: "https://some/url/12", Withdrawn: true})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 13, Branch: "master", ProductName: "test product", DownloadUrl: "https://some/url/13", RolloutPercentage: &rollout})
+	service := NewService(store)
+
+	newest, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if newest.StatusCode != 302 || newest.Headers["Location"] != "https://some/url/11" {
+		t.Errorf("download should have redirected to build 11 but got %d %v", newest.StatusCode, newest.Headers)
+	}
+	if newest.Headers["Cache-Control"] != "private, max-age=300" {
+		t.Errorf("download redirect should only be cacheable by the browser but got %s", newest.Headers["Cache-Control"])
+	}
+
+	platform, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters:        map[string]string{"productName": "test product", "branch": "master"},
+		QueryStringParameters: map[string]string{"os": "darwin", "arch": "aarch64"},
+	})
+	if platform.StatusCode != 302 || platform.Headers["Location"] != "https://some/url/11" {
+		t.Errorf("download for macOS should have redirected to the any-platform build 11 but got %d %v", platform.StatusCode, platform.Headers)
+	}
+
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 14, Branch: "master", ProductName: "test product", Artifacts: []common.Artifact{
+		{Os: "macos", Arch: "arm64", Url: "https://some/url/14-arm64.dmg"},
+	}})
+	artifact, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters:        map[string]string{"productName": "test product", "branch": "master"},
+		QueryStringParameters: map[string]string{"os": "macos", "arch": "arm64"},
+	})
+	if artifact.StatusCode != 302 || artifact.Headers["Location"] != "https://some/url/14-arm64.dmg" {
+		t.Errorf("download for macOS should have redirected to the build 14 artifact but got %d %v", artifact.StatusCode, artifact.Headers)
+	}
+
+	madeUp, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters:        map[string]string{"productName": "test product", "branch": "master"},
+		QueryStringParameters: map[string]string{"os": "templeos", "arch": "z80"},
+	})
+	if madeUp.StatusCode != 302 || madeUp.Headers["Location"] != "https://some/url/11" {
+		t.Errorf("download for an unknown platform should have redirected to the any-platform build 11 but got %d %v", madeUp.StatusCode, madeUp.Headers)
+	}
+
+	//build 11 only has a downloadUrl, so every download of it counts against "any" whatever the platform asked for
+	counts, _ := store.DownloadCounts("test product", 11)
+	if len(counts) != 1 || counts[0].Platform != common.AnyPlatform || counts[0].Downloads != 3 {
+		t.Errorf("downloads of build 11 should all have been counted against any but got %v", counts)
+	}
+	artifactCounts, _ := store.DownloadCounts("test product", 14)
+	if len(artifactCounts) != 1 || artifactCounts[0].Platform != "macos/arm64" {
+		t.Errorf("downloads of build 14 should have been counted against its artifact but got %v", artifactCounts)
+	}
+
+	noAnyPlatform, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if noAnyPlatform.StatusCode != 404 || noAnyPlatform.Headers["Cache-Control"] != "no-store" {
+		t.Errorf("download without a platform should have returned 404 for build 14 rather than an older build but got %d %v", noAnyPlatform.StatusCode, noAnyPlatform.Headers)
+	}
+
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 15, Branch: "master", ProductName: "test product", Artifacts: []common.Artifact{
+		{Os: "macos", Arch: "arm64", Url: "https://some/url/15-arm64.dmg"},
+		{Url: "https://some/url/15.jar"},
+	}})
+	anyPlatform, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if anyPlatform.StatusCode != 302 || anyPlatform.Headers["Location"] != "https://some/url/15.jar" {
+		t.Errorf("download without a platform should have redirected to the any-platform artifact of build 15 but got %d %v", anyPlatform.StatusCode, anyPlatform.Headers)
+	}
+
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 16, Branch: "release/2.14", ProductName: "test product", DownloadUrl: "https://some/url/16"})
+	slashed, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product", "branch": "release/2.14"},
+	})
+	if slashed.StatusCode != 302 || slashed.Headers["Location"] != "https://some/url/16" {
+		t.Errorf("download from release/2.14 should have redirected to build 16 but got %d %v", slashed.StatusCode, slashed.Headers)
+	}
+
+	missing, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product", "branch": "nobranch"},
+	})
+	if missing.StatusCode != 404 || missing.Headers["Cache-Control"] != "no-store" {
+		t.Errorf("download from an empty branch should have returned an uncacheable 404 but got %d %v", missing.StatusCode, missing.Headers)
+	}
+
+	invalid, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product"},
+	})
+	if invalid.StatusCode != 400 {
+		t.Errorf("download without a branch should have returned 400 but got %d", invalid.StatusCode)
+	}
+
+	noOs, _ := service.Download(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters:        map[string]string{"productName": "test product", "branch": "master"},
+		QueryStringParameters: map[string]string{"arch": "arm64"},
+	})
+	if noOs.StatusCode != 400 {
+		t.Errorf("download with an arch but no os should have returned 400 but got %d", noOs.StatusCode)
+	}
+}
+
+func TestService_DownloadStats(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.CountDownload("test product", 12, "windows")
+	store.CountDownload("test product", 12, "windows")
+	store.CountDownload("test product", 12, common.AnyPlatform)
+	service := NewService(store)
+
+	response, _ := service.DownloadStats(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "buildId": "12"},
+	})
+	if response.StatusCode != 200 {
+		t.Fatalf("stats should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+	}
+	var stats common.DownloadStats
+	json.Unmarshal([]byte(response.Body), &stats)
+	if stats.Downloads != 3 || len(stats.Platforms) != 2 || stats.Platforms[1].Platform != "windows" || stats.Platforms[1].Downloads != 2 {
+		t.Errorf("stats returned the wrong counts: %s", response.Body)
+	}
+
+	none, _ := service.DownloadStats(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "buildId": "13"},
+	})
+	if none.StatusCode != 200 || !strings.Contains(none.Body, `"downloads":0`) {
+		t.Errorf("stats for a build that hasn't been downloaded should have returned zero but got %d: %s", none.StatusCode, none.Body)
+	}
+
+	invalid, _ := service.DownloadStats(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "buildId": "latest"},
+	})
+	if invalid.StatusCode != 400 {
+		t.Errorf("stats without a numeric buildId should have returned 400 but got %d", invalid.StatusCode)
+	}
+}
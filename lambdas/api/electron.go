@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"path"
+)
+
+/**
+handler for GET /electron/{file}, which answers electron-updater's generic provider with latest.yml, latest-mac.yml
+or latest-linux.yml for the newest release on a branch. The product and branch come from the query string, which
+electron-updater keeps when it adds the file name to the update URL.
+*/
+func (s *Service) ElectronUpdates(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	os, arch, isElectronFile := common.ElectronPlatform(path.Base(request.Path))
+	if !isElectronFile {
+		return events.APIGatewayProxyResponse{Body: "Expected latest.yml, latest-mac.yml or latest-linux.yml", StatusCode: 404}, nil
+	}
+
+	params := request.QueryStringParameters
+	productName := params["productName"]
+	branch := params["branch"]
+	if productName == "" || branch == "" {
+		return events.APIGatewayProxyResponse{Body: "productName and branch must be specified", StatusCode: 400}, nil
+	}
+	lang := params["lang"]
+	if lang == "" {
+		lang = preferredLanguage(request)
+	}
+
+	release, getErr := common.FindRelease(s.Store, productName, branch, common.OrderByBuildId, func(ev *common.NewReleaseEvent) bool {
+		return ev.OfferedTo(params["clientId"]) && common.NewElectronUpdateInfo(ev, os, arch, lang) != nil
+	})
+	if getErr != nil {
+		log.Printf("Could not get data from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	if release == nil {
+		return events.APIGatewayProxyResponse{Body: "Nothing found for product and branch", StatusCode: 404}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       string(common.NewElectronUpdateInfo(release, os, arch, lang).RenderYAML()),
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "text/yaml; charset=utf-8"},
+	}, nil
+}
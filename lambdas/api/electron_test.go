@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_ElectronUpdates(t *testing.T) {
+	sha512 := "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+Pw=="
+	store := common.NewMemoryStore()
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 10, Branch: "master", ProductName: "test product", Semver: "1.0.0", Artifacts: []common.Artifact{
+		{Os: "windows", Url: "https://some/url/Setup-1.0.0.exe", Sha512: sha512},
+		{Os: "macos", Url: "https://some/url/app-1.0.0.zip", Sha512: sha512},
+	}})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 11, Branch: "master", ProductName: "test product", Semver: "1.1.0", Artifacts: []common.Artifact{
+		{Os: "windows", Url: "https://some/url/Setup-1.1.0.exe", Sha512: sha512},
+	}})
+	service := NewService(store)
+	params := map[string]string{"productName": "test product", "branch": "master"}
+
+	windows, _ := service.ElectronUpdates(context.Background(), events.APIGatewayProxyRequest{Path: "/electron/latest.yml", QueryStringParameters: params})
+	if windows.StatusCode != 200 || !strings.HasPrefix(windows.Body, `version: "1.1.0"`) || windows.Headers["Content-Type"] != "text/yaml; charset=utf-8" {
+		t.Errorf("latest.yml should have described 1.1.0 but got %d: %s", windows.StatusCode, windows.Body)
+	}
+
+	mac, _ := service.ElectronUpdates(context.Background(), events.APIGatewayProxyRequest{Path: "/electron/latest-mac.yml", QueryStringParameters: params})
+	if mac.StatusCode != 200 || !strings.Contains(mac.Body, `path: "https://some/url/app-1.0.0.zip"`) {
+		t.Errorf("latest-mac.yml should have fallen back to the last release with a macOS download but got %d: %s", mac.StatusCode, mac.Body)
+	}
+
+	linux, _ := service.ElectronUpdates(context.Background(), events.APIGatewayProxyRequest{Path: "/electron/latest-linux.yml", QueryStringParameters: params})
+	if linux.StatusCode != 404 {
+		t.Errorf("latest-linux.yml should have returned 404 as there are no Linux downloads but got %d", linux.StatusCode)
+	}
+
+	unknown, _ := service.ElectronUpdates(context.Background(), events.APIGatewayProxyRequest{Path: "/electron/RELEASES", QueryStringParameters: params})
+	if unknown.StatusCode != 404 {
+		t.Errorf("a file that electron-updater doesn't use should have returned 404 but got %d", unknown.StatusCode)
+	}
+
+	missing, _ := service.ElectronUpdates(context.Background(), events.APIGatewayProxyRequest{Path: "/electron/latest.yml"})
+	if missing.StatusCode != 400 {
+		t.Errorf("latest.yml without a product and branch should have returned 400 but got %d", missing.StatusCode)
+	}
+}
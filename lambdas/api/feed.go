@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+/**
+the newest releases of a product, or of one branch of it if branch is not empty, as feed entries. Withdrawn releases
+are left out.
+*/
+func (s *Service) feedEntries(productName string, branch string, lang string) ([]common.AtomEntry, error) {
+	entries := make([]common.AtomEntry, 0, common.MaxFeedEntries)
+	var entryErr error
+	err := common.EachRelease(s.Store, common.ReleaseQuery{ProductName: productName, Branch: branch}, func(ev *common.NewReleaseEvent) bool {
+		if ev.Withdrawn {
+			return true
+		}
+
+		var entry *common.AtomEntry
+		entry, entryErr = common.NewAtomEntry(ev, lang)
+		if entryErr != nil {
+			return false
+		}
+		entries = append(entries, *entry)
+		return len(entries) < common.MaxFeedEntries
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, entryErr
+}
+
+/**
+handler for GET /feed/{productName} and /feed/{productName}/{branch}, an Atom feed of new releases that people can
+subscribe to in a feed reader
+*/
+func (s *Service) ReleaseFeed(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	productName := request.PathParameters["productName"]
+	branch := request.PathParameters["branch"]
+	if productName == "" {
+		return events.APIGatewayProxyResponse{Body: "productName must be specified", StatusCode: 400}, nil
+	}
+	lang := request.QueryStringParameters["lang"]
+	if lang == "" {
+		lang = preferredLanguage(request)
+	}
+
+	title, titleErr := s.productTitle(productName)
+	if titleErr != nil {
+		log.Printf("Could not get product from database: %s", titleErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	entries, getErr := s.feedEntries(productName, branch, lang)
+	if getErr != nil {
+		log.Printf("Could not get data from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	output, renderErr := common.RenderAtomFeed(productName, branch, title, entries)
+	if renderErr != nil {
+		log.Printf("Could not render feed: %s", renderErr)
+		return events.APIGatewayProxyResponse{Body: "Could not render feed", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{
+		Body:       string(output),
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "application/atom+xml; charset=utf-8"},
+	}, nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_ReleaseFeed(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 10, Branch: "master", ProductName: "test product", DownloadUrl: "https://some/url/10", Timestamp: "2019-11-01T10:00:00Z"})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 11, Branch: "develop", ProductName: "test product", DownloadUrl: "https://some/url/11", Timestamp: "2019-11-02T10:00:00Z"})
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 12, Branch: "master", ProductName: "test product", DownloadUrl: "https://some/url/12", Timestamp: "2019-11-03T10:00:00Z", Withdrawn: true})
+	service := NewService(store)
+
+	product, _ := service.ReleaseFeed(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product"},
+	})
+	if product.StatusCode != 200 || product.Headers["Content-Type"] != "application/atom+xml; charset=utf-8" {
+		t.Fatalf("product feed should have returned 200 with an Atom content type but got %d %v", product.StatusCode, product.Headers)
+	}
+	if strings.Count(product.Body, "<entry>") != 2 || !strings.Contains(product.Body, "<updated>2019-11-02T10:00:00Z</updated>") {
+		t.Errorf("product feed should have listed both branches without the withdrawn release but got %s", product.Body)
+	}
+
+	branch, _ := service.ReleaseFeed(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if strings.Count(branch.Body, "<entry>") != 1 || !strings.Contains(branch.Body, `href="https://some/url/10"`) {
+		t.Errorf("branch feed should have listed just build 10 but got %s", branch.Body)
+	}
+
+	missing, _ := service.ReleaseFeed(context.Background(), events.APIGatewayProxyRequest{})
+	if missing.StatusCode != 400 {
+		t.Errorf("feed without a productName should have returned 400 but got %d", missing.StatusCode)
+	}
+}
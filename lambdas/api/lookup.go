@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"strings"
+)
+
+/**
+work out the search parameters for the request. The query string is preferred, as many clients and proxies
+drop GET bodies; older clients that send a JSON body are still supported
+*/
+func ParseSearchRequest(request events.APIGatewayProxyRequest) (*common.SearchRequest, error) {
+	if len(request.QueryStringParameters) > 0 {
+		return common.SearchRequestFromQuery(request.QueryStringParameters)
+	}
+
+	if request.Body == "" {
+		return nil, errors.New("No search parameters provided, expected productName and branch in the query string")
+	}
+
+	var searchReq common.SearchRequest
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &searchReq)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return nil, errors.New("Could not understand request body")
+	}
+	return &searchReq, nil
+}
+
+/**
+the client's first choice of language from the Accept-Language header, or an empty string if it didn't send one.
+API Gateway passes headers through in whatever case the client used
+*/
+func preferredLanguage(request events.APIGatewayProxyRequest) string {
+	for name, value := range request.Headers {
+		if strings.EqualFold(name, "Accept-Language") {
+			first := strings.SplitN(value, ",", 2)[0]
+			tag := strings.TrimSpace(strings.SplitN(first, ";", 2)[0])
+			if tag == "*" {
+				return ""
+			}
+			return tag
+		}
+	}
+	return ""
+}
+
+/**
+returns a function that accepts the releases that can be offered to the client: ones that it is inside the rollout of
+and, if it gave a platform, that have an artifact for it
+*/
+func releaseFilter(searchReq *common.SearchRequest) func(ev *common.NewReleaseEvent) bool {
+	return func(ev *common.NewReleaseEvent) bool {
+		if !ev.OfferedTo(searchReq.ClientId) {
+			return false
+		}
+		return searchReq.Os == "" || ev.FindArtifact(searchReq.Os, searchReq.Arch) != nil
+	}
+}
+
+/**
+find the newest release on a branch that can be offered to the client, in the order that it asked for. If the client
+gave a platform then the release is narrowed down to the artifact for it.
+*/
+func (s *Service) latestRelease(searchReq *common.SearchRequest, branch string) (*common.NewReleaseEvent, error) {
+	accept := releaseFilter(searchReq)
+
+	var release *common.NewReleaseEvent
+	if searchReq.OrderBy != common.OrderBySemver {
+		//usually the newest release will do, which saves reading the branch history
+		newest, getErr := common.MostRecentOnBranch(s.Store, searchReq.ProductName, branch)
+		if newest == nil || getErr != nil {
+			return nil, getErr
+		}
+		if accept(newest) {
+			release = newest
+		}
+	}
+
+	if release == nil {
+		var findErr error
+		release, findErr = common.FindRelease(s.Store, searchReq.ProductName, branch, searchReq.OrderBy, accept)
+		if release == nil || findErr != nil {
+			return nil, findErr
+		}
+	}
+
+	if searchReq.Os == "" {
+		return release, nil
+	}
+	return release.ForPlatform(searchReq.Os, searchReq.Arch), nil
+}
+
+var errNoChannels = errors.New("Channels are not supported by this storage backend")
+
+/**
+the branches that a lookup should search: the branch that the client asked for, or every branch of its channel and
+the channels that it falls back to.
+returns no branches if there is no such channel
+*/
+func (s *Service) searchBranches(searchReq *common.SearchRequest) ([]string, error) {
+	if searchReq.Channel == "" {
+		return []string{searchReq.Branch}, nil
+	}
+	if s.Channels == nil {
+		return nil, errNoChannels
+	}
+
+	chain, chainErr := common.ChannelChain(s.Channels, searchReq.ProductName, searchReq.Channel)
+	if chainErr != nil {
+		return nil, chainErr
+	}
+	return common.ChainBranches(chain), nil
+}
+
+/**
+find the newest release on any of the given branches that can be offered to the client, see latestRelease
+*/
+func (s *Service) latestOnBranches(searchReq *common.SearchRequest, branches []string) (*common.NewReleaseEvent, error) {
+	var newest *common.NewReleaseEvent
+	for _, branch := range branches {
+		release, getErr := s.latestRelease(searchReq, branch)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if release == nil {
+			continue
+		}
+
+		if newest == nil {
+			newest = release
+		} else if searchReq.OrderBy == common.OrderBySemver && common.CompareReleases(release, newest) > 0 {
+			newest = release
+		} else if searchReq.OrderBy != common.OrderBySemver && release.BuildId > newest.BuildId {
+			newest = release
+		}
+	}
+	return newest, nil
+}
+
+/**
+handler for GET /lookup. Responses are signed if the service has a signing key.
+*/
+func (s *Service) LookupVersion(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	response, err := s.lookupVersion(ctx, request)
+	return s.sign(request, response), err
+}
+
+func (s *Service) lookupVersion(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	searchReq, parseErr := ParseSearchRequest(request)
+	if parseErr != nil {
+		return events.APIGatewayProxyResponse{Body: parseErr.Error(), StatusCode: 400}, nil
+	}
+	searchReq.Format = requestedFormat(searchReq, request)
+	if searchReq.Os == "" {
+		searchReq.Os = common.FormatOs(searchReq.Format)
+	}
+
+	validationErr := searchReq.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+	if searchReq.Lang == "" {
+		searchReq.Lang = preferredLanguage(request)
+	}
+
+	var outArrayLen int
+	if searchReq.ShowsDefault() {
+		outArrayLen = 2
+	} else {
+		outArrayLen = 1
+	}
+
+	results := make([]*common.NewReleaseEvent, outArrayLen)
+
+	branches, channelErr := s.searchBranches(searchReq)
+	if channelErr == errNoChannels {
+		return events.APIGatewayProxyResponse{Body: channelErr.Error(), StatusCode: 501}, nil
+	} else if channelErr != nil {
+		log.Printf("Could not get channel from database: %s", channelErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	if len(branches) == 0 {
+		return events.APIGatewayProxyResponse{Body: "No such channel for product", StatusCode: 404}, nil
+	}
+
+	branchRecord, getErr := s.latestOnBranches(searchReq, branches)
+	if getErr != nil {
+		log.Printf("Could not get data from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	results[0] = branchRecord
+
+	if searchReq.ShowsDefault() {
+		defaultBranch, productErr := common.DefaultBranchOf(s.Products, searchReq.ProductName)
+		if productErr != nil {
+			log.Printf("Could not get product from database: %s", productErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+		defaultRecord, getErr := s.latestRelease(searchReq, defaultBranch)
+		if getErr != nil {
+			log.Printf("Could not get data from database: %s", getErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+		results[1] = defaultRecord
+	}
+
+	if results[0] == nil {
+		return events.APIGatewayProxyResponse{Body: "Nothing found for product and branch", StatusCode: 404}, nil
+	}
+
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		withNotes, notesErr := result.WithNotes(searchReq.Notes, searchReq.Lang)
+		if notesErr != nil {
+			log.Printf("Could not render release notes for %s build %d: %s", result.ProductName, result.BuildId, notesErr)
+			return events.APIGatewayProxyResponse{Body: "Could not render release notes", StatusCode: 500}, nil
+		}
+		results[i] = withNotes
+	}
+
+	if searchReq.Format == common.FormatSquirrelWindows || searchReq.Format == common.FormatSquirrelMac {
+		return s.squirrelResponse(searchReq, results[0]), nil
+	}
+
+	var output []byte
+	var marshalErr error
+	if searchReq.CurrentBuildId != nil {
+		checkResponse, checkErr := s.checkForUpdate(searchReq, branches, results)
+		if checkErr != nil {
+			log.Printf("Could not check for updates: %s", checkErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+		output, marshalErr = json.Marshal(checkResponse)
+	} else {
+		output, marshalErr = json.Marshal(results)
+	}
+
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
+
+/**
+compare the client in searchReq to the release in results[0] and work out whether it has to update. The minimum
+build and mandatory releases come from the branch that the release is on, and buildsBehind counts releases on all
+the branches that were searched.
+*/
+func (s *Service) checkForUpdate(searchReq *common.SearchRequest, branches []string, results []*common.NewReleaseEvent) (*common.UpdateCheckResponse, error) {
+	var settings *common.BranchSettings
+	if s.Settings != nil {
+		var settingsErr error
+		settings, settingsErr = s.Settings.GetBranchSettings(searchReq.ProductName, results[0].Branch)
+		if settingsErr != nil {
+			return nil, settingsErr
+		}
+	}
+
+	update, statusErr := common.UpdateStatus(s.Store, results[0], settings, *searchReq.CurrentBuildId, searchReq.CurrentSemver, releaseFilter(searchReq))
+	if statusErr != nil {
+		return nil, statusErr
+	}
+
+	currentBuildId := *searchReq.CurrentBuildId
+	buildsBehind := 0
+	for _, branch := range branches {
+		behindOnBranch, countErr := common.BuildsBehind(s.Store, searchReq.ProductName, branch, currentBuildId, searchReq.CurrentSemver, releaseFilter(searchReq))
+		if countErr != nil {
+			return nil, countErr
+		}
+		buildsBehind += behindOnBranch
+	}
+
+	response := &common.UpdateCheckResponse{
+		Status:       common.ClientStatus(results[0], currentBuildId, searchReq.CurrentSemver),
+		Update:       update,
+		Release:      results[0],
+		BuildsBehind: buildsBehind,
+		Releases:     results,
+	}
+	if settings != nil {
+		response.MinimumBuildId = settings.MinimumBuildId
+	}
+	return response, nil
+}
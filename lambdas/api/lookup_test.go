@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/verify"
+	"testing"
+	"time"
+)
+
+func TestService_LookupVersion(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 11, Branch: "somebranch", DownloadUrl: "https://some/url/11", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 12, Branch: "semverbranch", DownloadUrl: "https://some/url/12", ProductName: "test product", Semver: "2.0.0"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 14, Branch: "platformbranch", ProductName: "test product", Artifacts: []common.Artifact{
+		{Os: "macos", Arch: "arm64", Url: "https://some/url/14/mac-arm64.dmg"},
+		{Os: "windows", Url: "https://some/url/14/setup.exe"},
+	}})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 15, Branch: "platformbranch", ProductName: "test product", Artifacts: []common.Artifact{
+		{Os: "windows", Url: "https://some/url/15/setup.exe"},
+	}})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 13, Branch: "semverbranch", DownloadUrl: "https://some/url/13", ProductName: "test product", Semver: "2.0.0-rc.1"})
+
+	queryResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "somebranch", "alwaysShowMaster": "true"},
+	})
+	if queryResponse.StatusCode != 200 {
+		t.Fatalf("query string lookup should have returned 200 but got %d: %s", queryResponse.StatusCode, queryResponse.Body)
+	}
+	var results []*common.NewReleaseEvent
+	json.Unmarshal([]byte(queryResponse.Body), &results)
+	if len(results) != 2 || results[0].BuildId != 11 || results[1].BuildId != 10 {
+		t.Errorf("query string lookup returned the wrong records: %s", queryResponse.Body)
+	}
+
+	bodyResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName": "test product", "branch": "master"}`,
+	})
+	if bodyResponse.StatusCode != 200 {
+		t.Errorf("body lookup should have returned 200 but got %d: %s", bodyResponse.StatusCode, bodyResponse.Body)
+	}
+
+	notFoundResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "nobranch"},
+	})
+	if notFoundResponse.StatusCode != 404 {
+		t.Errorf("lookup of an unknown branch should have returned 404 but got %d", notFoundResponse.StatusCode)
+	}
+
+	emptyResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{})
+	if emptyResponse.StatusCode != 400 {
+		t.Errorf("lookup with no parameters should have returned 400 but got %d", emptyResponse.StatusCode)
+	}
+
+	invalidResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product"},
+	})
+	if invalidResponse.StatusCode != 400 {
+		t.Errorf("lookup with no branch should have returned 400 but got %d", invalidResponse.StatusCode)
+	}
+
+	semverResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "semverbranch", "orderBy": "semver"},
+	})
+	if semverResponse.StatusCode != 200 {
+		t.Fatalf("semver lookup should have returned 200 but got %d: %s", semverResponse.StatusCode, semverResponse.Body)
+	}
+	json.Unmarshal([]byte(semverResponse.Body), &results)
+	if len(results) != 1 || results[0].Semver != "2.0.0" {
+		t.Errorf("semver lookup returned the wrong records: %s", semverResponse.Body)
+	}
+
+	badOrderResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "orderBy": "random"},
+	})
+	if badOrderResponse.StatusCode != 400 {
+		t.Errorf("lookup with an unknown orderBy should have returned 400 but got %d", badOrderResponse.StatusCode)
+	}
+
+	platformResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "platformbranch", "os": "darwin", "arch": "aarch64"},
+	})
+	if platformResponse.StatusCode != 200 {
+		t.Fatalf("platform lookup should have returned 200 but got %d: %s", platformResponse.StatusCode, platformResponse.Body)
+	}
+	json.Unmarshal([]byte(platformResponse.Body), &results)
+	if len(results) != 1 || results[0].BuildId != 14 || results[0].DownloadUrl != "https://some/url/14/mac-arm64.dmg" || len(results[0].Artifacts) != 1 {
+		t.Errorf("platform lookup should have returned the newest build with a mac artifact, got %s", platformResponse.Body)
+	}
+
+	noPlatformResponse, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "platformbranch", "os": "linux"},
+	})
+	if noPlatformResponse.StatusCode != 404 {
+		t.Errorf("lookup for a platform with no artifacts should have returned 404 but got %d", noPlatformResponse.StatusCode)
+	}
+
+}
+
+func TestService_LookupVersionSigned(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	service.SigningKey = privateKey
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+
+	response, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		Path:                  "/lookup",
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if response.StatusCode != 200 {
+		t.Fatalf("lookup should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+	}
+	signedRequest := response.Headers[verify.RequestHeader]
+	if signedRequest != "/lookup?branch=master&productName=test+product" {
+		t.Errorf("lookup response was signed for the wrong request, got %s", signedRequest)
+	}
+	if !verify.Fresh(response.Headers[verify.TimestampHeader], time.Minute) {
+		t.Errorf("lookup response had a bad timestamp, got %s", response.Headers[verify.TimestampHeader])
+	}
+	payload := verify.Payload(signedRequest, response.Headers[verify.TimestampHeader], []byte(response.Body))
+	if err := verify.Signature(publicKey, payload, response.Headers[verify.SignatureHeader]); err != nil {
+		t.Errorf("lookup response signature should have verified but got %s", err)
+	}
+	if err := verify.Signature(publicKey, []byte(response.Body), response.Headers[verify.SignatureHeader]); err != verify.ErrBadSignature {
+		t.Errorf("the signature should not have verified against the body alone but got %v", err)
+	}
+	if response.Headers[verify.KeyIdHeader] != verify.KeyId(publicKey) {
+		t.Errorf("lookup response had the wrong key id, got %s", response.Headers[verify.KeyIdHeader])
+	}
+}
+
+func TestService_LookupVersionNotes(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product",
+		ReleaseNotes: common.ReleaseNotes{"en": "# New", "fr": "# Nouveau"}})
+
+	response, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "notes": "html"},
+		Headers:               map[string]string{"accept-language": "fr-FR,fr;q=0.9,en;q=0.8"},
+	})
+	if response.StatusCode != 200 {
+		t.Fatalf("lookup should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+	}
+	var results []common.NewReleaseEvent
+	json.Unmarshal([]byte(response.Body), &results)
+	if len(results) != 1 || len(results[0].ReleaseNotes) != 1 || results[0].ReleaseNotes["fr"] != "<h1>Nouveau</h1>\n" {
+		t.Errorf("lookup should have returned the french notes as HTML but got %s", response.Body)
+	}
+
+	badFormat, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "notes": "pdf"},
+	})
+	if badFormat.StatusCode != 400 {
+		t.Errorf("lookup with an unknown notes format should have returned 400 but got %d", badFormat.StatusCode)
+	}
+}
+
+func TestService_LookupVersionUpdateCheck(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product", Mandatory: true})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 11, Branch: "master", DownloadUrl: "https://some/url/11", ProductName: "test product"})
+	store.PutBranchSettings(&common.BranchSettings{ProductName: "test product", Branch: "master", MinimumBuildId: 5})
+
+	tests := []struct {
+		currentBuildId string
+		expected       string
+		expectedStatus string
+		expectedBehind int
+	}{
+		{"4", common.UpdateRequired, common.StatusUpdateAvailable, 2},
+		{"9", common.UpdateRequired, common.StatusUpdateAvailable, 2},
+		{"10", common.UpdateOptional, common.StatusUpdateAvailable, 1},
+		{"11", common.UpdateNone, common.StatusUpToDate, 0},
+		{"12", common.UpdateNone, common.StatusAheadOfServer, 0},
+	}
+	for _, test := range tests {
+		response, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "currentBuildId": test.currentBuildId},
+		})
+		if response.StatusCode != 200 {
+			t.Fatalf("lookup should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+		}
+		var checkResponse common.UpdateCheckResponse
+		json.Unmarshal([]byte(response.Body), &checkResponse)
+		if checkResponse.Update != test.expected || checkResponse.MinimumBuildId != 5 {
+			t.Errorf("lookup for build %s should have said %s but got %s", test.currentBuildId, test.expected, response.Body)
+		}
+		if checkResponse.Status != test.expectedStatus || checkResponse.BuildsBehind != test.expectedBehind {
+			t.Errorf("lookup for build %s should have been %s and %d behind but got %s", test.currentBuildId, test.expectedStatus, test.expectedBehind, response.Body)
+		}
+		if checkResponse.Release == nil || checkResponse.Release.BuildId != 11 {
+			t.Errorf("lookup for build %s returned the wrong target release: %s", test.currentBuildId, response.Body)
+		}
+		if len(checkResponse.Releases) != 1 || checkResponse.Releases[0].BuildId != 11 {
+			t.Errorf("lookup for build %s returned the wrong releases: %s", test.currentBuildId, response.Body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"net/http"
+)
+
+var errNoProducts = errors.New("Product configuration is not supported by this storage backend")
+
+/**
+handler for /products in Lambda, where one function serves every method of the resource
+*/
+func (s *Service) ManageProducts(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		return s.GetProduct(ctx, request)
+	case http.MethodPost:
+		return s.PutProduct(ctx, request)
+	default:
+		return events.APIGatewayProxyResponse{Body: "Method not allowed", StatusCode: 405}, nil
+	}
+}
+
+/**
+handler for GET /products?productName=..., which returns the registry entry of a product, or GET /products which
+lists every registered product
+*/
+func (s *Service) GetProduct(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Products == nil {
+		return events.APIGatewayProxyResponse{Body: errNoProducts.Error(), StatusCode: 501}, nil
+	}
+
+	var result interface{}
+	if productName := request.QueryStringParameters["productName"]; productName != "" {
+		product, getErr := s.Products.GetProduct(productName)
+		if getErr != nil {
+			log.Printf("Could not get product from database: %s", getErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+		if product == nil {
+			return events.APIGatewayProxyResponse{Body: "Product has not been registered", StatusCode: 404}, nil
+		}
+		result = product
+	} else {
+		products, listErr := s.Products.ListProducts()
+		if listErr != nil {
+			log.Printf("Could not get products from database: %s", listErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+		result = products
+	}
+
+	output, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
+
+/**
+handler for POST /products, which registers a product or replaces its registry entry
+*/
+func (s *Service) PutProduct(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Products == nil {
+		return events.APIGatewayProxyResponse{Body: errNoProducts.Error(), StatusCode: 501}, nil
+	}
+
+	var product common.Product
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &product)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not understand request body", StatusCode: 400}, nil
+	}
+
+	validationErr := product.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	putErr := s.Products.PutProduct(&product)
+	if putErr != nil {
+		log.Printf("Could not write product to database: %s", putErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(product)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
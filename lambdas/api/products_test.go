@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_ManageProducts(t *testing.T) {
+	service := NewService(common.NewMemoryStore())
+
+	unconfigured, _ := service.ManageProducts(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"productName": "test product"},
+	})
+	if unconfigured.StatusCode != 404 {
+		t.Errorf("get of an unconfigured product should have returned 404 but got %d", unconfigured.StatusCode)
+	}
+
+	saved, _ := service.ManageProducts(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"productName":"test product","defaultBranch":"main"}`,
+	})
+	if saved.StatusCode != 200 {
+		t.Fatalf("put should have returned 200 but got %d: %s", saved.StatusCode, saved.Body)
+	}
+
+	got, _ := service.ManageProducts(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"productName": "test product"},
+	})
+	if got.StatusCode != 200 || !strings.Contains(got.Body, `"defaultBranch":"main"`) {
+		t.Errorf("get should have returned the saved product but got %d: %s", got.StatusCode, got.Body)
+	}
+
+	service.PutProduct(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"another product","defaultBranch":"trunk","owner":"desktop-team"}`,
+	})
+	list, _ := service.ManageProducts(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	if list.StatusCode != 200 || !strings.HasPrefix(list.Body, `[{"productName":"another product"`) || !strings.Contains(list.Body, `"productName":"test product"`) {
+		t.Errorf("get without a productName should have listed every product but got %d: %s", list.StatusCode, list.Body)
+	}
+
+	invalid, _ := service.ManageProducts(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"productName":"test product"}`,
+	})
+	if invalid.StatusCode != 400 {
+		t.Errorf("put without a default branch should have returned 400 but got %d", invalid.StatusCode)
+	}
+}
+
+func TestService_LookupVersionAlsoShowDefault(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "main"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "main", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 11, Branch: "master", DownloadUrl: "https://some/url/11", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 12, Branch: "feature", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+
+	for _, param := range []string{"alsoShowDefault", "alwaysShowMaster"} {
+		response, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{"productName": "test product", "branch": "feature", param: "true"},
+		})
+		if response.StatusCode != 200 || !strings.Contains(response.Body, `"buildId":12`) || !strings.Contains(response.Body, `"buildId":10`) {
+			t.Errorf("lookup with %s should have returned the feature and main builds but got %d: %s", param, response.StatusCode, response.Body)
+		}
+		if strings.Contains(response.Body, `"buildId":11`) {
+			t.Errorf("lookup with %s should not have returned the master build of a product whose default branch is main", param)
+		}
+	}
+}
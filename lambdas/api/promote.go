@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+var errNoPromotions = errors.New("Promotions are not supported by this storage backend")
+
+//recorded as the caller of a request that didn't need an API key
+const anonymousCaller = "anonymous"
+
+/**
+identify the caller of a protected endpoint for auditing, from the API key that API Gateway checked the request
+against rather than anything in the request body. Only a fingerprint of the key is kept, the first 8 bytes of its
+SHA-256 in hex, so that the key itself never ends up in a record that is returned to clients.
+*/
+func callerOf(request events.APIGatewayProxyRequest) string {
+	apiKey := request.RequestContext.Identity.APIKey
+	if apiKey == "" {
+		return anonymousCaller
+	}
+	digest := sha256.Sum256([]byte(apiKey))
+	return "apikey:" + hex.EncodeToString(digest[:8])
+}
+
+/**
+handler for POST /promote, which publishes a build that is already on one branch on another branch or channel,
+without rebuilding it. The copy records who promoted it, see callerOf.
+*/
+func (s *Service) PromoteRelease(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if s.Promotions == nil {
+		return events.APIGatewayProxyResponse{Body: errNoPromotions.Error(), StatusCode: 501}, nil
+	}
+
+	var promoteReq common.PromoteRequest
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &promoteReq)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not understand request body", StatusCode: 400}, nil
+	}
+
+	validationErr := promoteReq.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	branch := promoteReq.Branch
+	if promoteReq.Channel != "" {
+		if s.Channels == nil {
+			return events.APIGatewayProxyResponse{Body: errNoChannels.Error(), StatusCode: 501}, nil
+		}
+		channel, channelErr := s.Channels.GetChannel(promoteReq.ProductName, promoteReq.Channel)
+		if channelErr != nil {
+			log.Printf("Could not get channel from database: %s", channelErr)
+			return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+		}
+		if channel == nil {
+			return events.APIGatewayProxyResponse{Body: "No such channel for product", StatusCode: 404}, nil
+		}
+		branch = channel.Branches[0]
+	}
+
+	release, getErr := s.Store.GetRelease(promoteReq.ProductName, promoteReq.BuildId)
+	if getErr != nil {
+		log.Printf("Could not get release from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	if release == nil {
+		return events.APIGatewayProxyResponse{Body: "Nothing found for product and buildId", StatusCode: 404}, nil
+	}
+	if release.Withdrawn {
+		return events.APIGatewayProxyResponse{Body: "A withdrawn release can't be promoted", StatusCode: 409}, nil
+	}
+	if release.Branch == branch {
+		return events.APIGatewayProxyResponse{Body: "The build is already on branch " + branch, StatusCode: 400}, nil
+	}
+
+	//the target branch has to be allowed just as if the build had been logged there
+	target := *release
+	target.Branch = branch
+	if status, message := s.checkRegistered(&target); status != 0 {
+		return events.APIGatewayProxyResponse{Body: message, StatusCode: status}, nil
+	}
+
+	//the files may have been tidied away since the build was logged
+	if unverified := s.unverifiedDownload(release); unverified != "" {
+		return events.APIGatewayProxyResponse{Body: "Could not verify release URL " + unverified, StatusCode: 400}, nil
+	}
+
+	promoted, promoteErr := common.Promote(s.Promotions, release, branch, callerOf(request), promoteReq.RolloutPercentage)
+	if promoteErr != nil {
+		log.Printf("Could not promote release: %s", promoteErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(promoted)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
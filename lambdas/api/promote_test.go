@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_PromoteRelease(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "master", AllowedBranches: []string{"master", "release-candidate"}})
+	service := NewService(store)
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool { return uploadUrl != "https://some/url/deleted" }
+	store.PutChannel(&common.Channel{ProductName: "test product", Name: "stable", Branches: []string{"master"}})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 13, Branch: "release-candidate", DownloadUrl: "https://some/url/deleted", ProductName: "test product"})
+
+	//whoever is named in the body, the promotion is recorded against the API key that made the request
+	promoteReq := events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":12,"channel":"stable","promotedBy":"someone-else"}`,
+	}
+	promoteReq.RequestContext.Identity.APIKey = "qa-team-key"
+	response, _ := service.PromoteRelease(context.Background(), promoteReq)
+	if response.StatusCode != 200 {
+		t.Fatalf("promote should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+	}
+	caller := callerOf(promoteReq)
+	if !strings.HasPrefix(caller, "apikey:") || strings.Contains(caller, "qa-team-key") {
+		t.Errorf("the caller should have been a fingerprint of the API key but got %s", caller)
+	}
+	if !strings.Contains(response.Body, `"branch":"master"`) || !strings.Contains(response.Body, `"promotedFrom":"release-candidate"`) || !strings.Contains(response.Body, `"promotedBy":"`+caller+`"`) {
+		t.Errorf("promote should have returned the promoted copy but got %s", response.Body)
+	}
+
+	lookup, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if !strings.Contains(lookup.Body, `"buildId":12`) {
+		t.Errorf("lookup on master should have returned the promoted build but got %s", lookup.Body)
+	}
+
+	unverified, _ := service.PromoteRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":13,"branch":"master"}`,
+	})
+	if unverified.StatusCode != 400 {
+		t.Errorf("promote of a build whose download has gone should have returned 400 but got %d", unverified.StatusCode)
+	}
+
+	sameBranch, _ := service.PromoteRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":10,"branch":"master"}`,
+	})
+	if sameBranch.StatusCode != 400 {
+		t.Errorf("promote to the branch the build is on should have returned 400 but got %d", sameBranch.StatusCode)
+	}
+
+	missing, _ := service.PromoteRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":99,"branch":"master"}`,
+	})
+	if missing.StatusCode != 404 {
+		t.Errorf("promote of a missing release should have returned 404 but got %d", missing.StatusCode)
+	}
+
+	noKey, _ := service.PromoteRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":12,"branch":"master"}`,
+	})
+	if noKey.StatusCode != 200 || !strings.Contains(noKey.Body, `"promotedBy":"anonymous"`) {
+		t.Errorf("promote without an API key should have been recorded as anonymous but got %d: %s", noKey.StatusCode, noKey.Body)
+	}
+
+	notAllowed, _ := service.PromoteRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":10,"branch":"develop"}`,
+	})
+	if notAllowed.StatusCode != 400 {
+		t.Errorf("promote to a branch that isn't allowed should have returned 400 but got %d", notAllowed.StatusCode)
+	}
+
+	common.WithdrawRelease(store, "test product", 10, "bad build")
+	withdrawn, _ := service.PromoteRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":10,"branch":"release-candidate"}`,
+	})
+	if withdrawn.StatusCode != 409 {
+		t.Errorf("promote of a withdrawn release should have returned 409 but got %d", withdrawn.StatusCode)
+	}
+}
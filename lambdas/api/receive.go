@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"net/http"
+	"time"
+)
+
+/**
+check that uploadUrl can be downloaded by making a HEAD request to it. If expectedSize is not 0 then the Content-Length
+returned by the server must match it; if the server doesn't say how big the file is then that check is skipped.
+*/
+func TestUploadedContent(uploadUrl string, expectedSize int64) bool {
+	response, headErr := http.Head(uploadUrl)
+	if headErr != nil {
+		log.Printf("Could not verify uploaded URL %s: %s", uploadUrl, headErr)
+		return false
+	}
+
+	if response.StatusCode != 200 {
+		log.Printf("Could not verify uploaded URL %s - server returned %d", uploadUrl, response.StatusCode)
+		return false
+	}
+
+	if expectedSize != 0 {
+		if response.ContentLength < 0 {
+			log.Printf("Server did not return a Content-Length for %s, can't check the size", uploadUrl)
+		} else if response.ContentLength != expectedSize {
+			log.Printf("Could not verify uploaded URL %s - server says it is %d bytes but %d were expected", uploadUrl, response.ContentLength, expectedSize)
+			return false
+		}
+	}
+	return true
+}
+
+/**
+check that every download of a release can be fetched, see TestUploadedContent.
+returns the URL of the first one that can't, or an empty string if they all can
+*/
+func (s *Service) unverifiedDownload(ev *common.NewReleaseEvent) string {
+	uploads := make([]common.Artifact, 0, len(ev.Artifacts)+1)
+	if ev.DownloadUrl != "" {
+		uploads = append(uploads, common.Artifact{Url: ev.DownloadUrl, Size: ev.Size})
+	}
+	uploads = append(uploads, ev.Artifacts...)
+
+	for _, upload := range uploads {
+		if !s.VerifyContent(upload.Url, upload.Size) {
+			return upload.Url
+		}
+	}
+	return ""
+}
+
+/**
+check a new release against the product registry. Releases of unregistered products are rejected, so that a typo in
+a pipeline doesn't quietly create a new product.
+returns the status code and message to reject the release with, or 0 if it is acceptable
+*/
+func (s *Service) checkRegistered(ev *common.NewReleaseEvent) (int, string) {
+	if s.Products == nil {
+		return 0, ""
+	}
+
+	product, getErr := s.Products.GetProduct(ev.ProductName)
+	if getErr != nil {
+		log.Printf("Could not get product from database: %s", getErr)
+		return 500, "Could not get info from database"
+	}
+	if product == nil {
+		return 400, "Unknown product " + ev.ProductName + ", it must be registered with /products first"
+	}
+	if checkErr := product.CheckRelease(ev); checkErr != nil {
+		return 400, checkErr.Error()
+	}
+	return 0, ""
+}
+
+/**
+handler for POST /newversion
+*/
+func (s *Service) ReceiveVersion(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	log.Printf("Processing request with ID %s.\n", request.RequestContext.RequestID)
+	log.Printf("Body size is %d\n", len(request.Body))
+
+	var releaseEvent common.NewReleaseEvent
+
+	log.Printf("Got request body '%s'", request.Body)
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &releaseEvent)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s\n", unmarshalErr)
+		return events.APIGatewayProxyResponse{StatusCode: 400}, unmarshalErr
+	}
+
+	validationErr := releaseEvent.Validate()
+	if validationErr != nil {
+		log.Printf("Incoming JSON was not valid: %s\n", validationErr)
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: validationErr.Error()}, nil
+	}
+	if releaseEvent.Withdrawn || releaseEvent.WithdrawnReason != "" || releaseEvent.WithdrawnAt != "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "A new release can't be withdrawn, use /withdraw for that"}, nil
+	}
+
+	if releaseEvent.PromotedFrom != "" || releaseEvent.PromotedBy != "" || releaseEvent.PromotedAt != "" || len(releaseEvent.PromotedTo) > 0 {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "A new release can't be promoted, use /promote for that"}, nil
+	}
+
+	if status, message := s.checkRegistered(&releaseEvent); status != 0 {
+		return events.APIGatewayProxyResponse{StatusCode: status, Body: message}, nil
+	}
+
+	newer, newerErr := common.MostRecentOnBranch(s.Store, releaseEvent.ProductName, releaseEvent.Branch)
+	if newerErr != nil {
+		log.Printf("Could not get data from database: %s", newerErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	if newer != nil && releaseEvent.BuildId < newer.BuildId {
+		return s.regressingRelease(&releaseEvent, newer)
+	}
+
+	if unverified := s.unverifiedDownload(&releaseEvent); unverified != "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Could not verify provided release URL " + unverified}, nil
+	}
+
+	releaseEvent.Timestamp = time.Now().UTC().Format(time.RFC3339)
+
+	putErr := s.Store.CreateRelease(&releaseEvent)
+	if putErr == common.ErrReleaseExists {
+		return s.existingRelease(&releaseEvent)
+	} else if putErr != nil {
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, errors.New("Could not write record to database: " + putErr.Error())
+	} else {
+		return events.APIGatewayProxyResponse{StatusCode: 201}, nil
+	}
+}
+
+/**
+work out the response to a release whose buildId has already been logged. A pipeline that is run again sends the same
+release, which succeeds without changing anything; a different release with the same buildId is rejected with a 409
+and the record that is already stored, rather than overwriting it.
+*/
+func (s *Service) existingRelease(releaseEvent *common.NewReleaseEvent) (events.APIGatewayProxyResponse, error) {
+	existing, getErr := s.Store.GetRelease(releaseEvent.ProductName, releaseEvent.BuildId)
+	if getErr != nil {
+		log.Printf("Could not get release from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	if existing == nil {
+		//it was deleted since CreateRelease looked, so the pipeline can simply try again
+		return events.APIGatewayProxyResponse{Body: "The release changed while it was being logged, please try again", StatusCode: 409}, nil
+	}
+
+	output, marshalErr := json.Marshal(existing)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+
+	if existing.SameBuildAs(releaseEvent) {
+		log.Printf("%s build %d has already been logged, nothing to do", releaseEvent.ProductName, releaseEvent.BuildId)
+		return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+	}
+	log.Printf("Rejecting %s build %d, a different release with that buildId has already been logged", releaseEvent.ProductName, releaseEvent.BuildId)
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 409}, nil
+}
+
+/**
+work out the response to a release that is older than the newest one already on its branch. A pipeline run that
+finishes late would otherwise put an older build back in front of clients, so it is rejected with a 409 and the newer
+record, unless the buildId has already been logged, in which case it is treated as any other repeat (see
+existingRelease) so that re-running an old pipeline stays harmless.
+*/
+func (s *Service) regressingRelease(releaseEvent *common.NewReleaseEvent, newer *common.NewReleaseEvent) (events.APIGatewayProxyResponse, error) {
+	existing, getErr := s.Store.GetRelease(releaseEvent.ProductName, releaseEvent.BuildId)
+	if getErr != nil {
+		log.Printf("Could not get release from database: %s", getErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+	if existing != nil {
+		return s.existingRelease(releaseEvent)
+	}
+
+	output, marshalErr := json.Marshal(newer)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	log.Printf("Rejecting %s build %d, build %d is already on %s", releaseEvent.ProductName, releaseEvent.BuildId, newer.BuildId, releaseEvent.Branch)
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 409}, nil
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTestUploadedContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+			return
+		}
+		if r.URL.Path == "/unsized" {
+			w.Header().Set("Transfer-Encoding", "chunked")
+			w.WriteHeader(200)
+			return
+		}
+		w.Header().Set("Content-Length", "1234")
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	if !TestUploadedContent(server.URL+"/file", 0) {
+		t.Errorf("existing file with no expected size should have verified")
+	}
+	if !TestUploadedContent(server.URL+"/file", 1234) {
+		t.Errorf("existing file with the right size should have verified")
+	}
+	if TestUploadedContent(server.URL+"/file", 999) {
+		t.Errorf("existing file with the wrong size should not have verified")
+	}
+	if !TestUploadedContent(server.URL+"/unsized", 999) {
+		t.Errorf("existing file with no Content-Length should have verified")
+	}
+	if TestUploadedContent(server.URL+"/missing", 0) {
+		t.Errorf("missing file should not have verified")
+	}
+}
+
+func TestService_ReceiveVersion(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "master"})
+	service := NewService(store)
+	var verifiedUrls []string
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool {
+		verifiedUrls = append(verifiedUrls, uploadUrl)
+		return uploadUrl != "https://some.server.com/missing"
+	}
+
+	response, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":12,"branch":"master","productName":"test product","artifacts":[
+			{"os":"macos","arch":"arm64","url":"https://some.server.com/mac.dmg"},
+			{"os":"windows","url":"https://some.server.com/setup.exe"}]}`,
+	})
+	if response.StatusCode != 201 {
+		t.Fatalf("valid release should have returned 201 but got %d: %s", response.StatusCode, response.Body)
+	}
+	if len(verifiedUrls) != 2 {
+		t.Errorf("every artifact url should have been verified, got %v", verifiedUrls)
+	}
+
+	stored, _ := store.GetRelease("test product", 12)
+	if stored == nil || len(stored.Artifacts) != 2 || stored.Timestamp == "" {
+		t.Errorf("release was not stored properly, got %v", stored)
+	}
+
+	var verifiedSize int64
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool {
+		verifiedSize = expectedSize
+		return true
+	}
+	sizedResponse, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":15,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/file","size":1234,
+			"sha256":"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}`,
+	})
+	if sizedResponse.StatusCode != 201 || verifiedSize != 1234 {
+		t.Errorf("release with a size should have been verified against it, got %d and size %d", sizedResponse.StatusCode, verifiedSize)
+	}
+	sized, _ := store.GetRelease("test product", 15)
+	if sized == nil || sized.Size != 1234 || sized.Sha256 != "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Errorf("size and checksum were not stored, got %v", sized)
+	}
+
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool {
+		return uploadUrl != "https://some.server.com/missing"
+	}
+
+	missingResponse, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":19,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/missing"}`,
+	})
+	if missingResponse.StatusCode != 400 {
+		t.Errorf("release with an unreachable url should have returned 400 but got %d", missingResponse.StatusCode)
+	}
+
+	invalidResponse, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":14,"branch":"master","productName":"test product"}`,
+	})
+	if invalidResponse.StatusCode != 400 {
+		t.Errorf("release with no download should have returned 400 but got %d", invalidResponse.StatusCode)
+	}
+
+	withdrawnResponse, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":16,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/file","withdrawn":true}`,
+	})
+	if withdrawnResponse.StatusCode != 400 {
+		t.Errorf("release that is already withdrawn should have returned 400 but got %d", withdrawnResponse.StatusCode)
+	}
+
+	promotedResponse, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":17,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/file","promotedFrom":"develop"}`,
+	})
+	if promotedResponse.StatusCode != 400 {
+		t.Errorf("release that claims to be promoted should have returned 400 but got %d", promotedResponse.StatusCode)
+	}
+
+	unknownResponse, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":18,"branch":"master","productName":"test prodcut","downloadUrl":"https://some.server.com/file"}`,
+	})
+	if unknownResponse.StatusCode != 400 {
+		t.Errorf("release of an unregistered product should have returned 400 but got %d", unknownResponse.StatusCode)
+	}
+}
+
+func TestService_ReceiveVersionChecksRegistry(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{
+		ProductName:     "test product",
+		DefaultBranch:   "main",
+		AllowedBranches: []string{"main", "release-*"},
+		AllowedHosts:    []string{"*.example.com"},
+	})
+	service := NewService(store)
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool { return true }
+
+	allowed, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":12,"branch":"release-2","productName":"test product","downloadUrl":"https://downloads.example.com/file"}`,
+	})
+	if allowed.StatusCode != 201 {
+		t.Errorf("release on an allowed branch and host should have returned 201 but got %d: %s", allowed.StatusCode, allowed.Body)
+	}
+
+	wrongBranch, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":13,"branch":"feature","productName":"test product","downloadUrl":"https://downloads.example.com/file"}`,
+	})
+	if wrongBranch.StatusCode != 400 {
+		t.Errorf("release on a branch that isn't allowed should have returned 400 but got %d", wrongBranch.StatusCode)
+	}
+
+	wrongHost, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":14,"branch":"main","productName":"test product","artifacts":[{"os":"windows","url":"https://example.org/setup.exe"}]}`,
+	})
+	if wrongHost.StatusCode != 400 {
+		t.Errorf("release with an artifact on a host that isn't allowed should have returned 400 but got %d", wrongHost.StatusCode)
+	}
+}
+
+func TestService_ReceiveVersionDuplicate(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "master"})
+	service := NewService(store)
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool { return true }
+
+	body := `{"event":"newversion","buildId":12,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/file"}`
+	first, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{Body: body})
+	if first.StatusCode != 201 {
+		t.Fatalf("first release should have returned 201 but got %d: %s", first.StatusCode, first.Body)
+	}
+	logged, _ := store.GetRelease("test product", 12)
+
+	repeat, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{Body: body})
+	if repeat.StatusCode != 200 || !strings.Contains(repeat.Body, `"timestamp":"`+logged.Timestamp+`"`) {
+		t.Errorf("identical repeat should have returned 200 with the stored record but got %d: %s", repeat.StatusCode, repeat.Body)
+	}
+
+	conflict, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":12,"branch":"develop","productName":"test product","downloadUrl":"https://some.server.com/other"}`,
+	})
+	if conflict.StatusCode != 409 || !strings.Contains(conflict.Body, `"downloadUrl":"https://some.server.com/file"`) {
+		t.Errorf("conflicting release should have returned 409 with the stored record but got %d: %s", conflict.StatusCode, conflict.Body)
+	}
+
+	stored, _ := store.GetRelease("test product", 12)
+	if stored.Branch != "master" || stored.DownloadUrl != "https://some.server.com/file" {
+		t.Errorf("conflicting release should not have overwritten the stored record")
+	}
+}
+
+func TestService_ReceiveVersionRegressing(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "master"})
+	service := NewService(store)
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool { return true }
+
+	oldBody := `{"event":"newversion","buildId":10,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/10"}`
+	for _, body := range []string{
+		oldBody,
+		`{"event":"newversion","buildId":12,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/12"}`,
+	} {
+		if response, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{Body: body}); response.StatusCode != 201 {
+			t.Fatalf("release should have returned 201 but got %d: %s", response.StatusCode, response.Body)
+		}
+	}
+
+	regressing, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":11,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/11"}`,
+	})
+	if regressing.StatusCode != 409 || !strings.Contains(regressing.Body, `"buildId":12`) {
+		t.Errorf("regressing release should have returned 409 with the newer record but got %d: %s", regressing.StatusCode, regressing.Body)
+	}
+	if stored, _ := store.GetRelease("test product", 11); stored != nil {
+		t.Errorf("regressing release should not have been stored")
+	}
+
+	repeat, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{Body: oldBody})
+	if repeat.StatusCode != 200 || !strings.Contains(repeat.Body, `"buildId":10`) {
+		t.Errorf("identical repeat of an older release should have returned 200 with the stored record but got %d: %s", repeat.StatusCode, repeat.Body)
+	}
+
+	otherBranch, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":11,"branch":"develop","productName":"test product","downloadUrl":"https://some.server.com/11"}`,
+	})
+	if otherBranch.StatusCode != 201 {
+		t.Errorf("an older buildId on another branch should have returned 201 but got %d: %s", otherBranch.StatusCode, otherBranch.Body)
+	}
+}
+
+/**
+a store whose promotions can't be read, like a DynamoStore that wasn't given PROMOTIONS_TABLE_NAME
+*/
+type brokenPromotionsStore struct {
+	*common.MemoryStore
+}
+
+func (s *brokenPromotionsStore) ListPromotions(productName string, branch string) ([]common.NewReleaseEvent, error) {
+	return nil, errors.New("promotions table name is not set")
+}
+
+func TestService_ReceiveVersionPromotionsFail(t *testing.T) {
+	store := &brokenPromotionsStore{common.NewMemoryStore()}
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "master"})
+	service := NewService(store)
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool { return true }
+
+	//receive-version has to read promotions to check that a release doesn't go backwards, so it needs that table too
+	response, _ := service.ReceiveVersion(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"event":"newversion","buildId":12,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/12"}`,
+	})
+	if response.StatusCode != 500 {
+		t.Errorf("release should have returned 500 when promotions can't be read but got %d: %s", response.StatusCode, response.Body)
+	}
+	if stored, _ := store.GetRelease("test product", 12); stored != nil {
+		t.Errorf("release should not have been stored when promotions can't be read")
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+/**
+handler for GET /releases
+*/
+func (s *Service) ListReleases(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	query, parseErr := common.ReleaseQueryFromQuery(request.QueryStringParameters)
+	if parseErr != nil {
+		return events.APIGatewayProxyResponse{Body: parseErr.Error(), StatusCode: 400}, nil
+	}
+
+	validationErr := query.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	page, listErr := s.Store.ListReleases(query)
+	if listErr == common.ErrInvalidPageToken {
+		return events.APIGatewayProxyResponse{Body: listErr.Error(), StatusCode: 400}, nil
+	} else if listErr != nil {
+		log.Printf("Could not get data from database: %s", listErr)
+		return events.APIGatewayProxyResponse{Body: "Could not get info from database", StatusCode: 500}, nil
+	}
+
+	output, marshalErr := json.Marshal(page)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+/**
+handler for POST /rollout, which changes the percentage of clients that a release is offered to
+*/
+func (s *Service) SetRollout(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var rolloutReq common.RolloutRequest
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &rolloutReq)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not understand request body", StatusCode: 400}, nil
+	}
+
+	validationErr := rolloutReq.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	release, rolloutErr := common.SetRolloutPercentage(s.Store, rolloutReq.ProductName, rolloutReq.BuildId, rolloutReq.Branch, *rolloutReq.RolloutPercentage)
+	if rolloutErr != nil {
+		log.Printf("Could not change rollout: %s", rolloutErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+	if release == nil {
+		return events.APIGatewayProxyResponse{Body: "Nothing found for product and buildId on the branch", StatusCode: 404}, nil
+	}
+
+	output, marshalErr := json.Marshal(release)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
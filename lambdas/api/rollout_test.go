@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+/**
+find a client identifier that lands inside (or outside) the first percentage buckets for the product
+*/
+func clientInRollout(productName string, percentage int, inside bool) string {
+	for i := 0; ; i++ {
+		clientId := fmt.Sprintf("client-%d", i)
+		if (common.RolloutBucket(productName, clientId) < percentage) == inside {
+			return clientId
+		}
+	}
+}
+
+func TestService_SetRollout(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 11, Branch: "master", DownloadUrl: "https://some/url/11", ProductName: "test product"})
+
+	response, _ := service.SetRollout(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":11,"rolloutPercentage":25}`,
+	})
+	if response.StatusCode != 200 || !strings.Contains(response.Body, `"rolloutPercentage":25`) {
+		t.Fatalf("rollout should have returned 200 with the updated release but got %d: %s", response.StatusCode, response.Body)
+	}
+
+	tests := []struct {
+		clientId      string
+		expectedBuild string
+	}{
+		{clientInRollout("test product", 25, true), `"buildId":11`},
+		{clientInRollout("test product", 25, false), `"buildId":10`},
+		{"", `"buildId":10`},
+	}
+	for _, test := range tests {
+		lookup, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "clientId": test.clientId},
+		})
+		if !strings.Contains(lookup.Body, test.expectedBuild) {
+			t.Errorf("lookup for client '%s' should have returned %s but got %s", test.clientId, test.expectedBuild, lookup.Body)
+		}
+	}
+
+	missingPercentage, _ := service.SetRollout(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":11}`,
+	})
+	if missingPercentage.StatusCode != 400 {
+		t.Errorf("rollout without a percentage should have returned 400 but got %d", missingPercentage.StatusCode)
+	}
+
+	missing, _ := service.SetRollout(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":99,"rolloutPercentage":50}`,
+	})
+	if missing.StatusCode != 404 {
+		t.Errorf("rollout of a missing release should have returned 404 but got %d", missing.StatusCode)
+	}
+}
+
+func TestService_SetRolloutPromoted(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+	original, _ := store.GetRelease("test product", 12)
+	common.Promote(store, original, "master", "qa-team", nil)
+
+	response, _ := service.SetRollout(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":12,"branch":"master","rolloutPercentage":25}`,
+	})
+	if response.StatusCode != 200 || !strings.Contains(response.Body, `"branch":"master"`) || !strings.Contains(response.Body, `"rolloutPercentage":25`) {
+		t.Fatalf("rollout of the promoted copy should have returned 200 with the copy but got %d: %s", response.StatusCode, response.Body)
+	}
+	promoted, _ := store.GetPromotion("test product", "master", 12)
+	if promoted.RolloutPercentage == nil || *promoted.RolloutPercentage != 25 {
+		t.Errorf("rollout did not change the promoted copy")
+	}
+
+	notPromoted, _ := service.SetRollout(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":12,"branch":"develop","rolloutPercentage":25}`,
+	})
+	if notPromoted.StatusCode != 404 {
+		t.Errorf("rollout on a branch the build wasn't promoted to should have returned 404 but got %d", notPromoted.StatusCode)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/verify"
+	"net/url"
+	"os"
+	"time"
+)
+
+/**
+Service holds everything that the request handlers need. The handler methods all have the signature that
+lambda.Start expects, so each lambda function is just a Service with one of its methods started, and
+cmd/versions-server can serve the same methods over plain HTTP.
+*/
+type Service struct {
+	Store common.ReleaseStore
+	//where per-branch settings such as the minimum supported build are kept, nil if there is nowhere
+	Settings common.SettingsStore
+	//where release channels are kept, nil if there is nowhere
+	Channels common.ChannelStore
+	//where promoted copies of releases are kept, nil if there is nowhere
+	Promotions common.PromotionStore
+	//where per-product configuration such as the default branch is kept, nil if there is nowhere
+	Products common.ProductStore
+	//where download statistics are kept, nil if there is nowhere
+	Downloads common.DownloadStore
+	//checks that a download URL is reachable (and the right size, if expectedSize is not 0) before a release is logged.
+	//this is TestUploadedContent unless a test replaces it
+	VerifyContent func(uploadUrl string, expectedSize int64) bool
+	//if set, lookup responses are signed with this key
+	SigningKey ed25519.PrivateKey
+}
+
+/**
+set up a Service for the given store. If the store can also hold BranchSettings, Channels, promotions, Products and
+download counts (all the stores in common can) then it is used for those too.
+*/
+func NewService(store common.ReleaseStore) *Service {
+	settings, _ := store.(common.SettingsStore)
+	channels, _ := store.(common.ChannelStore)
+	promotions, _ := store.(common.PromotionStore)
+	products, _ := store.(common.ProductStore)
+	downloads, _ := store.(common.DownloadStore)
+	return &Service{
+		Store:         store,
+		Settings:      settings,
+		Channels:      channels,
+		Promotions:    promotions,
+		Products:      products,
+		Downloads:     downloads,
+		VerifyContent: TestUploadedContent,
+	}
+}
+
+/**
+set up a Service for a lambda function, using the DynamoDB tables in DYNAMO_TABLE_NAME, BRANCHES_TABLE_NAME,
+CHANNELS_TABLE_NAME, PROMOTIONS_TABLE_NAME, PRODUCTS_TABLE_NAME and DOWNLOADS_TABLE_NAME and the signing key in
+SIGNING_KEY (if there is one)
+*/
+func NewServiceFromEnvironment() (*Service, error) {
+	service := NewService(common.NewDynamoStoreFromEnvironment())
+
+	if encodedKey := os.Getenv("SIGNING_KEY"); encodedKey != "" {
+		signingKey, keyErr := common.LoadSigningKey(encodedKey)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		service.SigningKey = signingKey
+	}
+	return service, nil
+}
+
+/**
+the query string of a request, with every value of parameters that were given more than once
+*/
+func requestQuery(request events.APIGatewayProxyRequest) url.Values {
+	if len(request.MultiValueQueryStringParameters) > 0 {
+		return url.Values(request.MultiValueQueryStringParameters)
+	}
+	query := make(url.Values, len(request.QueryStringParameters))
+	for name, value := range request.QueryStringParameters {
+		query.Set(name, value)
+	}
+	return query
+}
+
+/**
+add a detached signature of the response to the response headers, if we have a signing key. The signature covers
+the request and the time as well as the body, see verify.Payload, so that the response can't be replayed as the
+answer to another request or long after it was made.
+*/
+func (s *Service) sign(request events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	if s.SigningKey == nil {
+		return response
+	}
+
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	signedRequest := verify.CanonicalRequest(request.Path, requestQuery(request))
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	response.Headers[verify.SignatureHeader] = common.SignPayload(s.SigningKey, verify.Payload(signedRequest, timestamp, []byte(response.Body)))
+	response.Headers[verify.RequestHeader] = signedRequest
+	response.Headers[verify.TimestampHeader] = timestamp
+	response.Headers[verify.KeyIdHeader] = verify.KeyId(s.SigningKey.Public().(ed25519.PublicKey))
+	return response
+}
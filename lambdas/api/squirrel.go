@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+	"strings"
+)
+
+/**
+Squirrel.Windows fetches RELEASES from under the update URL it is given, so /lookup/RELEASES answers in its format
+without needing a format parameter
+*/
+func requestedFormat(searchReq *common.SearchRequest, request events.APIGatewayProxyRequest) string {
+	if searchReq.Format == "" && strings.HasSuffix(request.Path, "/RELEASES") {
+		return common.FormatSquirrelWindows
+	}
+	return searchReq.Format
+}
+
+/**
+answer a lookup in one of the Squirrel formats. release is the newest release for the client's platform.
+Squirrel.Mac is told there is no update with a 204 if the client gave its currentBuildId and is running release (or
+something newer); Squirrel.Windows works that out for itself from the RELEASES file.
+*/
+func (s *Service) squirrelResponse(searchReq *common.SearchRequest, release *common.NewReleaseEvent) events.APIGatewayProxyResponse {
+	if searchReq.Format == common.FormatSquirrelMac {
+		if searchReq.CurrentBuildId != nil && common.ClientStatus(release, *searchReq.CurrentBuildId, searchReq.CurrentSemver) != common.StatusUpdateAvailable {
+			return events.APIGatewayProxyResponse{StatusCode: 204}
+		}
+		output, marshalErr := json.Marshal(common.NewSquirrelMacUpdate(release, searchReq.Lang))
+		if marshalErr != nil {
+			log.Printf("Could not marshal final results: %s", marshalErr)
+			return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}
+		}
+		return events.APIGatewayProxyResponse{
+			Body:       string(output),
+			StatusCode: 200,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+		}
+	}
+
+	line, lineErr := common.SquirrelReleasesLine(release)
+	if lineErr != nil {
+		log.Printf("Could not build RELEASES file: %s", lineErr)
+		return events.APIGatewayProxyResponse{Body: lineErr.Error(), StatusCode: 404}
+	}
+	return events.APIGatewayProxyResponse{
+		Body:       line + "\n",
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_LookupVersionSquirrel(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.LogRelease(&common.NewReleaseEvent{BuildId: 10, Branch: "master", ProductName: "test product", Semver: "1.2.0", Timestamp: "2019-11-01T10:00:00Z", Artifacts: []common.Artifact{
+		{Os: "macos", Url: "https://some/url/app-1.2.0.zip"},
+		{Os: "windows", Arch: "x64", Url: "https://some/url/TestProduct-1.2.0-full.nupkg", Size: 1234, Sha1: "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"},
+		{Os: "windows", Arch: "x86", Url: "https://some/url/TestProduct-1.2.0-ia32-full.nupkg"},
+	}})
+	service := NewService(store)
+
+	releases, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		Path:                  "/lookup/RELEASES",
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "arch": "amd64", "id": "TestProduct", "localVersion": "1.1.0"},
+	})
+	if releases.StatusCode != 200 || releases.Body != "A94A8FE5CCB19BA61C4C0873D391E987982FBBD3 https://some/url/TestProduct-1.2.0-full.nupkg 1234\n" {
+		t.Errorf("RELEASES lookup returned the wrong response: %d %s", releases.StatusCode, releases.Body)
+	}
+
+	noSha1, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "arch": "x86", "format": "squirrel-windows"},
+	})
+	if noSha1.StatusCode != 404 {
+		t.Errorf("RELEASES lookup of a package without a sha1 should have returned 404 but got %d", noSha1.StatusCode)
+	}
+
+	mac, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "format": "squirrel-mac", "currentBuildId": "9"},
+	})
+	if mac.StatusCode != 200 || mac.Body != `{"url":"https://some/url/app-1.2.0.zip","name":"1.2.0","pub_date":"2019-11-01T10:00:00Z"}` {
+		t.Errorf("Squirrel.Mac lookup of an older client returned the wrong response: %d %s", mac.StatusCode, mac.Body)
+	}
+
+	current, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "format": "squirrel-mac", "currentBuildId": "10"},
+	})
+	if current.StatusCode != 204 || current.Body != "" {
+		t.Errorf("Squirrel.Mac lookup of a current client should have returned 204 but got %d %s", current.StatusCode, current.Body)
+	}
+
+	invalid, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master", "format": "nuget"},
+	})
+	if invalid.StatusCode != 400 || !strings.Contains(invalid.Body, "format must be") {
+		t.Errorf("lookup with an unknown format should have returned 400 but got %d %s", invalid.StatusCode, invalid.Body)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"log"
+)
+
+/**
+handler for POST /withdraw, which stops a bad release from being offered to clients
+*/
+func (s *Service) WithdrawRelease(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var withdrawReq common.WithdrawRequest
+	unmarshalErr := json.Unmarshal([]byte(request.Body), &withdrawReq)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal request body: %s", unmarshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not understand request body", StatusCode: 400}, nil
+	}
+
+	validationErr := withdrawReq.Validate()
+	if validationErr != nil {
+		return events.APIGatewayProxyResponse{Body: validationErr.Error(), StatusCode: 400}, nil
+	}
+
+	release, withdrawErr := common.WithdrawRelease(s.Store, withdrawReq.ProductName, withdrawReq.BuildId, withdrawReq.Reason)
+	if withdrawErr != nil {
+		log.Printf("Could not withdraw release: %s", withdrawErr)
+		return events.APIGatewayProxyResponse{Body: "Could not communicate with database", StatusCode: 500}, nil
+	}
+	if release == nil {
+		return events.APIGatewayProxyResponse{Body: "Nothing found for product and buildId", StatusCode: 404}, nil
+	}
+
+	output, marshalErr := json.Marshal(release)
+	if marshalErr != nil {
+		log.Printf("Could not marshal final results: %s", marshalErr)
+		return events.APIGatewayProxyResponse{Body: "Could not marshal final response", StatusCode: 500}, nil
+	}
+	return events.APIGatewayProxyResponse{Body: string(output), StatusCode: 200}, nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"strings"
+	"testing"
+)
+
+func TestService_WithdrawRelease(t *testing.T) {
+	store := common.NewMemoryStore()
+	service := NewService(store)
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&common.NewReleaseEvent{Event: "newversion", BuildId: 11, Branch: "master", DownloadUrl: "https://some/url/11", ProductName: "test product"})
+
+	response, _ := service.WithdrawRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":11,"reason":"corrupt installer"}`,
+	})
+	if response.StatusCode != 200 {
+		t.Fatalf("withdraw should have returned 200 but got %d: %s", response.StatusCode, response.Body)
+	}
+	if !strings.Contains(response.Body, `"withdrawn":true`) || !strings.Contains(response.Body, `"withdrawnReason":"corrupt installer"`) {
+		t.Errorf("withdraw should have returned the withdrawn release but got %s", response.Body)
+	}
+
+	lookup, _ := service.LookupVersion(context.Background(), events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"productName": "test product", "branch": "master"},
+	})
+	if !strings.Contains(lookup.Body, `"buildId":10`) {
+		t.Errorf("lookup should have fallen back to the previous release but got %s", lookup.Body)
+	}
+
+	missing, _ := service.WithdrawRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":99,"reason":"corrupt installer"}`,
+	})
+	if missing.StatusCode != 404 {
+		t.Errorf("withdraw of a missing release should have returned 404 but got %d", missing.StatusCode)
+	}
+
+	noReason, _ := service.WithdrawRelease(context.Background(), events.APIGatewayProxyRequest{
+		Body: `{"productName":"test product","buildId":10}`,
+	})
+	if noReason.StatusCode != 400 {
+		t.Errorf("withdraw without a reason should have returned 400 but got %d", noReason.StatusCode)
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/api"
+	"log"
+)
+
+func main() {
+	service, setupErr := api.NewServiceFromEnvironment()
+	if setupErr != nil {
+		log.Fatalf("Could not set up service: %s", setupErr)
+	}
+	lambda.Start(service.Appcast)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/verify"
+	"log"
+)
+
+/**
+generate a new Ed25519 key pair for signing lookup responses.
+the private key goes into the SIGNING_KEY configuration of the service and the public key is given to client apps
+*/
+func main() {
+	publicKey, privateKey, genErr := ed25519.GenerateKey(nil)
+	if genErr != nil {
+		log.Fatalf("Could not generate key: %s", genErr)
+	}
+
+	fmt.Printf("Private key (keep this secret): %s\n", base64.StdEncoding.EncodeToString(privateKey.Seed()))
+	fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(publicKey))
+	fmt.Printf("Key id: %s\n", verify.KeyId(publicKey))
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+/**
+the signature of the handler methods on api.Service
+*/
+type LambdaHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+/**
+an http.Handler that serves one endpoint by converting the request into the form that API Gateway would
+send to the lambda function and converting the response back again
+*/
+type Endpoint struct {
+	Method  string
+	Handler LambdaHandler
+	//if set then the request must carry one of these in the x-api-key header, as API Gateway would check
+	ApiKeys []string
+	//optional API Gateway resource paths with path parameters, e.g. /feed/{productName}/{branch+}, that the endpoint
+	//serves. The first one that matches fills in the path parameters, and requests that match none get a 404.
+	Resources []string
+}
+
+/**
+an http.Handler for a path that supports more than one method, such as /channels, with an Endpoint for each method
+*/
+type MethodRouter map[string]*Endpoint
+
+func (m MethodRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint, haveEndpoint := m[r.Method]
+	if !haveEndpoint {
+		allowed := make([]string, 0, len(m))
+		for method := range m {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	endpoint.ServeHTTP(w, r)
+}
+
+/**
+returns true if the request carries one of the configured API keys
+*/
+func (e *Endpoint) Authorised(r *http.Request) bool {
+	provided := []byte(r.Header.Get("x-api-key"))
+	if len(provided) == 0 {
+		return false
+	}
+	for _, key := range e.ApiKeys {
+		if subtle.ConstantTimeCompare(provided, []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+match an escaped request path against an API Gateway resource path. {name} matches a single path segment and {name+}
+matches all the remaining segments, as API Gateway's greedy path variables do. The parameters are unescaped after
+the path is split, so a %2F in a segment doesn't start a new one.
+returns the path parameters, and false if the path doesn't match
+*/
+func MatchResource(resource string, path string) (map[string]string, bool) {
+	resourceParts := strings.Split(strings.Trim(resource, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	params := make(map[string]string)
+	for i, part := range resourceParts {
+		if i >= len(pathParts) || pathParts[i] == "" {
+			return nil, false
+		}
+		isParam := strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+		if isParam && strings.HasSuffix(part, "+}") {
+			value, unescapeErr := url.PathUnescape(strings.Join(pathParts[i:], "/"))
+			if unescapeErr != nil {
+				return nil, false
+			}
+			params[part[1:len(part)-2]] = value
+			return params, true
+		} else if isParam {
+			value, unescapeErr := url.PathUnescape(pathParts[i])
+			if unescapeErr != nil {
+				return nil, false
+			}
+			params[part[1:len(part)-1]] = value
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	if len(pathParts) != len(resourceParts) {
+		return nil, false
+	}
+	return params, true
+}
+
+/**
+convert an incoming HTTP request into an API Gateway proxy request
+*/
+func ToProxyRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, readErr := ioutil.ReadAll(r.Body)
+	if readErr != nil {
+		return events.APIGatewayProxyRequest{}, readErr
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Resource:                        r.URL.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         make(map[string]string, len(r.Header)),
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: r.URL.Query(),
+		Body:                            string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  fmt.Sprintf("%d", time.Now().UnixNano()),
+			HTTPMethod: r.Method,
+		},
+	}
+
+	for name, values := range r.Header {
+		request.Headers[name] = values[0]
+	}
+	for name, values := range r.URL.Query() {
+		request.QueryStringParameters[name] = values[0]
+	}
+	//API Gateway sends nil rather than an empty map when there is no query string
+	if len(request.QueryStringParameters) == 0 {
+		request.QueryStringParameters = nil
+	}
+	return request, nil
+}
+
+/**
+write an API Gateway proxy response out to the client
+*/
+func WriteProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, decodeErr := base64.StdEncoding.DecodeString(response.Body)
+		if decodeErr != nil {
+			log.Printf("Could not decode base64 response body: %s", decodeErr)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body = decoded
+	}
+
+	w.WriteHeader(response.StatusCode)
+	w.Write(body)
+}
+
+func (e *Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != e.Method {
+		w.Header().Set("Allow", e.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if e.ApiKeys != nil && !e.Authorised(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	request, convertErr := ToProxyRequest(r)
+	if convertErr != nil {
+		log.Printf("Could not read request body: %s", convertErr)
+		http.Error(w, "Could not read request body", http.StatusBadRequest)
+		return
+	}
+	if e.ApiKeys != nil {
+		//API Gateway passes the key that it checked on to the handler, so that it can tell who called
+		request.RequestContext.Identity.APIKey = r.Header.Get("x-api-key")
+	}
+
+	if e.Resources != nil {
+		matched := false
+		for _, resource := range e.Resources {
+			if params, matches := MatchResource(resource, r.URL.EscapedPath()); matches {
+				request.Resource = resource
+				request.PathParameters = params
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	response, handlerErr := e.Handler(r.Context(), request)
+	if handlerErr != nil {
+		log.Printf("%s %s returned an error: %s", r.Method, r.URL.Path, handlerErr)
+		//API Gateway would send a 502 for any error, we pass on the handler's response if it made one
+		if response.StatusCode == 0 {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	WriteProxyResponse(w, response)
+}
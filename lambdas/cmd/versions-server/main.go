@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/api"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+/**
+set up the requested storage backend. tables gives the table names to use with the dynamo backend, its Client is
+filled in here.
+*/
+func OpenStore(backend string, dbPath string, tables common.DynamoStore) (common.ReleaseStore, error) {
+	switch backend {
+	case "memory":
+		return common.NewMemoryStore(), nil
+	case "bolt":
+		return common.NewBoltStore(dbPath)
+	case "dynamo":
+		sess := session.Must(session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+		tables.Client = dynamodb.New(sess)
+		return &tables, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend '%s', expected memory, bolt or dynamo", backend)
+	}
+}
+
+/**
+build the routing table, mirroring the paths that are set up in API Gateway
+*/
+func NewRouter(service *api.Service, apiKeys []string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/lookup", &Endpoint{Method: http.MethodGet, Handler: service.LookupVersion})
+	mux.Handle("/lookup/RELEASES", &Endpoint{Method: http.MethodGet, Handler: service.LookupVersion})
+	mux.Handle("/releases", &Endpoint{Method: http.MethodGet, Handler: service.ListReleases})
+	mux.Handle("/appcast", &Endpoint{Method: http.MethodGet, Handler: service.Appcast})
+	mux.Handle("/electron/", &Endpoint{Method: http.MethodGet, Handler: service.ElectronUpdates})
+	mux.Handle("/feed/", &Endpoint{
+		Method:    http.MethodGet,
+		Handler:   service.ReleaseFeed,
+		Resources: []string{"/feed/{productName}", "/feed/{productName}/{branch+}"},
+	})
+	mux.Handle("/download/", &Endpoint{
+		Method:    http.MethodGet,
+		Handler:   service.Download,
+		Resources: []string{"/download/{productName}/{branch+}"},
+	})
+	mux.Handle("/newversion", &Endpoint{Method: http.MethodPost, Handler: service.ReceiveVersion, ApiKeys: apiKeys})
+	mux.Handle("/branchsettings", &Endpoint{Method: http.MethodPost, Handler: service.UpdateBranchSettings, ApiKeys: apiKeys})
+	mux.Handle("/withdraw", &Endpoint{Method: http.MethodPost, Handler: service.WithdrawRelease, ApiKeys: apiKeys})
+	mux.Handle("/rollout", &Endpoint{Method: http.MethodPost, Handler: service.SetRollout, ApiKeys: apiKeys})
+	mux.Handle("/promote", &Endpoint{Method: http.MethodPost, Handler: service.PromoteRelease, ApiKeys: apiKeys})
+	mux.Handle("/downloads", &Endpoint{Method: http.MethodGet, Handler: service.DownloadStats, ApiKeys: apiKeys})
+	mux.Handle("/channels", MethodRouter{
+		http.MethodGet:    &Endpoint{Method: http.MethodGet, Handler: service.ListChannels, ApiKeys: apiKeys},
+		http.MethodPost:   &Endpoint{Method: http.MethodPost, Handler: service.PutChannel, ApiKeys: apiKeys},
+		http.MethodDelete: &Endpoint{Method: http.MethodDelete, Handler: service.DeleteChannel, ApiKeys: apiKeys},
+	})
+	mux.Handle("/products", MethodRouter{
+		http.MethodGet:  &Endpoint{Method: http.MethodGet, Handler: service.GetProduct, ApiKeys: apiKeys},
+		http.MethodPost: &Endpoint{Method: http.MethodPost, Handler: service.PutProduct, ApiKeys: apiKeys},
+	})
+	return mux
+}
+
+func main() {
+	var listenAddress = flag.String("listen", ":8080", "Address and port to listen on")
+	var backend = flag.String("backend", "bolt", "Storage backend to use, one of memory, bolt or dynamo")
+	var dbPath = flag.String("db", "versions.db", "Database file to use with the bolt backend")
+	var tableName = flag.String("table", os.Getenv("DYNAMO_TABLE_NAME"), "Table name to use with the dynamo backend")
+	var branchesTableName = flag.String("branches-table", os.Getenv("BRANCHES_TABLE_NAME"), "Table name for branch settings with the dynamo backend")
+	var channelsTableName = flag.String("channels-table", os.Getenv("CHANNELS_TABLE_NAME"), "Table name for channels with the dynamo backend")
+	var promotionsTableName = flag.String("promotions-table", os.Getenv("PROMOTIONS_TABLE_NAME"), "Table name for promoted builds with the dynamo backend")
+	var productsTableName = flag.String("products-table", os.Getenv("PRODUCTS_TABLE_NAME"), "Table name for product configuration with the dynamo backend")
+	var downloadsTableName = flag.String("downloads-table", os.Getenv("DOWNLOADS_TABLE_NAME"), "Table name for download counts with the dynamo backend")
+	var semverIndex = flag.Bool("semver-index", os.Getenv("SEMVER_INDEX_ENABLED") == "true", "Order by semver using the productBranch-semverKey-index index of the dynamo backend's table")
+	var signingKey = flag.String("signing-key", os.Getenv("SIGNING_KEY"), "Base64-encoded Ed25519 private key to sign lookup responses with")
+	var apiKeyList = flag.String("api-keys", os.Getenv("VERSIONS_API_KEYS"), "Comma-separated list of API keys that are allowed to call the protected endpoints")
+	flag.Parse()
+
+	var apiKeys []string
+	for _, key := range strings.Split(*apiKeyList, ",") {
+		if strings.TrimSpace(key) != "" {
+			apiKeys = append(apiKeys, strings.TrimSpace(key))
+		}
+	}
+	if len(apiKeys) == 0 {
+		println("You must specify at least one API key in the --api-keys argument or the VERSIONS_API_KEYS environment variable")
+		os.Exit(1)
+	}
+
+	if *backend == "dynamo" && *tableName == "" {
+		println("You must specify a table name in the --table argument or the DYNAMO_TABLE_NAME environment variable to use the dynamo backend")
+		os.Exit(1)
+	}
+	if *backend == "dynamo" && *branchesTableName == "" {
+		println("You must specify a table name in the --branches-table argument or the BRANCHES_TABLE_NAME environment variable to use the dynamo backend")
+		os.Exit(1)
+	}
+	if *backend == "dynamo" && *channelsTableName == "" {
+		println("You must specify a table name in the --channels-table argument or the CHANNELS_TABLE_NAME environment variable to use the dynamo backend")
+		os.Exit(1)
+	}
+	if *backend == "dynamo" && *promotionsTableName == "" {
+		println("You must specify a table name in the --promotions-table argument or the PROMOTIONS_TABLE_NAME environment variable to use the dynamo backend")
+		os.Exit(1)
+	}
+	if *backend == "dynamo" && *productsTableName == "" {
+		println("You must specify a table name in the --products-table argument or the PRODUCTS_TABLE_NAME environment variable to use the dynamo backend")
+		os.Exit(1)
+	}
+	if *backend == "dynamo" && *downloadsTableName == "" {
+		println("You must specify a table name in the --downloads-table argument or the DOWNLOADS_TABLE_NAME environment variable to use the dynamo backend")
+		os.Exit(1)
+	}
+
+	store, storeErr := OpenStore(*backend, *dbPath, common.DynamoStore{
+		TableName:           *tableName,
+		BranchesTableName:   *branchesTableName,
+		ChannelsTableName:   *channelsTableName,
+		PromotionsTableName: *promotionsTableName,
+		ProductsTableName:   *productsTableName,
+		DownloadsTableName:  *downloadsTableName,
+		SemverIndexEnabled:  *semverIndex,
+	})
+	if storeErr != nil {
+		log.Fatalf("Could not open storage: %s", storeErr)
+	}
+	if closer, isCloser := store.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+
+	service := api.NewService(store)
+	if *signingKey != "" {
+		var keyErr error
+		service.SigningKey, keyErr = common.LoadSigningKey(*signingKey)
+		if keyErr != nil {
+			log.Fatalf("Could not load signing key: %s", keyErr)
+		}
+	}
+
+	log.Printf("Serving versions API from %s storage on %s", *backend, *listenAddress)
+	serveErr := http.ListenAndServe(*listenAddress, NewRouter(service, apiKeys))
+	if serveErr != nil {
+		log.Printf("Server stopped: %s", serveErr)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/api"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/common"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	store := common.NewMemoryStore()
+	store.PutProduct(&common.Product{ProductName: "test product", DefaultBranch: "master"})
+	service := api.NewService(store)
+	service.VerifyContent = func(uploadUrl string, expectedSize int64) bool { return true }
+
+	server := httptest.NewServer(NewRouter(service, []string{"secretkey"}))
+	defer server.Close()
+
+	newVersionBody := `{"event":"newversion","buildId":12,"branch":"master","productName":"test product","downloadUrl":"https://some.server.com/path/12","sha1":"a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"}`
+
+	unauthorised, _ := http.Post(server.URL+"/newversion", "application/json", strings.NewReader(newVersionBody))
+	if unauthorised.StatusCode != 403 {
+		t.Errorf("POST without an API key should have returned 403 but got %d", unauthorised.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/newversion", strings.NewReader(newVersionBody))
+	req.Header.Set("x-api-key", "secretkey")
+	created, _ := http.DefaultClient.Do(req)
+	if created.StatusCode != 201 {
+		t.Errorf("POST with an API key should have returned 201 but got %d", created.StatusCode)
+	}
+
+	lookup, _ := http.Get(server.URL + "/lookup?productName=test%20product&branch=master")
+	if lookup.StatusCode != 200 {
+		t.Errorf("lookup should have returned 200 but got %d", lookup.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(lookup.Body)
+	if !strings.Contains(string(body), `"buildId":12`) {
+		t.Errorf("lookup should have returned the new build but got %s", string(body))
+	}
+
+	releases, _ := http.Get(server.URL + "/lookup/RELEASES?productName=test%20product&branch=master&id=TestProduct&localVersion=1.0.0")
+	releasesBody, _ := ioutil.ReadAll(releases.Body)
+	if releases.StatusCode != 200 || string(releasesBody) != "A94A8FE5CCB19BA61C4C0873D391E987982FBBD3 https://some.server.com/path/12 0\n" {
+		t.Errorf("lookup/RELEASES should have returned a RELEASES file but got %d: %s", releases.StatusCode, string(releasesBody))
+	}
+
+	feed, _ := http.Get(server.URL + "/feed/test%20product/master")
+	feedBody, _ := ioutil.ReadAll(feed.Body)
+	if feed.StatusCode != 200 || !strings.Contains(string(feedBody), `<link href="https://some.server.com/path/12">`) {
+		t.Errorf("feed for the branch should have listed the new build but got %d: %s", feed.StatusCode, string(feedBody))
+	}
+	noFeed, _ := http.Get(server.URL + "/feed/")
+	if noFeed.StatusCode != 404 {
+		t.Errorf("feed without a product should have returned 404 but got %d", noFeed.StatusCode)
+	}
+
+	//don't follow the redirect, we want to see where it goes
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	download, _ := noRedirects.Get(server.URL + "/download/test%20product/master?os=windows&arch=x64")
+	if download.StatusCode != 302 || download.Header.Get("Location") != "https://some.server.com/path/12" {
+		t.Errorf("download should have redirected to the new build but got %d %v", download.StatusCode, download.Header)
+	}
+	if download.Header.Get("Cache-Control") != "private, max-age=300" {
+		t.Errorf("download should have carried a Cache-Control header but got %v", download.Header)
+	}
+	unauthorisedStats, _ := http.Get(server.URL + "/downloads?productName=test%20product&buildId=12")
+	if unauthorisedStats.StatusCode != 403 {
+		t.Errorf("download stats without an API key should have returned 403 but got %d", unauthorisedStats.StatusCode)
+	}
+	statsReq, _ := http.NewRequest(http.MethodGet, server.URL+"/downloads?productName=test%20product&buildId=12", nil)
+	statsReq.Header.Set("x-api-key", "secretkey")
+	stats, _ := http.DefaultClient.Do(statsReq)
+	statsBody, _ := ioutil.ReadAll(stats.Body)
+	if stats.StatusCode != 200 || !strings.Contains(string(statsBody), `"downloads":1`) {
+		t.Errorf("download stats should have counted the redirect but got %d: %s", stats.StatusCode, string(statsBody))
+	}
+	noDownload, _ := noRedirects.Get(server.URL + "/download/test%20product/master/windows")
+	if noDownload.StatusCode != 404 {
+		t.Errorf("download from the empty branch master/windows should have returned 404 but got %d", noDownload.StatusCode)
+	}
+
+	wrongMethod, _ := http.Post(server.URL+"/lookup", "application/json", strings.NewReader("{}"))
+	if wrongMethod.StatusCode != 405 {
+		t.Errorf("POST to lookup should have returned 405 but got %d", wrongMethod.StatusCode)
+	}
+
+	channelReq, _ := http.NewRequest(http.MethodPost, server.URL+"/channels", strings.NewReader(`{"productName":"test product","name":"stable","branches":["master"]}`))
+	channelReq.Header.Set("x-api-key", "secretkey")
+	channel, _ := http.DefaultClient.Do(channelReq)
+	if channel.StatusCode != 200 {
+		t.Errorf("POST to channels should have returned 200 but got %d", channel.StatusCode)
+	}
+
+	patchReq, _ := http.NewRequest(http.MethodPatch, server.URL+"/channels", strings.NewReader("{}"))
+	patchReq.Header.Set("x-api-key", "secretkey")
+	patch, _ := http.DefaultClient.Do(patchReq)
+	if patch.StatusCode != 405 || patch.Header.Get("Allow") != "DELETE, GET, POST" {
+		t.Errorf("PATCH to channels should have returned 405 with an Allow header but got %d, '%s'", patch.StatusCode, patch.Header.Get("Allow"))
+	}
+}
+
+func TestMatchResource(t *testing.T) {
+	tests := []struct {
+		resource string
+		path     string
+		params   map[string]string
+	}{
+		{"/feed/{productName}", "/feed/my%20product", map[string]string{"productName": "my product"}},
+		{"/feed/{productName}/{branch}", "/feed/myproduct/release%2F2.14", map[string]string{"productName": "myproduct", "branch": "release/2.14"}},
+		{"/feed/{productName}", "/feed/myproduct/master", nil},
+		{"/feed/{productName}/{branch+}", "/feed/myproduct/release/2.14", map[string]string{"productName": "myproduct", "branch": "release/2.14"}},
+		{"/feed/{productName}/{branch+}", "/feed/myproduct", nil},
+		{"/feed/{productName}", "/download/myproduct", nil},
+		{"/feed/{productName}", "/feed/", nil},
+	}
+	for _, test := range tests {
+		params, matches := MatchResource(test.resource, test.path)
+		if matches != (test.params != nil) || len(params) != len(test.params) {
+			t.Errorf("MatchResource(%s, %s) returned %v, %t", test.resource, test.path, params, matches)
+			continue
+		}
+		for name, value := range test.params {
+			if params[name] != value {
+				t.Errorf("MatchResource(%s, %s) returned %s for %s, expected %s", test.resource, test.path, params[name], name, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+//the most releases that are listed in an appcast. Sparkle only needs the newest few to decide whether to update.
+const MaxAppcastItems = 20
+
+const sparkleNamespace = "http://www.andymatuschak.org/xml-namespaces/sparkle"
+
+/**
+an RSS appcast in the format that the Sparkle update framework for macOS expects,
+see https://sparkle-project.org/documentation/publishing/
+*/
+type Appcast struct {
+	XMLName   xml.Name       `xml:"rss"`
+	Version   string         `xml:"version,attr"`
+	Namespace string         `xml:"xmlns:sparkle,attr"`
+	Channel   AppcastChannel `xml:"channel"`
+}
+
+type AppcastChannel struct {
+	Title string        `xml:"title"`
+	Items []AppcastItem `xml:"item"`
+}
+
+type AppcastItem struct {
+	Title              string `xml:"title"`
+	PubDate            string `xml:"pubDate,omitempty"`
+	Version            string `xml:"sparkle:version"`
+	ShortVersionString string `xml:"sparkle:shortVersionString,omitempty"`
+	ReleaseNotesLink   string `xml:"sparkle:releaseNotesLink,omitempty"`
+	//embedded HTML release notes, for releases that don't link to any
+	Description *AppcastDescription `xml:"description,omitempty"`
+	//present on mandatory releases
+	CriticalUpdate *struct{}        `xml:"sparkle:criticalUpdate,omitempty"`
+	Enclosure      AppcastEnclosure `xml:"enclosure"`
+}
+
+type AppcastDescription struct {
+	Html string `xml:",cdata"`
+}
+
+type AppcastEnclosure struct {
+	Url         string `xml:"url,attr"`
+	Length      int64  `xml:"length,attr"`
+	Type        string `xml:"type,attr"`
+	EdSignature string `xml:"sparkle:edSignature,attr,omitempty"`
+}
+
+/**
+build the appcast item for a release that has already been narrowed down to a single download with ForPlatform.
+If the release has no releaseNotesUrl then its notes for lang, if it has any, are embedded as HTML.
+*/
+func NewAppcastItem(release *NewReleaseEvent, lang string) (*AppcastItem, error) {
+	item := &AppcastItem{
+		Title:              release.ProductName + " " + release.Semver,
+		Version:            strconv.Itoa(release.BuildId),
+		ShortVersionString: release.Semver,
+		ReleaseNotesLink:   release.ReleaseNotesUrl,
+		Enclosure: AppcastEnclosure{
+			Url:         release.DownloadUrl,
+			Length:      release.Size,
+			Type:        "application/octet-stream",
+			EdSignature: release.EdSignature,
+		},
+	}
+	if release.Semver == "" {
+		item.Title = release.ProductName + " build " + item.Version
+	}
+	if timestamp, parseErr := time.Parse(time.RFC3339, release.Timestamp); parseErr == nil {
+		item.PubDate = timestamp.Format(time.RFC1123Z)
+	}
+	if release.Mandatory {
+		item.CriticalUpdate = &struct{}{}
+	}
+
+	if release.ReleaseNotesUrl == "" && len(release.ReleaseNotes) > 0 {
+		_, notes := release.ReleaseNotes.Select(lang)
+		html, renderErr := RenderNotesHTML(notes)
+		if renderErr != nil {
+			return nil, renderErr
+		}
+		item.Description = &AppcastDescription{Html: html}
+	}
+	return item, nil
+}
+
+/**
+render an appcast with the given title and items, newest first
+*/
+func RenderAppcast(title string, items []AppcastItem) ([]byte, error) {
+	appcast := Appcast{
+		Version:   "2.0",
+		Namespace: sparkleNamespace,
+		Channel:   AppcastChannel{Title: title, Items: items},
+	}
+	content, marshalErr := xml.MarshalIndent(appcast, "", "  ")
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return append([]byte(xml.Header), content...), nil
+}
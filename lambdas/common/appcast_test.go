@@ -0,0 +1,80 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewAppcastItem(t *testing.T) {
+	signature := strings.Repeat("A", 86) + "=="
+	release := NewReleaseEvent{
+		BuildId:         123,
+		ProductName:     "some product",
+		Semver:          "2.14.0",
+		DownloadUrl:     "https://someurl.server.com/app.zip",
+		Size:            1234,
+		EdSignature:     signature,
+		Timestamp:       "2019-11-01T10:00:00Z",
+		ReleaseNotesUrl: "https://someurl.server.com/notes/2.14.0.html",
+		Mandatory:       true,
+	}
+
+	item, err := NewAppcastItem(&release, "en")
+	if err != nil {
+		t.Fatalf("NewAppcastItem should have succeeded but got %s", err)
+	}
+	if item.Version != "123" || item.ShortVersionString != "2.14.0" || item.Title != "some product 2.14.0" {
+		t.Errorf("NewAppcastItem got the versions wrong: %v", item)
+	}
+	if item.PubDate != "Fri, 01 Nov 2019 10:00:00 +0000" {
+		t.Errorf("NewAppcastItem got the wrong pubDate: %s", item.PubDate)
+	}
+	if item.Enclosure.Url != release.DownloadUrl || item.Enclosure.Length != 1234 || item.Enclosure.EdSignature != signature {
+		t.Errorf("NewAppcastItem got the enclosure wrong: %v", item.Enclosure)
+	}
+	if item.ReleaseNotesLink != release.ReleaseNotesUrl || item.Description != nil || item.CriticalUpdate == nil {
+		t.Errorf("NewAppcastItem got the notes or critical update wrong: %v", item)
+	}
+
+	embedded := release
+	embedded.ReleaseNotesUrl = ""
+	embedded.ReleaseNotes = ReleaseNotes{"en": "## Fixed\n- crashes", "de": "## Behoben"}
+	embeddedItem, err := NewAppcastItem(&embedded, "de-DE")
+	if err != nil {
+		t.Fatalf("NewAppcastItem with notes should have succeeded but got %s", err)
+	}
+	if embeddedItem.Description == nil || embeddedItem.Description.Html != "<h2>Behoben</h2>\n" {
+		t.Errorf("NewAppcastItem should have embedded the German notes but got %v", embeddedItem.Description)
+	}
+}
+
+func TestRenderAppcast(t *testing.T) {
+	item, _ := NewAppcastItem(&NewReleaseEvent{
+		BuildId:      123,
+		ProductName:  "some product",
+		DownloadUrl:  "https://someurl.server.com/app.zip?a=1&b=2",
+		ReleaseNotes: ReleaseNotes{"en": "Fixed <crashes>"},
+	}, "")
+
+	output, err := RenderAppcast("Some Product", []AppcastItem{*item})
+	if err != nil {
+		t.Fatalf("RenderAppcast should have succeeded but got %s", err)
+	}
+	expected := []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<rss version="2.0" xmlns:sparkle="http://www.andymatuschak.org/xml-namespaces/sparkle">`,
+		`<title>Some Product</title>`,
+		`<title>some product build 123</title>`,
+		`<sparkle:version>123</sparkle:version>`,
+		`<description><![CDATA[<p>Fixed <!-- raw HTML omitted --></p>`,
+		`<enclosure url="https://someurl.server.com/app.zip?a=1&amp;b=2" length="0" type="application/octet-stream"></enclosure>`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(string(output), fragment) {
+			t.Errorf("appcast should have contained %s but got %s", fragment, output)
+		}
+	}
+	if strings.Contains(string(output), "shortVersionString") || strings.Contains(string(output), "criticalUpdate") {
+		t.Errorf("appcast should have left out the fields that the release doesn't have but got %s", output)
+	}
+}
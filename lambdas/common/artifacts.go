@@ -0,0 +1,176 @@
+package common
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/**
+a single downloadable file within a release, e.g. the macOS arm64 installer
+*/
+type Artifact struct {
+	Os       string `json:"os"`   //operating system, e.g. macos, windows or linux. Empty means any.
+	Arch     string `json:"arch"` //CPU architecture, e.g. x64 or arm64. Empty means any (a universal build).
+	Url      string `json:"url"`
+	Size     int64  `json:"size,omitempty"`     //in bytes
+	Checksum string `json:"checksum,omitempty"` //hex-encoded SHA-256 of the file
+	//optional hex-encoded SHA-1 of the file, which Squirrel.Windows needs in its RELEASES file
+	Sha1 string `json:"sha1,omitempty"`
+	//optional base64-encoded SHA-512 of the file, which electron-updater checks downloads against
+	Sha512 string `json:"sha512,omitempty"`
+	//optional base64-encoded EdDSA (ed25519) signature of the file, as made by Sparkle's sign_update tool
+	EdSignature string `json:"edSignature,omitempty"`
+}
+
+//common alternative spellings, so that clients can send whatever their runtime calls the platform
+var platformAliases = map[string]string{
+	"darwin":  "macos",
+	"osx":     "macos",
+	"mac":     "macos",
+	"win":     "windows",
+	"win32":   "windows",
+	"amd64":   "x64",
+	"x86_64":  "x64",
+	"aarch64": "arm64",
+	"i386":    "x86",
+	"ia32":    "x86",
+}
+
+/**
+lower-case an os or arch name and replace any alias with the usual name
+*/
+func NormalisePlatform(name string) string {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	if canonical, isAlias := platformAliases[lower]; isAlias {
+		return canonical
+	}
+	return lower
+}
+
+var checksumValidator = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+var sha1Validator = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+/**
+check that an artifact is well-formed. Os can be left out for a file that works on any platform, as FindArtifact
+treats it, but an arch only makes sense for a particular os.
+*/
+func (a *Artifact) Validate() error {
+	if a.Os == "" && a.Arch != "" {
+		return errors.New("artifact os must be specified if arch is")
+	}
+	if a.Url == "" {
+		return errors.New("artifact url must be specified")
+	}
+	if !urlValidator.MatchString(a.Url) {
+		return fmt.Errorf("artifact url %s does not look like a valid URL", a.Url)
+	}
+	if a.Size < 0 {
+		return fmt.Errorf("artifact size for %s can't be negative", a.Url)
+	}
+	if a.Checksum != "" && !checksumValidator.MatchString(a.Checksum) {
+		return fmt.Errorf("artifact checksum for %s must be a hex-encoded SHA-256", a.Url)
+	}
+	if a.Sha1 != "" && !sha1Validator.MatchString(a.Sha1) {
+		return fmt.Errorf("artifact sha1 for %s must be a hex-encoded SHA-1", a.Url)
+	}
+	if a.Sha512 != "" && !validSha512(a.Sha512) {
+		return fmt.Errorf("artifact sha512 for %s must be a base64-encoded SHA-512", a.Url)
+	}
+	if a.EdSignature != "" && !validEdSignature(a.EdSignature) {
+		return fmt.Errorf("artifact edSignature for %s must be a base64-encoded ed25519 signature", a.Url)
+	}
+	return nil
+}
+
+/**
+returns true if checksum is the base64 encoding of something the size of a SHA-512 hash, as electron-builder writes them
+*/
+func validSha512(checksum string) bool {
+	raw, decodeErr := base64.StdEncoding.DecodeString(checksum)
+	return decodeErr == nil && len(raw) == 64
+}
+
+/**
+returns true if signature is the base64 encoding of something the size of an ed25519 signature
+*/
+func validEdSignature(signature string) bool {
+	raw, decodeErr := base64.StdEncoding.DecodeString(signature)
+	return decodeErr == nil && len(raw) == 64
+}
+
+/**
+returns the artifacts of the release. A release that only has a DownloadUrl is treated as a single artifact
+that works on any platform, so that older build pipelines keep working.
+*/
+func (e *NewReleaseEvent) AllArtifacts() []Artifact {
+	if len(e.Artifacts) > 0 {
+		return e.Artifacts
+	}
+	if e.DownloadUrl == "" {
+		return nil
+	}
+	return []Artifact{{Url: e.DownloadUrl, Size: e.Size, Checksum: e.Sha256, Sha1: e.Sha1, Sha512: e.Sha512, EdSignature: e.EdSignature}}
+}
+
+/**
+find the best artifact for the given platform. An exact match is preferred, then one for the right os that works
+on any arch, then one that works anywhere.
+returns nil if there is nothing suitable
+*/
+func (e *NewReleaseEvent) FindArtifact(os string, arch string) *Artifact {
+	os = NormalisePlatform(os)
+	arch = NormalisePlatform(arch)
+
+	var bestMatch *Artifact
+	bestScore := 0
+	artifacts := e.AllArtifacts()
+	for i := range artifacts {
+		artifactOs := NormalisePlatform(artifacts[i].Os)
+		artifactArch := NormalisePlatform(artifacts[i].Arch)
+
+		if artifactOs != "" && artifactOs != os {
+			continue
+		}
+		if artifactArch != "" && arch != "" && artifactArch != arch {
+			continue
+		}
+
+		score := 1
+		if artifactOs != "" {
+			score += 2
+		}
+		if artifactArch != "" && artifactArch == arch {
+			score += 1
+		}
+		if score > bestScore {
+			bestMatch = &artifacts[i]
+			bestScore = score
+		}
+	}
+	return bestMatch
+}
+
+/**
+returns a copy of the release narrowed down to the artifact for the given platform, with DownloadUrl, Sha256, Sha1, Sha512, Size and EdSignature
+describing it so that clients which only understand those fields get the right file.
+returns nil if the release has nothing for the platform
+*/
+func (e *NewReleaseEvent) ForPlatform(os string, arch string) *NewReleaseEvent {
+	artifact := e.FindArtifact(os, arch)
+	if artifact == nil {
+		return nil
+	}
+
+	narrowed := *e
+	narrowed.Artifacts = []Artifact{*artifact}
+	narrowed.DownloadUrl = artifact.Url
+	narrowed.Sha256 = artifact.Checksum
+	narrowed.Sha1 = artifact.Sha1
+	narrowed.Sha512 = artifact.Sha512
+	narrowed.Size = artifact.Size
+	narrowed.EdSignature = artifact.EdSignature
+	return &narrowed
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+var multiPlatformRelease = NewReleaseEvent{
+	Event:       "test",
+	BuildId:     123,
+	Branch:      "master",
+	ProductName: "some product",
+	Artifacts: []Artifact{
+		{Os: "macos", Arch: "arm64", Url: "https://someurl.server.com/mac-arm64.dmg", Size: 5678, Checksum: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{Os: "macos", Arch: "x64", Url: "https://someurl.server.com/mac-x64.dmg"},
+		{Os: "windows", Url: "https://someurl.server.com/setup.exe"},
+		{Os: "linux", Arch: "x64", Url: "https://someurl.server.com/linux-x64.tar.gz"},
+	},
+}
+
+func TestNewReleaseEvent_FindArtifact(t *testing.T) {
+	tests := []struct {
+		os          string
+		arch        string
+		expectedUrl string
+	}{
+		{"macos", "arm64", "https://someurl.server.com/mac-arm64.dmg"},
+		{"darwin", "amd64", "https://someurl.server.com/mac-x64.dmg"},
+		{"MacOS", "X64", "https://someurl.server.com/mac-x64.dmg"},
+		{"windows", "x64", "https://someurl.server.com/setup.exe"},
+		{"windows", "arm64", "https://someurl.server.com/setup.exe"},
+		{"linux", "x64", "https://someurl.server.com/linux-x64.tar.gz"},
+		{"linux", "arm64", ""},
+		{"freebsd", "", ""},
+	}
+
+	for _, test := range tests {
+		artifact := multiPlatformRelease.FindArtifact(test.os, test.arch)
+		if test.expectedUrl == "" {
+			if artifact != nil {
+				t.Errorf("%s/%s should not have matched anything but got %s", test.os, test.arch, artifact.Url)
+			}
+		} else if artifact == nil {
+			t.Errorf("%s/%s should have matched %s but got nothing", test.os, test.arch, test.expectedUrl)
+		} else if artifact.Url != test.expectedUrl {
+			t.Errorf("%s/%s should have matched %s but got %s", test.os, test.arch, test.expectedUrl, artifact.Url)
+		}
+	}
+
+	legacyRelease := NewReleaseEvent{ProductName: "some product", DownloadUrl: "https://someurl.server.com/path", Size: 1234, Sha256: "abcd"}
+	legacyArtifact := legacyRelease.FindArtifact("linux", "x64")
+	if legacyArtifact == nil || legacyArtifact.Url != "https://someurl.server.com/path" {
+		t.Errorf("a release with only a downloadUrl should match any platform")
+	} else if legacyArtifact.Size != 1234 || legacyArtifact.Checksum != "abcd" {
+		t.Errorf("a release with only a downloadUrl should carry its size and checksum into the artifact")
+	}
+}
+
+func TestNewReleaseEvent_ForPlatform(t *testing.T) {
+	narrowed := multiPlatformRelease.ForPlatform("macos", "arm64")
+	if narrowed == nil {
+		t.Fatalf("ForPlatform should have found the macos arm64 artifact")
+	}
+	if len(narrowed.Artifacts) != 1 || narrowed.DownloadUrl != "https://someurl.server.com/mac-arm64.dmg" || narrowed.Size != 5678 || narrowed.Sha256 == "" {
+		t.Errorf("ForPlatform did not narrow down the release properly, got %v", narrowed)
+	}
+	if len(multiPlatformRelease.Artifacts) != 4 {
+		t.Errorf("ForPlatform should not have changed the original release")
+	}
+
+	if missing := multiPlatformRelease.ForPlatform("linux", "arm64"); missing != nil {
+		t.Errorf("ForPlatform should have returned nil for a platform with no artifact")
+	}
+}
+
+func TestArtifact_Validate(t *testing.T) {
+	a1 := Artifact{Os: "macos", Arch: "arm64", Url: "https://someurl.server.com/path", Size: 1234, Checksum: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+	if err := a1.Validate(); err != nil {
+		t.Errorf("Test failed to validate: got %s", err)
+	}
+
+	a2 := Artifact{Arch: "arm64", Url: "https://someurl.server.com/path"}
+	if err := a2.Validate(); err == nil {
+		t.Errorf("Validation on an arch without an os should have failed but it succeeded")
+	}
+
+	anyPlatform := Artifact{Url: "https://someurl.server.com/path"}
+	if err := anyPlatform.Validate(); err != nil {
+		t.Errorf("Artifact without an os should have validated as working on any platform: got %s", err)
+	}
+
+	a3 := Artifact{Os: "macos", Url: "malformedurl!"}
+	if err := a3.Validate(); err == nil {
+		t.Errorf("Validation on malformed URL should have failed but it succeeded")
+	}
+
+	a4 := Artifact{Os: "macos", Url: "https://someurl.server.com/path", Checksum: "abc123"}
+	if err := a4.Validate(); err == nil {
+		t.Errorf("Validation on malformed checksum should have failed but it succeeded")
+	}
+
+	a5 := Artifact{Os: "macos", Url: "https://someurl.server.com/path", EdSignature: strings.Repeat("A", 86) + "=="}
+	if err := a5.Validate(); err != nil {
+		t.Errorf("Test with an edSignature failed to validate: got %s", err)
+	}
+
+	a6 := Artifact{Os: "macos", Url: "https://someurl.server.com/path", EdSignature: "c2lnbmF0dXJl"}
+	if err := a6.Validate(); err == nil {
+		t.Errorf("Validation on an edSignature of the wrong length should have failed but it succeeded")
+	}
+
+	a7 := Artifact{Os: "windows", Url: "https://someurl.server.com/path", Sha1: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+	if err := a7.Validate(); err == nil {
+		t.Errorf("Validation on malformed sha1 should have failed but it succeeded")
+	}
+
+	a8 := Artifact{Os: "windows", Url: "https://someurl.server.com/path", Sha512: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+	if err := a8.Validate(); err == nil {
+		t.Errorf("Validation on a hex sha512 should have failed but it succeeded")
+	}
+}
@@ -0,0 +1,538 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"log"
+	"time"
+)
+
+var releasesBucket = []byte("releases")
+var branchesBucket = []byte("branches")
+var channelsBucket = []byte("channels")
+var promotionsBucket = []byte("promotions")
+var productsBucket = []byte("products")
+var downloadsBucket = []byte("downloads")
+
+/**
+ReleaseStore implementation backed by a BoltDB file, for running the service on a single machine without AWS.
+Releases live in a bucket per product inside the "releases" bucket, keyed by buildId and stored as JSON.
+BranchSettings live in the "branches" bucket, keyed by ProductBranchKey, and Channels live in a bucket per product
+inside the "channels" bucket, keyed by name. Promoted copies live in a bucket per ProductBranchKey inside the
+"promotions" bucket, keyed by buildId. Products live in the "products" bucket, keyed by name, and download counts
+live in a bucket per product inside the "downloads" bucket, keyed by buildId and platform.
+*/
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+/**
+open (or create) the BoltDB file at the given path. Only one process can have the file open at once.
+*/
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, openErr := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if openErr != nil {
+		log.Printf("Could not open database file %s: %s", path, openErr)
+		return nil, openErr
+	}
+
+	initErr := db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(releasesBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(branchesBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(channelsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(promotionsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(productsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(downloadsBucket)
+		return err
+	})
+	if initErr != nil {
+		log.Printf("Could not initialise database file %s: %s", path, initErr)
+		db.Close()
+		return nil, initErr
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+/**
+encode a buildId as a key that sorts in numeric order, including negative numbers
+*/
+func buildIdKey(buildId int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(buildId)^(1<<63))
+	return key
+}
+
+/**
+returns every release of productName, newest first
+*/
+func boltReleasesFor(tx *bbolt.Tx, productName string) ([]NewReleaseEvent, error) {
+	result := make([]NewReleaseEvent, 0)
+	productBucket := tx.Bucket(releasesBucket).Bucket([]byte(productName))
+	if productBucket == nil {
+		return result, nil
+	}
+
+	cursor := productBucket.Cursor()
+	for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+		var ev NewReleaseEvent
+		unmarshalErr := json.Unmarshal(v, &ev)
+		if unmarshalErr != nil {
+			log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+			return nil, unmarshalErr
+		}
+		result = append(result, ev)
+	}
+	return result, nil
+}
+
+func (s *BoltStore) LogRelease(ev *NewReleaseEvent) error {
+	content, marshalErr := json.Marshal(ev)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data for database: %s", marshalErr)
+		return marshalErr
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		productBucket, bucketErr := tx.Bucket(releasesBucket).CreateBucketIfNotExists([]byte(ev.ProductName))
+		if bucketErr != nil {
+			return bucketErr
+		}
+		return productBucket.Put(buildIdKey(ev.BuildId), content)
+	})
+}
+
+func (s *BoltStore) CreateRelease(ev *NewReleaseEvent) error {
+	content, marshalErr := json.Marshal(ev)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data for database: %s", marshalErr)
+		return marshalErr
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		productBucket, bucketErr := tx.Bucket(releasesBucket).CreateBucketIfNotExists([]byte(ev.ProductName))
+		if bucketErr != nil {
+			return bucketErr
+		}
+		if productBucket.Get(buildIdKey(ev.BuildId)) != nil {
+			return ErrReleaseExists
+		}
+		return productBucket.Put(buildIdKey(ev.BuildId), content)
+	})
+}
+
+func (s *BoltStore) MostRecentRelease(productName string, branch string) (*NewReleaseEvent, error) {
+	var result *NewReleaseEvent
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		releases, readErr := boltReleasesFor(tx, productName)
+		if readErr != nil {
+			return readErr
+		}
+		result = mostRecentOf(releases, branch)
+		return nil
+	})
+	return result, err
+}
+
+/**
+apply change to the record for buildId in bucket, which can be nil. Caller must be in an update transaction, so
+nothing else can change the record in between.
+returns the updated record, or nil if there is none
+*/
+func boltUpdateRecord(bucket *bbolt.Bucket, buildId int, change func(ev *NewReleaseEvent)) (*NewReleaseEvent, error) {
+	if bucket == nil {
+		return nil, nil
+	}
+	content := bucket.Get(buildIdKey(buildId))
+	if content == nil {
+		return nil, nil
+	}
+	var ev NewReleaseEvent
+	if unmarshalErr := json.Unmarshal(content, &ev); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	change(&ev)
+	updated, marshalErr := json.Marshal(ev)
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	if putErr := bucket.Put(buildIdKey(buildId), updated); putErr != nil {
+		return nil, putErr
+	}
+	return &ev, nil
+}
+
+/**
+apply change in place to the release buildId of productName, see boltUpdateRecord
+*/
+func (s *BoltStore) updateRelease(productName string, buildId int, change func(ev *NewReleaseEvent)) (*NewReleaseEvent, error) {
+	var result *NewReleaseEvent
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var updateErr error
+		result, updateErr = boltUpdateRecord(tx.Bucket(releasesBucket).Bucket([]byte(productName)), buildId, change)
+		return updateErr
+	})
+	return result, err
+}
+
+/**
+apply change in place to the copy of buildId that was promoted to branch, see boltUpdateRecord
+*/
+func (s *BoltStore) updatePromotion(productName string, branch string, buildId int, change func(ev *NewReleaseEvent)) (*NewReleaseEvent, error) {
+	var result *NewReleaseEvent
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var updateErr error
+		result, updateErr = boltUpdateRecord(tx.Bucket(promotionsBucket).Bucket([]byte(ProductBranchKey(productName, branch))), buildId, change)
+		return updateErr
+	})
+	return result, err
+}
+
+func (s *BoltStore) UpdateRolloutPercentage(productName string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	return s.updateRelease(productName, buildId, func(ev *NewReleaseEvent) {
+		ev.RolloutPercentage = &percentage
+	})
+}
+
+func (s *BoltStore) MarkWithdrawn(productName string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	return s.updateRelease(productName, buildId, func(ev *NewReleaseEvent) {
+		ev.markWithdrawn(reason, withdrawnAt)
+	})
+}
+
+func (s *BoltStore) GetRelease(productName string, buildId int) (*NewReleaseEvent, error) {
+	var result *NewReleaseEvent
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		productBucket := tx.Bucket(releasesBucket).Bucket([]byte(productName))
+		if productBucket == nil {
+			return nil
+		}
+		content := productBucket.Get(buildIdKey(buildId))
+		if content == nil {
+			return nil
+		}
+		var ev NewReleaseEvent
+		unmarshalErr := json.Unmarshal(content, &ev)
+		if unmarshalErr != nil {
+			return unmarshalErr
+		}
+		result = &ev
+		return nil
+	})
+	return result, err
+}
+
+func (s *BoltStore) ListReleases(query *ReleaseQuery) (*ReleasePage, error) {
+	var result *ReleasePage
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		releases, readErr := boltReleasesFor(tx, query.ProductName)
+		if readErr != nil {
+			return readErr
+		}
+		var pageErr error
+		result, pageErr = pageOfReleases(releases, query)
+		return pageErr
+	})
+	return result, err
+}
+
+func (s *BoltStore) DeleteRelease(productName string, buildId int) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		productBucket := tx.Bucket(releasesBucket).Bucket([]byte(productName))
+		if productBucket == nil {
+			return nil
+		}
+		return productBucket.Delete(buildIdKey(buildId))
+	})
+}
+
+func (s *BoltStore) GetBranchSettings(productName string, branch string) (*BranchSettings, error) {
+	var result *BranchSettings
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		content := tx.Bucket(branchesBucket).Get([]byte(ProductBranchKey(productName, branch)))
+		if content == nil {
+			return nil
+		}
+		var settings BranchSettings
+		unmarshalErr := json.Unmarshal(content, &settings)
+		if unmarshalErr != nil {
+			return unmarshalErr
+		}
+		result = &settings
+		return nil
+	})
+	return result, err
+}
+
+func (s *BoltStore) PutBranchSettings(settings *BranchSettings) error {
+	content, marshalErr := json.Marshal(settings)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data for database: %s", marshalErr)
+		return marshalErr
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(branchesBucket).Put([]byte(ProductBranchKey(settings.ProductName, settings.Branch)), content)
+	})
+}
+
+func (s *BoltStore) GetChannel(productName string, name string) (*Channel, error) {
+	var result *Channel
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		productBucket := tx.Bucket(channelsBucket).Bucket([]byte(productName))
+		if productBucket == nil {
+			return nil
+		}
+		content := productBucket.Get([]byte(name))
+		if content == nil {
+			return nil
+		}
+		var channel Channel
+		unmarshalErr := json.Unmarshal(content, &channel)
+		if unmarshalErr != nil {
+			return unmarshalErr
+		}
+		result = &channel
+		return nil
+	})
+	return result, err
+}
+
+func (s *BoltStore) ListChannels(productName string) ([]Channel, error) {
+	result := make([]Channel, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		productBucket := tx.Bucket(channelsBucket).Bucket([]byte(productName))
+		if productBucket == nil {
+			return nil
+		}
+		//bolt keeps keys in byte order, so this is already sorted by name
+		return productBucket.ForEach(func(k, v []byte) error {
+			var channel Channel
+			unmarshalErr := json.Unmarshal(v, &channel)
+			if unmarshalErr != nil {
+				log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+				return unmarshalErr
+			}
+			result = append(result, channel)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *BoltStore) PutChannel(channel *Channel) error {
+	content, marshalErr := json.Marshal(channel)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data for database: %s", marshalErr)
+		return marshalErr
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		productBucket, bucketErr := tx.Bucket(channelsBucket).CreateBucketIfNotExists([]byte(channel.ProductName))
+		if bucketErr != nil {
+			return bucketErr
+		}
+		return productBucket.Put([]byte(channel.Name), content)
+	})
+}
+
+func (s *BoltStore) DeleteChannel(productName string, name string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		productBucket := tx.Bucket(channelsBucket).Bucket([]byte(productName))
+		if productBucket == nil {
+			return nil
+		}
+		return productBucket.Delete([]byte(name))
+	})
+}
+
+func (s *BoltStore) PutPromotion(ev *NewReleaseEvent) error {
+	content, marshalErr := json.Marshal(ev)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data for database: %s", marshalErr)
+		return marshalErr
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		branchBucket, bucketErr := tx.Bucket(promotionsBucket).CreateBucketIfNotExists([]byte(ProductBranchKey(ev.ProductName, ev.Branch)))
+		if bucketErr != nil {
+			return bucketErr
+		}
+		return branchBucket.Put(buildIdKey(ev.BuildId), content)
+	})
+}
+
+func (s *BoltStore) GetPromotion(productName string, branch string, buildId int) (*NewReleaseEvent, error) {
+	var result *NewReleaseEvent
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		branchBucket := tx.Bucket(promotionsBucket).Bucket([]byte(ProductBranchKey(productName, branch)))
+		if branchBucket == nil {
+			return nil
+		}
+		content := branchBucket.Get(buildIdKey(buildId))
+		if content == nil {
+			return nil
+		}
+		var ev NewReleaseEvent
+		unmarshalErr := json.Unmarshal(content, &ev)
+		if unmarshalErr != nil {
+			return unmarshalErr
+		}
+		result = &ev
+		return nil
+	})
+	return result, err
+}
+
+func (s *BoltStore) UpdatePromotionRolloutPercentage(productName string, branch string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	return s.updatePromotion(productName, branch, buildId, func(ev *NewReleaseEvent) {
+		ev.RolloutPercentage = &percentage
+	})
+}
+
+func (s *BoltStore) MarkPromotionWithdrawn(productName string, branch string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	return s.updatePromotion(productName, branch, buildId, func(ev *NewReleaseEvent) {
+		ev.markWithdrawn(reason, withdrawnAt)
+	})
+}
+
+func (s *BoltStore) AddPromotedTo(productName string, buildId int, branch string) (*NewReleaseEvent, error) {
+	return s.updateRelease(productName, buildId, func(ev *NewReleaseEvent) {
+		ev.addPromotedTo(branch)
+	})
+}
+
+func (s *BoltStore) ListPromotions(productName string, branch string) ([]NewReleaseEvent, error) {
+	result := make([]NewReleaseEvent, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		branchBucket := tx.Bucket(promotionsBucket).Bucket([]byte(ProductBranchKey(productName, branch)))
+		if branchBucket == nil {
+			return nil
+		}
+		cursor := branchBucket.Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var ev NewReleaseEvent
+			unmarshalErr := json.Unmarshal(v, &ev)
+			if unmarshalErr != nil {
+				log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+				return unmarshalErr
+			}
+			result = append(result, ev)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (s *BoltStore) GetProduct(productName string) (*Product, error) {
+	var result *Product
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		content := tx.Bucket(productsBucket).Get([]byte(productName))
+		if content == nil {
+			return nil
+		}
+		var product Product
+		unmarshalErr := json.Unmarshal(content, &product)
+		if unmarshalErr != nil {
+			return unmarshalErr
+		}
+		result = &product
+		return nil
+	})
+	return result, err
+}
+
+func (s *BoltStore) ListProducts() ([]Product, error) {
+	result := make([]Product, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		//bolt keeps keys in byte order, so this is already sorted by productName
+		return tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+			var product Product
+			unmarshalErr := json.Unmarshal(v, &product)
+			if unmarshalErr != nil {
+				log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+				return unmarshalErr
+			}
+			result = append(result, product)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *BoltStore) PutProduct(product *Product) error {
+	content, marshalErr := json.Marshal(product)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data for database: %s", marshalErr)
+		return marshalErr
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(productsBucket).Put([]byte(product.ProductName), content)
+	})
+}
+
+func (s *BoltStore) CountDownload(productName string, buildId int, platform string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		productBucket, bucketErr := tx.Bucket(downloadsBucket).CreateBucketIfNotExists([]byte(productName))
+		if bucketErr != nil {
+			return bucketErr
+		}
+
+		key := []byte(downloadCountKey(buildId, platform))
+		count := DownloadCount{ProductName: productName, BuildId: buildId, Platform: platform}
+		if content := productBucket.Get(key); content != nil {
+			if unmarshalErr := json.Unmarshal(content, &count); unmarshalErr != nil {
+				return unmarshalErr
+			}
+		}
+		count.Downloads++
+
+		content, marshalErr := json.Marshal(count)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		return productBucket.Put(key, content)
+	})
+}
+
+func (s *BoltStore) DownloadCounts(productName string, buildId int) ([]DownloadCount, error) {
+	result := make([]DownloadCount, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		productBucket := tx.Bucket(downloadsBucket).Bucket([]byte(productName))
+		if productBucket == nil {
+			return nil
+		}
+
+		//the keys of a build share a prefix, and bolt keeps them in byte order so they come out in platform order
+		prefix := []byte(downloadCountPrefix(buildId))
+		cursor := productBucket.Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			var count DownloadCount
+			if unmarshalErr := json.Unmarshal(v, &count); unmarshalErr != nil {
+				log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+				return unmarshalErr
+			}
+			result = append(result, count)
+		}
+		return nil
+	})
+	return result, err
+}
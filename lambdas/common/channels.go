@@ -0,0 +1,136 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+//channels are named by people, so keep the names simple
+var channelNameValidator = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+//a fallback chain longer than this is almost certainly a mistake
+const MaxChannelChainLength = 10
+
+/**
+a named audience for a product, such as stable, beta or nightly, that is served from one or more branches.
+A channel can fall back to another channel, so that e.g. beta clients also get stable builds that are newer than
+anything on the beta branches.
+*/
+type Channel struct {
+	ProductName string   `json:"productName"`
+	Name        string   `json:"name"`
+	Branches    []string `json:"branches"`
+	Fallback    string   `json:"fallback,omitempty"` //optional name of another channel of the same product
+}
+
+func (c *Channel) Validate() error {
+	if c.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if !channelNameValidator.MatchString(c.Name) {
+		return errors.New("name must be specified and can only contain letters, numbers, '.', '_' and '-'")
+	}
+	if len(c.Branches) == 0 {
+		return errors.New("at least one branch must be specified")
+	}
+	for _, branch := range c.Branches {
+		if branch == "" {
+			return errors.New("branches can't be empty")
+		}
+	}
+	if c.Fallback == c.Name {
+		return errors.New("a channel can't fall back to itself")
+	}
+	return nil
+}
+
+/**
+ChannelStore is implemented by the stores that can also hold Channels.
+Lookups by channel read them to find the branches that the channel is served from.
+*/
+type ChannelStore interface {
+	//return the named channel of productName, or nil and nil if there is no such channel
+	GetChannel(productName string, name string) (*Channel, error)
+	//return every channel of productName, in name order
+	ListChannels(productName string) ([]Channel, error)
+	//save a channel, replacing any channel of the same product with the same name
+	PutChannel(channel *Channel) error
+	//remove a channel. Removing a channel that does not exist is not an error.
+	DeleteChannel(productName string, name string) error
+}
+
+/**
+returns the channel and the channels that it falls back to, in order.
+If a channel in the chain has been deleted the chain stops there.
+returns nil and nil if there is no such channel
+*/
+func ChannelChain(store ChannelStore, productName string, name string) ([]Channel, error) {
+	var chain []Channel
+	seen := make(map[string]bool)
+	for name != "" {
+		if seen[name] {
+			return nil, fmt.Errorf("channel %s of %s falls back to itself", name, productName)
+		}
+		if len(chain) == MaxChannelChainLength {
+			return nil, fmt.Errorf("channel %s of %s has more than %d fallbacks", chain[0].Name, productName, MaxChannelChainLength)
+		}
+		seen[name] = true
+
+		channel, getErr := store.GetChannel(productName, name)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if channel == nil {
+			if len(chain) > 0 {
+				log.Printf("Channel %s of %s falls back to %s, which does not exist", chain[len(chain)-1].Name, productName, name)
+			}
+			break
+		}
+		chain = append(chain, *channel)
+		name = channel.Fallback
+	}
+	return chain, nil
+}
+
+/**
+check that the fallback of a channel exists and that saving the channel would not make a loop of fallbacks
+arguments:
+    - channel: the channel that is about to be saved
+    - fallbackChain: the ChannelChain of channel.Fallback as it is now
+*/
+func CheckFallback(channel *Channel, fallbackChain []Channel) error {
+	if channel.Fallback == "" {
+		return nil
+	}
+	if len(fallbackChain) == 0 {
+		return fmt.Errorf("fallback channel %s does not exist", channel.Fallback)
+	}
+	if len(fallbackChain) >= MaxChannelChainLength {
+		return fmt.Errorf("channel %s would have more than %d fallbacks", channel.Name, MaxChannelChainLength)
+	}
+	for _, fallback := range fallbackChain {
+		if fallback.Name == channel.Name {
+			return fmt.Errorf("channel %s would fall back to itself through %s", channel.Name, channel.Fallback)
+		}
+	}
+	return nil
+}
+
+/**
+returns every branch of the channels in a chain, in order, without repeats
+*/
+func ChainBranches(chain []Channel) []string {
+	var branches []string
+	seen := make(map[string]bool)
+	for _, channel := range chain {
+		for _, branch := range channel.Branches {
+			if !seen[branch] {
+				seen[branch] = true
+				branches = append(branches, branch)
+			}
+		}
+	}
+	return branches
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+)
+
+func TestChannel_Validate(t *testing.T) {
+	valid := Channel{ProductName: "test product", Name: "beta", Branches: []string{"develop"}, Fallback: "stable"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid channel failed validation: %s", err)
+	}
+
+	invalid := []Channel{
+		{Name: "beta", Branches: []string{"develop"}},
+		{ProductName: "test product", Name: "", Branches: []string{"develop"}},
+		{ProductName: "test product", Name: "beta channel", Branches: []string{"develop"}},
+		{ProductName: "test product", Name: "beta"},
+		{ProductName: "test product", Name: "beta", Branches: []string{""}},
+		{ProductName: "test product", Name: "beta", Branches: []string{"develop"}, Fallback: "beta"},
+	}
+	for _, channel := range invalid {
+		if err := channel.Validate(); err == nil {
+			t.Errorf("channel should have failed validation: %s", spew.Sprint(channel))
+		}
+	}
+}
+
+func TestChannelChain(t *testing.T) {
+	store := NewMemoryStore()
+	store.PutChannel(&Channel{ProductName: "test product", Name: "stable", Branches: []string{"master"}})
+	store.PutChannel(&Channel{ProductName: "test product", Name: "beta", Branches: []string{"release", "master"}, Fallback: "stable"})
+	store.PutChannel(&Channel{ProductName: "test product", Name: "nightly", Branches: []string{"develop"}, Fallback: "beta"})
+	store.PutChannel(&Channel{ProductName: "test product", Name: "orphan", Branches: []string{"old"}, Fallback: "deleted"})
+
+	chain, err := ChannelChain(store, "test product", "nightly")
+	if err != nil || len(chain) != 3 || chain[0].Name != "nightly" || chain[2].Name != "stable" {
+		t.Errorf("ChannelChain returned the wrong chain: %s, %s", spew.Sprint(chain), err)
+	}
+	if branches := ChainBranches(chain); !reflect.DeepEqual(branches, []string{"develop", "release", "master"}) {
+		t.Errorf("ChainBranches returned the wrong branches: %v", branches)
+	}
+
+	orphan, err := ChannelChain(store, "test product", "orphan")
+	if err != nil || len(orphan) != 1 {
+		t.Errorf("ChannelChain should have stopped at the missing fallback but got %s, %s", spew.Sprint(orphan), err)
+	}
+
+	missing, err := ChannelChain(store, "test product", "nochannel")
+	if err != nil || len(missing) != 0 {
+		t.Errorf("ChannelChain of a missing channel should have been empty but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	//a loop can only get into the store if something skipped CheckFallback
+	store.PutChannel(&Channel{ProductName: "test product", Name: "stable", Branches: []string{"master"}, Fallback: "nightly"})
+	if _, err := ChannelChain(store, "test product", "nightly"); err == nil {
+		t.Errorf("ChannelChain should have failed on a loop")
+	}
+}
+
+func TestCheckFallback(t *testing.T) {
+	stable := Channel{ProductName: "test product", Name: "stable", Branches: []string{"master"}}
+	beta := Channel{ProductName: "test product", Name: "beta", Branches: []string{"release"}, Fallback: "stable"}
+
+	if err := CheckFallback(&stable, nil); err != nil {
+		t.Errorf("a channel without a fallback should have passed but got %s", err)
+	}
+	if err := CheckFallback(&beta, []Channel{stable}); err != nil {
+		t.Errorf("a channel with a valid fallback should have passed but got %s", err)
+	}
+	if err := CheckFallback(&beta, nil); err == nil {
+		t.Errorf("a channel with a missing fallback should have failed")
+	}
+
+	loopingStable := Channel{ProductName: "test product", Name: "stable", Branches: []string{"master"}, Fallback: "beta"}
+	if err := CheckFallback(&loopingStable, []Channel{beta, stable}); err == nil {
+		t.Errorf("a channel that would fall back to itself should have failed")
+	}
+}
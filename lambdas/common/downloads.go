@@ -0,0 +1,80 @@
+package common
+
+import (
+	"strconv"
+)
+
+/**
+how many times a release has been downloaded through /download on one platform. Platform is the os and arch of the
+artifact that was served, see DownloadPlatform.
+*/
+type DownloadCount struct {
+	ProductName string `json:"productName"`
+	BuildId     int    `json:"buildId"`
+	Platform    string `json:"platform"`
+	Downloads   int64  `json:"downloads"`
+	//the sort key of the downloads table, see downloadCountKey
+	CountKey string `json:"-" dynamodbav:"countKey,omitempty"`
+}
+
+//the platform that downloads of a plain DownloadUrl, or of an artifact that works on any os, are counted against
+const AnyPlatform = "any"
+
+/**
+the platform that a download of an artifact is counted against. This is the artifact's own os and arch, normalised
+so that aliases such as darwin and macos are counted together, rather than whatever the client asked for, so the
+counts only ever cover platforms that releases were actually published for: "os/arch", just the os for an artifact
+that works on any arch of it, or AnyPlatform.
+*/
+func DownloadPlatform(artifact *Artifact) string {
+	platform := NormalisePlatform(artifact.Os)
+	if platform == "" {
+		return AnyPlatform
+	}
+	if arch := NormalisePlatform(artifact.Arch); arch != "" {
+		platform += "/" + arch
+	}
+	return platform
+}
+
+/**
+the key of a download count within its product. The buildId comes first so that the counts of a release can be read
+back together; the separator stops build 12 picking up the counts of build 123.
+*/
+func downloadCountKey(buildId int, platform string) string {
+	return strconv.Itoa(buildId) + ":" + platform
+}
+
+func downloadCountPrefix(buildId int) string {
+	return downloadCountKey(buildId, "")
+}
+
+/**
+DownloadStore is implemented by the stores that can also keep download statistics.
+/download adds to the counts and /downloads reads them back.
+*/
+type DownloadStore interface {
+	//add one to the number of times a release has been downloaded on a platform
+	CountDownload(productName string, buildId int, platform string) error
+	//return the download counts of a release, one per platform, in platform order
+	DownloadCounts(productName string, buildId int) ([]DownloadCount, error)
+}
+
+/**
+the download statistics of a release, as returned by GET /downloads
+*/
+type DownloadStats struct {
+	ProductName string `json:"productName"`
+	BuildId     int    `json:"buildId"`
+	//the total over every platform
+	Downloads int64           `json:"downloads"`
+	Platforms []DownloadCount `json:"platforms"`
+}
+
+func NewDownloadStats(productName string, buildId int, counts []DownloadCount) *DownloadStats {
+	stats := &DownloadStats{ProductName: productName, BuildId: buildId, Platforms: counts}
+	for _, count := range counts {
+		stats.Downloads += count.Downloads
+	}
+	return stats
+}
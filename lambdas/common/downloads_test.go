@@ -0,0 +1,26 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestDownloadPlatform(t *testing.T) {
+	tests := map[Artifact]string{
+		{}:                              AnyPlatform,
+		{Os: "Windows"}:                 "windows",
+		{Os: "darwin", Arch: "aarch64"}: "macos/arm64",
+		{Os: "linux", Arch: "x64"}:      "linux/x64",
+	}
+	for artifact, expected := range tests {
+		if platform := DownloadPlatform(&artifact); platform != expected {
+			t.Errorf("DownloadPlatform(%v) returned %s, expected %s", artifact, platform, expected)
+		}
+	}
+}
+
+func TestNewDownloadStats(t *testing.T) {
+	stats := NewDownloadStats("test product", 12, []DownloadCount{{Platform: "any", Downloads: 2}, {Platform: "windows", Downloads: 3}})
+	if stats.Downloads != 5 || len(stats.Platforms) != 2 {
+		t.Errorf("NewDownloadStats should have totalled the counts but got %v", stats)
+	}
+}
@@ -1,13 +1,32 @@
 package common
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+//name of the global secondary index on the data table, keyed on productBranch and buildId
+const BranchIndexName = "productBranch-buildId-index"
+
+//name of the global secondary index on the data table, keyed on productBranch and semverKey. Releases without a semver
+//have no semverKey, so they are left out of it.
+const SemverIndexName = "productBranch-semverKey-index"
+
+//filters out withdrawn releases. withdrawn is omitted from records that have not been withdrawn.
+const notWithdrawnFilter = "attribute_not_exists(withdrawn) OR withdrawn = :notWithdrawn"
+
 /**
 logs a new release to the database
 arguments:
@@ -15,6 +34,28 @@ arguments:
  - tableName: table name to write to. Client must have PutObject permission for this
 */
 func (ev *NewReleaseEvent) LogRelease(client dynamodbiface.DynamoDBAPI, tableName string) error {
+	return ev.putRelease(client, tableName, nil)
+}
+
+/**
+write the release to Dynamo only if there is no record with the same productName and buildId, so that a pipeline
+that is run again can't overwrite what was logged the first time.
+returns ErrReleaseExists if there is already a record
+*/
+func (ev *NewReleaseEvent) CreateRelease(client dynamodbiface.DynamoDBAPI, tableName string) error {
+	putErr := ev.putRelease(client, tableName, aws.String("attribute_not_exists(buildId)"))
+	if awsErr, isAwsErr := putErr.(awserr.Error); isAwsErr && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrReleaseExists
+	}
+	return putErr
+}
+
+func (ev *NewReleaseEvent) putRelease(client dynamodbiface.DynamoDBAPI, tableName string, condition *string) error {
+	ev.ProductBranch = ProductBranchKey(ev.ProductName, ev.Branch)
+	if version, semverErr := ev.ParsedSemver(); version != nil && semverErr == nil {
+		ev.SemverKey = version.SortKey()
+	}
+
 	attributeValues, marshalErr := dynamodbattribute.MarshalMap(ev)
 	if marshalErr != nil {
 		log.Printf("Could not marshal data into dynamo format: %s\n", marshalErr)
@@ -22,8 +63,9 @@ func (ev *NewReleaseEvent) LogRelease(client dynamodbiface.DynamoDBAPI, tableNam
 	}
 
 	input := &dynamodb.PutItemInput{
-		Item:      attributeValues,
-		TableName: aws.String(tableName),
+		Item:                attributeValues,
+		TableName:           aws.String(tableName),
+		ConditionExpression: condition,
 	}
 
 	_, putErr := client.PutItem(input)
@@ -37,7 +79,7 @@ func (ev *NewReleaseEvent) LogRelease(client dynamodbiface.DynamoDBAPI, tableNam
 }
 
 /**
-retrieve a record for the most recent release of productName
+retrieve a record for the most recent release of productName that has not been withdrawn
 arguments:
     - client: an instance of Dynamodb client or a mock
     - tableName: a string of the dynamo table name
@@ -47,40 +89,1035 @@ returns either:
 	- nil and an error if an error occurred
     - a pointer to a NewReleaseEvent record and nil if a record was found
     - nil and nil if no record was found and there was not an error
+this queries the branch index, so only records that have a productBranch attribute are found; older records
+can be given one with utils/backfill-branch-index.  Dynamo applies the withdrawn filter after the limit, so if a page
+comes back empty but Dynamo says there is more to read we keep following LastEvaluatedKey until we find a record or
+run out of pages.
 */
 func MostRecentRelease(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string) (*NewReleaseEvent, error) {
 	scanForward := false //we want to start with the highest number
 
 	parameters := map[string]*dynamodb.AttributeValue{
-		":nameSubst":   {S: aws.String(productName)},
-		":branchSubst": {S: aws.String(branch)},
+		":productBranchSubst": {S: aws.String(ProductBranchKey(productName, branch))},
+		":notWithdrawn":       {BOOL: aws.Bool(false)},
+	}
+
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		qInput := &dynamodb.QueryInput{
+			TableName:                 &tableName,
+			IndexName:                 aws.String(BranchIndexName),
+			KeyConditionExpression:    aws.String("productBranch=:productBranchSubst"),
+			FilterExpression:          aws.String(notWithdrawnFilter),
+			ExpressionAttributeValues: parameters,
+			ScanIndexForward:          &scanForward,
+			Limit:                     aws.Int64(1),
+			ExclusiveStartKey:         startKey,
+		}
+
+		results, scanErr := client.Query(qInput)
+		if scanErr != nil {
+			log.Printf("Could not perform table query: %s", scanErr)
+			return nil, scanErr
+		}
+
+		if *results.Count > 0 {
+			objectsList := make([]NewReleaseEvent, *results.Count)
+
+			unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &objectsList)
+			if unmarshalErr != nil {
+				log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+				return nil, unmarshalErr
+			}
+			return &objectsList[0], nil
+		}
+
+		if len(results.LastEvaluatedKey) == 0 {
+			log.Printf("No results found for productName %s on branch %s", productName, branch)
+			return nil, nil
+		}
+		startKey = results.LastEvaluatedKey
+	}
+}
+
+/**
+call fn for every release on a branch that has a semver and has not been withdrawn, highest semver first, by querying
+the semver index a page at a time. Releases with the same semver come out in no particular order.
+stops early if fn returns false.
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: table to query. Client must have Query permission on its SemverIndexName index
+    - productName: product name to filter on
+    - branch: branch to filter on
+    - fn: called with each release
+*/
+func EachReleaseBySemver(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string, fn func(ev *NewReleaseEvent) bool) error {
+	scanForward := false //we want to start with the highest version
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		results, queryErr := client.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			IndexName:              aws.String(SemverIndexName),
+			KeyConditionExpression: aws.String("productBranch=:productBranchSubst"),
+			FilterExpression:       aws.String(notWithdrawnFilter),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":productBranchSubst": {S: aws.String(ProductBranchKey(productName, branch))},
+				":notWithdrawn":       {BOOL: aws.Bool(false)},
+			},
+			ScanIndexForward:  &scanForward,
+			Limit:             aws.Int64(MaxPageSize),
+			ExclusiveStartKey: startKey,
+		})
+		if queryErr != nil {
+			log.Printf("Could not perform table query: %s", queryErr)
+			return queryErr
+		}
+
+		page := make([]NewReleaseEvent, 0, len(results.Items))
+		unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &page)
+		if unmarshalErr != nil {
+			log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+			return unmarshalErr
+		}
+		for i := range page {
+			if !fn(&page[i]) {
+				return nil
+			}
+		}
+
+		if len(results.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = results.LastEvaluatedKey
+	}
+}
+
+var ErrInvalidPageToken = errors.New("pageToken is not valid")
+
+/**
+turn a LastEvaluatedKey from Dynamo into an opaque string that can be handed to a client
+*/
+func encodePageToken(lastEvaluatedKey map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return "", nil
+	}
+	raw, marshalErr := json.Marshal(lastEvaluatedKey)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+/**
+turn a token from encodePageToken back into an ExclusiveStartKey for Dynamo
+*/
+func decodePageToken(token string) (map[string]*dynamodb.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, decodeErr := base64.RawURLEncoding.DecodeString(token)
+	if decodeErr != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var startKey map[string]*dynamodb.AttributeValue
+	unmarshalErr := json.Unmarshal(raw, &startKey)
+	if unmarshalErr != nil || len(startKey) == 0 {
+		return nil, ErrInvalidPageToken
+	}
+	return startKey, nil
+}
+
+/**
+list the release history of a product, newest first
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: a string of the dynamo table name
+    - query: pointer to a ReleaseQuery giving the product name, optional filters and pagination settings
+returns either:
+	- nil and an error if an error occurred. If the page token could not be understood this is ErrInvalidPageToken
+    - a pointer to a ReleasePage and nil otherwise. Because timestamp filters are applied after the page is read,
+      a page can contain fewer than PageSize results (or none at all) even if there are more to come.
+*/
+func ListReleases(client dynamodbiface.DynamoDBAPI, tableName string, query *ReleaseQuery) (*ReleasePage, error) {
+	scanForward := false //we want to start with the highest number
+
+	startKey, tokenErr := decodePageToken(query.PageToken)
+	if tokenErr != nil {
+		return nil, tokenErr
 	}
 
+	parameters := map[string]*dynamodb.AttributeValue{}
+	attributeNames := map[string]*string{}
+	var filters []string
+
 	qInput := &dynamodb.QueryInput{
-		TableName:                 &tableName,
-		KeyConditionExpression:    aws.String("productName=:nameSubst"),
-		ExpressionAttributeValues: parameters,
-		ScanIndexForward:          &scanForward,
-		FilterExpression:          aws.String("branch=:branchSubst"),
+		TableName:        &tableName,
+		ScanIndexForward: &scanForward,
+		Limit:            aws.Int64(int64(query.PageSize)),
+	}
+
+	//if we have a branch then the branch index gives us exactly the records we want, otherwise read the whole product
+	if query.Branch != "" {
+		parameters[":productBranchSubst"] = &dynamodb.AttributeValue{S: aws.String(ProductBranchKey(query.ProductName, query.Branch))}
+		qInput.IndexName = aws.String(BranchIndexName)
+		qInput.KeyConditionExpression = aws.String("productBranch=:productBranchSubst")
+	} else {
+		parameters[":nameSubst"] = &dynamodb.AttributeValue{S: aws.String(query.ProductName)}
+		qInput.KeyConditionExpression = aws.String("productName=:nameSubst")
+	}
+	if query.Since != "" {
+		parameters[":sinceSubst"] = &dynamodb.AttributeValue{S: aws.String(query.Since)}
+		attributeNames["#ts"] = aws.String("timestamp")
+		filters = append(filters, "#ts >= :sinceSubst")
+	}
+	if query.Until != "" {
+		parameters[":untilSubst"] = &dynamodb.AttributeValue{S: aws.String(query.Until)}
+		attributeNames["#ts"] = aws.String("timestamp")
+		filters = append(filters, "#ts <= :untilSubst")
+	}
+
+	qInput.ExpressionAttributeValues = parameters
+	qInput.ExclusiveStartKey = startKey
+	if len(filters) > 0 {
+		qInput.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if len(attributeNames) > 0 {
+		qInput.ExpressionAttributeNames = attributeNames
+	}
+
+	results, queryErr := client.Query(qInput)
+	if queryErr != nil {
+		log.Printf("Could not perform table query: %s", queryErr)
+		return nil, queryErr
+	}
+
+	page := ReleasePage{Releases: make([]NewReleaseEvent, 0, len(results.Items))}
+	unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &page.Releases)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
 	}
 
-	results, scanErr := client.Query(qInput)
-	if scanErr != nil {
-		log.Printf("Could not perform table query: %s", scanErr)
-		return nil, scanErr
+	var encodeErr error
+	page.NextPageToken, encodeErr = encodePageToken(results.LastEvaluatedKey)
+	if encodeErr != nil {
+		log.Printf("Could not encode pagination token: %s", encodeErr)
+		return nil, encodeErr
+	}
+	return &page, nil
+}
+
+/**
+retrieve the record for a specific build of productName
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: a string of the dynamo table name
+    - productName: product name of the build
+    - buildId: build id of the build
+returns either:
+	- nil and an error if an error occurred
+    - a pointer to a NewReleaseEvent record and nil if a record was found
+    - nil and nil if no record was found and there was not an error
+*/
+func GetRelease(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int) (*NewReleaseEvent, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+			"buildId":     {N: aws.String(strconv.Itoa(buildId))},
+		},
 	}
 
-	if *results.Count > 0 {
-		objectsList := make([]NewReleaseEvent, *results.Count)
+	result, getErr := client.GetItem(input)
+	if getErr != nil {
+		log.Printf("Could not get item from Dynamo table %s: %s", tableName, getErr)
+		return nil, getErr
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var ev NewReleaseEvent
+	unmarshalErr := dynamodbattribute.UnmarshalMap(result.Item, &ev)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &ev, nil
+}
+
+/**
+remove the record for a specific build of productName
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: table name to delete from. Client must have DeleteItem permission for this
+    - productName: product name of the build
+    - buildId: build id of the build
+*/
+func DeleteRelease(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+			"buildId":     {N: aws.String(strconv.Itoa(buildId))},
+		},
+	}
 
-		unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &objectsList)
+	_, deleteErr := client.DeleteItem(input)
+	if deleteErr != nil {
+		log.Printf("Could not delete item from Dynamo table %s: %s", tableName, deleteErr)
+		return deleteErr
+	}
+	return nil
+}
+
+/**
+get the settings for a branch
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: branches table to read from, keyed on productName and branch. Client must have GetItem permission for this
+    - productName: product name of the branch
+    - branch: name of the branch
+returns nil and nil if no settings have been saved for the branch
+*/
+func GetBranchSettings(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string) (*BranchSettings, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+			"branch":      {S: aws.String(branch)},
+		},
+	}
+
+	result, getErr := client.GetItem(input)
+	if getErr != nil {
+		log.Printf("Could not get item from Dynamo table %s: %s", tableName, getErr)
+		return nil, getErr
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var settings BranchSettings
+	unmarshalErr := dynamodbattribute.UnmarshalMap(result.Item, &settings)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &settings, nil
+}
+
+/**
+save the settings for a branch, replacing any that were there before
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: branches table to write to. Client must have PutItem permission for this
+    - settings: the settings to save
+*/
+func PutBranchSettings(client dynamodbiface.DynamoDBAPI, tableName string, settings *BranchSettings) error {
+	attributeValues, marshalErr := dynamodbattribute.MarshalMap(settings)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data into dynamo format: %s\n", marshalErr)
+		return marshalErr
+	}
+
+	_, putErr := client.PutItem(&dynamodb.PutItemInput{
+		Item:      attributeValues,
+		TableName: aws.String(tableName),
+	})
+	if putErr != nil {
+		log.Printf("Could not write branch settings to Dynamo table %s: %s", tableName, putErr)
+		return putErr
+	}
+	return nil
+}
+
+/**
+get a channel of a product
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: channels table to read from, keyed on productName and name. Client must have GetItem permission for this
+    - productName: product name of the channel
+    - name: name of the channel
+returns nil and nil if there is no such channel
+*/
+func GetChannel(client dynamodbiface.DynamoDBAPI, tableName string, productName string, name string) (*Channel, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+			"name":        {S: aws.String(name)},
+		},
+	}
+
+	result, getErr := client.GetItem(input)
+	if getErr != nil {
+		log.Printf("Could not get item from Dynamo table %s: %s", tableName, getErr)
+		return nil, getErr
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var channel Channel
+	unmarshalErr := dynamodbattribute.UnmarshalMap(result.Item, &channel)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &channel, nil
+}
+
+/**
+list every channel of a product, in name order
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: channels table to read from. Client must have Query permission for this
+    - productName: product name to list the channels of
+*/
+func ListChannels(client dynamodbiface.DynamoDBAPI, tableName string, productName string) ([]Channel, error) {
+	result := make([]Channel, 0)
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		results, queryErr := client.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("productName=:productNameSubst"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":productNameSubst": {S: aws.String(productName)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if queryErr != nil {
+			log.Printf("Could not perform table query: %s", queryErr)
+			return nil, queryErr
+		}
+
+		page := make([]Channel, 0, len(results.Items))
+		unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &page)
 		if unmarshalErr != nil {
 			log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
 			return nil, unmarshalErr
 		}
-		return &objectsList[0], nil
-	} else {
-		log.Printf("No results found for productName %s", productName)
+		result = append(result, page...)
+
+		if len(results.LastEvaluatedKey) == 0 {
+			return result, nil
+		}
+		startKey = results.LastEvaluatedKey
+	}
+}
+
+/**
+save a channel, replacing any channel of the same product with the same name
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: channels table to write to. Client must have PutItem permission for this
+    - channel: the channel to save
+*/
+func PutChannel(client dynamodbiface.DynamoDBAPI, tableName string, channel *Channel) error {
+	attributeValues, marshalErr := dynamodbattribute.MarshalMap(channel)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data into dynamo format: %s\n", marshalErr)
+		return marshalErr
+	}
+
+	_, putErr := client.PutItem(&dynamodb.PutItemInput{
+		Item:      attributeValues,
+		TableName: aws.String(tableName),
+	})
+	if putErr != nil {
+		log.Printf("Could not write channel to Dynamo table %s: %s", tableName, putErr)
+		return putErr
+	}
+	return nil
+}
+
+/**
+remove a channel of a product
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: channels table to delete from. Client must have DeleteItem permission for this
+    - productName: product name of the channel
+    - name: name of the channel
+*/
+func DeleteChannel(client dynamodbiface.DynamoDBAPI, tableName string, productName string, name string) error {
+	_, deleteErr := client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+			"name":        {S: aws.String(name)},
+		},
+	})
+	if deleteErr != nil {
+		log.Printf("Could not delete item from Dynamo table %s: %s", tableName, deleteErr)
+		return deleteErr
+	}
+	return nil
+}
+
+/**
+save a promoted copy of a release, replacing any earlier promotion of the same build to the same branch
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: promotions table to write to, keyed on productBranch and buildId. Client must have PutItem permission for this
+    - ev: the promoted copy, see Promote
+*/
+func PutPromotion(client dynamodbiface.DynamoDBAPI, tableName string, ev *NewReleaseEvent) error {
+	promoted := *ev
+	promoted.ProductBranch = ProductBranchKey(ev.ProductName, ev.Branch)
+	if version, semverErr := ev.ParsedSemver(); version != nil && semverErr == nil {
+		promoted.SemverKey = version.SortKey()
+	}
+
+	attributeValues, marshalErr := dynamodbattribute.MarshalMap(promoted)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data into dynamo format: %s\n", marshalErr)
+		return marshalErr
+	}
+
+	_, putErr := client.PutItem(&dynamodb.PutItemInput{
+		Item:      attributeValues,
+		TableName: aws.String(tableName),
+	})
+	if putErr != nil {
+		log.Printf("Could not write promotion to Dynamo table %s: %s", tableName, putErr)
+		return putErr
+	}
+	return nil
+}
+
+/**
+change some attributes of an existing record in place, rather than putting the whole record back, so that a change
+made by someone else at the same time to other attributes is kept
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: table to update. Client must have UpdateItem permission for this
+    - key: the key of the record
+    - updateExpression: the SET expression to apply
+    - condition: condition that the record must meet to be updated, at least recordExistsCondition
+    - values: the values for updateExpression and condition
+returns the updated record, or nil and nil if there is no record with the key or it doesn't meet the condition
+*/
+func updateRecord(client dynamodbiface.DynamoDBAPI, tableName string, key map[string]*dynamodb.AttributeValue, updateExpression string, condition string, values map[string]*dynamodb.AttributeValue) (*NewReleaseEvent, error) {
+	result, updateErr := client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       key,
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if awsErr, isAwsErr := updateErr.(awserr.Error); isAwsErr && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
 		return nil, nil
+	} else if updateErr != nil {
+		log.Printf("Could not update record in Dynamo table %s: %s", tableName, updateErr)
+		return nil, updateErr
+	}
+
+	var ev NewReleaseEvent
+	unmarshalErr := dynamodbattribute.UnmarshalMap(result.Attributes, &ev)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &ev, nil
+}
+
+//stops an update from creating a record that isn't there
+const recordExistsCondition = "attribute_exists(buildId)"
+
+func releaseKey(productName string, buildId int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"productName": {S: aws.String(productName)},
+		"buildId":     {N: aws.String(strconv.Itoa(buildId))},
+	}
+}
+
+func promotionKey(productName string, branch string, buildId int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"productBranch": {S: aws.String(ProductBranchKey(productName, branch))},
+		"buildId":       {N: aws.String(strconv.Itoa(buildId))},
+	}
+}
+
+const setRolloutExpression = "SET rolloutPercentage = :percentage"
+
+func rolloutValues(percentage int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		":percentage": {N: aws.String(strconv.Itoa(percentage))},
+	}
+}
+
+const setWithdrawnExpression = "SET withdrawn = :withdrawn, withdrawnReason = :reason, withdrawnAt = :withdrawnAt"
+
+func withdrawnValues(reason string, withdrawnAt string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		":withdrawn":   {BOOL: aws.Bool(true)},
+		":reason":      {S: aws.String(reason)},
+		":withdrawnAt": {S: aws.String(withdrawnAt)},
+	}
+}
+
+/**
+change the rollout percentage of a release, see updateRecord
+returns nil and nil if there is no such release
+*/
+func UpdateRolloutPercentage(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	return updateRecord(client, tableName, releaseKey(productName, buildId), setRolloutExpression, recordExistsCondition, rolloutValues(percentage))
+}
+
+/**
+change the rollout percentage of the copy of a build that was promoted to a branch, see updateRecord
+returns nil and nil if the build was not promoted to the branch
+*/
+func UpdatePromotionRolloutPercentage(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	return updateRecord(client, tableName, promotionKey(productName, branch, buildId), setRolloutExpression, recordExistsCondition, rolloutValues(percentage))
+}
+
+/**
+mark a release as withdrawn, see updateRecord
+returns nil and nil if there is no such release
+*/
+func MarkWithdrawn(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	return updateRecord(client, tableName, releaseKey(productName, buildId), setWithdrawnExpression, recordExistsCondition, withdrawnValues(reason, withdrawnAt))
+}
+
+/**
+mark the copy of a build that was promoted to a branch as withdrawn, see updateRecord
+returns nil and nil if the build was not promoted to the branch
+*/
+func MarkPromotionWithdrawn(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	return updateRecord(client, tableName, promotionKey(productName, branch, buildId), setWithdrawnExpression, recordExistsCondition, withdrawnValues(reason, withdrawnAt))
+}
+
+/**
+add a branch to the promotedTo list of a release, see updateRecord. A release that already lists the branch is left
+as it is.
+returns the updated release, or nil and nil if there is no such release
+*/
+func AddPromotedTo(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int, branch string) (*NewReleaseEvent, error) {
+	updated, updateErr := updateRecord(client, tableName, releaseKey(productName, buildId),
+		"SET promotedTo = list_append(if_not_exists(promotedTo, :empty), :branches)",
+		recordExistsCondition+" AND NOT contains(promotedTo, :branch)",
+		map[string]*dynamodb.AttributeValue{
+			":empty":    {L: []*dynamodb.AttributeValue{}},
+			":branches": {L: []*dynamodb.AttributeValue{{S: aws.String(branch)}}},
+			":branch":   {S: aws.String(branch)},
+		})
+	if updated != nil || updateErr != nil {
+		return updated, updateErr
+	}
+	//either there is no such release or it already lists the branch
+	return GetRelease(client, tableName, productName, buildId)
+}
+
+/**
+get the copy of a build that was promoted to a branch
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: promotions table to read from. Client must have GetItem permission for this
+    - productName: product name of the build
+    - branch: branch that it was promoted to
+    - buildId: build to get
+returns nil and nil if the build was not promoted to the branch
+*/
+func GetPromotion(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string, buildId int) (*NewReleaseEvent, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productBranch": {S: aws.String(ProductBranchKey(productName, branch))},
+			"buildId":       {N: aws.String(strconv.Itoa(buildId))},
+		},
+	}
+
+	result, getErr := client.GetItem(input)
+	if getErr != nil {
+		log.Printf("Could not get item from Dynamo table %s: %s", tableName, getErr)
+		return nil, getErr
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var ev NewReleaseEvent
+	unmarshalErr := dynamodbattribute.UnmarshalMap(result.Item, &ev)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &ev, nil
+}
+
+/**
+list every build that was promoted to a branch, newest buildId first
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: promotions table to read from. Client must have Query permission for this
+    - productName: product name to list the promotions of
+    - branch: branch to list the promotions to
+*/
+func ListPromotions(client dynamodbiface.DynamoDBAPI, tableName string, productName string, branch string) ([]NewReleaseEvent, error) {
+	scanForward := false //we want to start with the highest number
+
+	result := make([]NewReleaseEvent, 0)
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		results, queryErr := client.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("productBranch=:productBranchSubst"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":productBranchSubst": {S: aws.String(ProductBranchKey(productName, branch))},
+			},
+			ScanIndexForward:  &scanForward,
+			ExclusiveStartKey: startKey,
+		})
+		if queryErr != nil {
+			log.Printf("Could not perform table query: %s", queryErr)
+			return nil, queryErr
+		}
+
+		page := make([]NewReleaseEvent, 0, len(results.Items))
+		unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &page)
+		if unmarshalErr != nil {
+			log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+			return nil, unmarshalErr
+		}
+		result = append(result, page...)
+
+		if len(results.LastEvaluatedKey) == 0 {
+			return result, nil
+		}
+		startKey = results.LastEvaluatedKey
+	}
+}
+
+/**
+get the configuration of a product
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: products table to read from, keyed on productName. Client must have GetItem permission for this
+    - productName: product to get
+returns nil and nil if the product has not been configured
+*/
+func GetProduct(client dynamodbiface.DynamoDBAPI, tableName string, productName string) (*Product, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+		},
+	}
+
+	result, getErr := client.GetItem(input)
+	if getErr != nil {
+		log.Printf("Could not get item from Dynamo table %s: %s", tableName, getErr)
+		return nil, getErr
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var product Product
+	unmarshalErr := dynamodbattribute.UnmarshalMap(result.Item, &product)
+	if unmarshalErr != nil {
+		log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+		return nil, unmarshalErr
+	}
+	return &product, nil
+}
+
+/**
+list every registered product, in productName order. The registry is small, so this scans the whole table.
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: products table to read from. Client must have Scan permission for this
+*/
+func ListProducts(client dynamodbiface.DynamoDBAPI, tableName string) ([]Product, error) {
+	result := make([]Product, 0)
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		results, scanErr := client.Scan(&dynamodb.ScanInput{
+			TableName:         aws.String(tableName),
+			ExclusiveStartKey: startKey,
+		})
+		if scanErr != nil {
+			log.Printf("Could not scan table %s: %s", tableName, scanErr)
+			return nil, scanErr
+		}
+
+		page := make([]Product, 0, len(results.Items))
+		unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &page)
+		if unmarshalErr != nil {
+			log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+			return nil, unmarshalErr
+		}
+		result = append(result, page...)
+
+		if len(results.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = results.LastEvaluatedKey
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProductName < result[j].ProductName
+	})
+	return result, nil
+}
+
+/**
+save the configuration of a product, replacing any that was there before
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: products table to write to. Client must have PutItem permission for this
+    - product: the configuration to save
+*/
+func PutProduct(client dynamodbiface.DynamoDBAPI, tableName string, product *Product) error {
+	attributeValues, marshalErr := dynamodbattribute.MarshalMap(product)
+	if marshalErr != nil {
+		log.Printf("Could not marshal data into dynamo format: %s\n", marshalErr)
+		return marshalErr
+	}
+
+	_, putErr := client.PutItem(&dynamodb.PutItemInput{
+		Item:      attributeValues,
+		TableName: aws.String(tableName),
+	})
+	if putErr != nil {
+		log.Printf("Could not write product to Dynamo table %s: %s", tableName, putErr)
+		return putErr
+	}
+	return nil
+}
+
+/**
+add one to the number of times a release has been downloaded on a platform. The count is updated in place, so
+downloads that happen at the same time are all counted.
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: downloads table to write to, keyed on productName and countKey. Client must have UpdateItem permission for this
+    - productName: product name of the release
+    - buildId: build that was downloaded
+    - platform: platform of the artifact that was downloaded, see DownloadPlatform
+*/
+func CountDownload(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int, platform string) error {
+	_, updateErr := client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": {S: aws.String(productName)},
+			"countKey":    {S: aws.String(downloadCountKey(buildId, platform))},
+		},
+		UpdateExpression: aws.String("ADD downloads :one SET buildId = :buildId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":     {N: aws.String("1")},
+			":buildId": {N: aws.String(strconv.Itoa(buildId))},
+		},
+	})
+	if updateErr != nil {
+		log.Printf("Could not update download count in Dynamo table %s: %s", tableName, updateErr)
+		return updateErr
+	}
+	return nil
+}
+
+/**
+get the download counts of a release, one per platform, in platform order
+arguments:
+    - client: an instance of Dynamodb client or a mock
+    - tableName: downloads table to read from. Client must have Query permission for this
+    - productName: product name of the release
+    - buildId: build to get the counts of
+*/
+func DownloadCounts(client dynamodbiface.DynamoDBAPI, tableName string, productName string, buildId int) ([]DownloadCount, error) {
+	prefix := downloadCountPrefix(buildId)
+
+	result := make([]DownloadCount, 0)
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		results, queryErr := client.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			KeyConditionExpression: aws.String("productName=:productNameSubst and begins_with(countKey, :prefixSubst)"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":productNameSubst": {S: aws.String(productName)},
+				":prefixSubst":      {S: aws.String(prefix)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if queryErr != nil {
+			log.Printf("Could not perform table query: %s", queryErr)
+			return nil, queryErr
+		}
+
+		page := make([]DownloadCount, 0, len(results.Items))
+		unmarshalErr := dynamodbattribute.UnmarshalListOfMaps(results.Items, &page)
+		if unmarshalErr != nil {
+			log.Printf("Could not unmarshal data from database: %s", unmarshalErr)
+			return nil, unmarshalErr
+		}
+		for i := range page {
+			//the platform is only kept in the key
+			page[i].Platform = strings.TrimPrefix(page[i].CountKey, prefix)
+		}
+		result = append(result, page...)
+
+		if len(results.LastEvaluatedKey) == 0 {
+			return result, nil
+		}
+		startKey = results.LastEvaluatedKey
 	}
 }
+
+/**
+ReleaseStore implementation backed by a DynamoDB table. BranchSettings, Channels, promoted copies of releases and
+Products are kept in tables of their own, as are download counts.
+*/
+type DynamoStore struct {
+	Client              dynamodbiface.DynamoDBAPI
+	TableName           string
+	BranchesTableName   string
+	ChannelsTableName   string
+	PromotionsTableName string
+	ProductsTableName   string
+	DownloadsTableName  string
+	//whether the SemverIndexName index has been created on TableName. It is added by a second deploy, after the
+	//BranchIndexName index, because DynamoDB only creates one index per table update.
+	SemverIndexEnabled bool
+}
+
+func NewDynamoStore(client dynamodbiface.DynamoDBAPI, tableName string) *DynamoStore {
+	return &DynamoStore{Client: client, TableName: tableName}
+}
+
+/**
+return a DynamoStore for the table in the DYNAMO_TABLE_NAME environment variable, the branches table in
+BRANCHES_TABLE_NAME, the channels table in CHANNELS_TABLE_NAME, the promotions table in PROMOTIONS_TABLE_NAME, the
+products table in PRODUCTS_TABLE_NAME and the downloads table in DOWNLOADS_TABLE_NAME, using the default AWS session.
+The semver index is used if SEMVER_INDEX_ENABLED is "true".
+*/
+func NewDynamoStoreFromEnvironment() *DynamoStore {
+	//set up an AWS session to communicate with Dynamo
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	store := NewDynamoStore(dynamodb.New(sess), os.Getenv("DYNAMO_TABLE_NAME"))
+	store.BranchesTableName = os.Getenv("BRANCHES_TABLE_NAME")
+	store.ChannelsTableName = os.Getenv("CHANNELS_TABLE_NAME")
+	store.PromotionsTableName = os.Getenv("PROMOTIONS_TABLE_NAME")
+	store.ProductsTableName = os.Getenv("PRODUCTS_TABLE_NAME")
+	store.DownloadsTableName = os.Getenv("DOWNLOADS_TABLE_NAME")
+	store.SemverIndexEnabled = os.Getenv("SEMVER_INDEX_ENABLED") == "true"
+	return store
+}
+
+func (s *DynamoStore) LogRelease(ev *NewReleaseEvent) error {
+	return ev.LogRelease(s.Client, s.TableName)
+}
+
+func (s *DynamoStore) CreateRelease(ev *NewReleaseEvent) error {
+	return ev.CreateRelease(s.Client, s.TableName)
+}
+
+func (s *DynamoStore) MostRecentRelease(productName string, branch string) (*NewReleaseEvent, error) {
+	return MostRecentRelease(s.Client, s.TableName, productName, branch)
+}
+
+func (s *DynamoStore) GetRelease(productName string, buildId int) (*NewReleaseEvent, error) {
+	return GetRelease(s.Client, s.TableName, productName, buildId)
+}
+
+func (s *DynamoStore) ListReleases(query *ReleaseQuery) (*ReleasePage, error) {
+	return ListReleases(s.Client, s.TableName, query)
+}
+
+func (s *DynamoStore) HasSemverIndex() bool {
+	return s.SemverIndexEnabled
+}
+
+func (s *DynamoStore) EachReleaseBySemver(productName string, branch string, fn func(ev *NewReleaseEvent) bool) error {
+	return EachReleaseBySemver(s.Client, s.TableName, productName, branch, fn)
+}
+
+func (s *DynamoStore) DeleteRelease(productName string, buildId int) error {
+	return DeleteRelease(s.Client, s.TableName, productName, buildId)
+}
+
+func (s *DynamoStore) UpdateRolloutPercentage(productName string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	return UpdateRolloutPercentage(s.Client, s.TableName, productName, buildId, percentage)
+}
+
+func (s *DynamoStore) MarkWithdrawn(productName string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	return MarkWithdrawn(s.Client, s.TableName, productName, buildId, reason, withdrawnAt)
+}
+
+func (s *DynamoStore) GetBranchSettings(productName string, branch string) (*BranchSettings, error) {
+	return GetBranchSettings(s.Client, s.BranchesTableName, productName, branch)
+}
+
+func (s *DynamoStore) PutBranchSettings(settings *BranchSettings) error {
+	return PutBranchSettings(s.Client, s.BranchesTableName, settings)
+}
+
+func (s *DynamoStore) GetChannel(productName string, name string) (*Channel, error) {
+	return GetChannel(s.Client, s.ChannelsTableName, productName, name)
+}
+
+func (s *DynamoStore) ListChannels(productName string) ([]Channel, error) {
+	return ListChannels(s.Client, s.ChannelsTableName, productName)
+}
+
+func (s *DynamoStore) PutChannel(channel *Channel) error {
+	return PutChannel(s.Client, s.ChannelsTableName, channel)
+}
+
+func (s *DynamoStore) DeleteChannel(productName string, name string) error {
+	return DeleteChannel(s.Client, s.ChannelsTableName, productName, name)
+}
+
+func (s *DynamoStore) PutPromotion(ev *NewReleaseEvent) error {
+	return PutPromotion(s.Client, s.PromotionsTableName, ev)
+}
+
+func (s *DynamoStore) GetPromotion(productName string, branch string, buildId int) (*NewReleaseEvent, error) {
+	return GetPromotion(s.Client, s.PromotionsTableName, productName, branch, buildId)
+}
+
+func (s *DynamoStore) UpdatePromotionRolloutPercentage(productName string, branch string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	return UpdatePromotionRolloutPercentage(s.Client, s.PromotionsTableName, productName, branch, buildId, percentage)
+}
+
+func (s *DynamoStore) MarkPromotionWithdrawn(productName string, branch string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	return MarkPromotionWithdrawn(s.Client, s.PromotionsTableName, productName, branch, buildId, reason, withdrawnAt)
+}
+
+func (s *DynamoStore) AddPromotedTo(productName string, buildId int, branch string) (*NewReleaseEvent, error) {
+	return AddPromotedTo(s.Client, s.TableName, productName, buildId, branch)
+}
+
+func (s *DynamoStore) ListPromotions(productName string, branch string) ([]NewReleaseEvent, error) {
+	return ListPromotions(s.Client, s.PromotionsTableName, productName, branch)
+}
+
+func (s *DynamoStore) GetProduct(productName string) (*Product, error) {
+	return GetProduct(s.Client, s.ProductsTableName, productName)
+}
+
+func (s *DynamoStore) ListProducts() ([]Product, error) {
+	return ListProducts(s.Client, s.ProductsTableName)
+}
+
+func (s *DynamoStore) PutProduct(product *Product) error {
+	return PutProduct(s.Client, s.ProductsTableName, product)
+}
+
+func (s *DynamoStore) CountDownload(productName string, buildId int, platform string) error {
+	return CountDownload(s.Client, s.DownloadsTableName, productName, buildId, platform)
+}
+
+func (s *DynamoStore) DownloadCounts(productName string, buildId int) ([]DownloadCount, error) {
+	return DownloadCounts(s.Client, s.DownloadsTableName, productName, buildId)
+}
@@ -3,10 +3,13 @@ package common
 import (
 	"errors"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/davecgh/go-spew/spew"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +21,152 @@ func (*MockedDynamo) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOut
 	if *input.TableName == "successtest" {
 		out := dynamodb.PutItemOutput{}
 		return &out, nil
+	} else if *input.TableName == "semverkeytest" {
+		if input.Item["semverKey"] == nil || *input.Item["semverKey"].S != "00000000000000000002.00000000000000000014.00000000000000000000!1rc!000000000000000000003" {
+			return nil, errors.New("semverKey was not set on the item")
+		}
+		out := dynamodb.PutItemOutput{}
+		return &out, nil
+	} else if *input.TableName == "conditionaltest" {
+		if input.ConditionExpression == nil || *input.ConditionExpression != "attribute_not_exists(buildId)" {
+			return nil, errors.New("put was not conditional")
+		}
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	} else if *input.TableName == "branchkeytest" {
+		if input.Item["productBranch"] == nil || *input.Item["productBranch"].S != "test product#master" {
+			return nil, errors.New("productBranch was not set on the item")
+		}
+		out := dynamodb.PutItemOutput{}
+		return &out, nil
+	} else {
+		return nil, errors.New("kaboom!")
+	}
+}
+
+func (*MockedDynamo) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName == "productstest" {
+		if *input.Key["productName"].S != "test product" {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		record, _ := dynamodbattribute.MarshalMap(Product{ProductName: "test product", DefaultBranch: "main"})
+		return &dynamodb.GetItemOutput{Item: record}, nil
+	} else if *input.TableName == "promotionstest" {
+		if *input.Key["productBranch"].S != "test product#master" || *input.Key["buildId"].N != "25" {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{
+			BuildId:      25,
+			Branch:       "master",
+			DownloadUrl:  "https://some/url/25",
+			ProductName:  "test product",
+			PromotedFrom: "somebranch",
+		})
+		return &dynamodb.GetItemOutput{Item: record}, nil
+	} else if *input.TableName == "channelstest" {
+		if *input.Key["name"].S != "beta" {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		record, _ := dynamodbattribute.MarshalMap(Channel{
+			ProductName: *input.Key["productName"].S,
+			Name:        "beta",
+			Branches:    []string{"develop"},
+			Fallback:    "stable",
+		})
+		return &dynamodb.GetItemOutput{Item: record}, nil
+	} else if *input.TableName == "branchestest" {
+		if *input.Key["branch"].S != "master" {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		record, _ := dynamodbattribute.MarshalMap(BranchSettings{
+			ProductName:    *input.Key["productName"].S,
+			Branch:         "master",
+			MinimumBuildId: 20,
+		})
+		return &dynamodb.GetItemOutput{Item: record}, nil
+	} else if *input.TableName == "recordstest" {
+		if *input.Key["buildId"].N != "25" {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{
+			Event:       "test",
+			BuildId:     25,
+			Branch:      "somebranch",
+			DownloadUrl: "https://some/url/25",
+			ProductName: *input.Key["productName"].S,
+		})
+		return &dynamodb.GetItemOutput{Item: record}, nil
+	} else {
+		return nil, errors.New("kaboom!")
+	}
+}
+
+func (*MockedDynamo) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	if *input.TableName == "productstest" {
+		//two pages, out of order, as a scan would return them
+		var name string
+		var lastKey map[string]*dynamodb.AttributeValue
+		if input.ExclusiveStartKey == nil {
+			name = "zebra"
+			lastKey = map[string]*dynamodb.AttributeValue{"productName": {S: aws.String("zebra")}}
+		} else {
+			name = "aardvark"
+		}
+		record, _ := dynamodbattribute.MarshalMap(Product{ProductName: name, DefaultBranch: "main"})
+		return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{record}, LastEvaluatedKey: lastKey}, nil
+	} else {
+		return nil, errors.New("kaboom!")
+	}
+}
+
+func (*MockedDynamo) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if *input.TableName == "downloadstest" {
+		if *input.Key["productName"].S != "test product" || *input.Key["countKey"].S != "12:macos/arm64" {
+			return nil, errors.New("update was not for the right count")
+		}
+		if *input.UpdateExpression != "ADD downloads :one SET buildId = :buildId" || *input.ExpressionAttributeValues[":one"].N != "1" {
+			return nil, errors.New("update did not add one to the count")
+		}
+		return &dynamodb.UpdateItemOutput{}, nil
+	} else if *input.TableName == "recordstest" {
+		if !strings.HasPrefix(*input.UpdateExpression, "SET promotedTo = list_append(") || !strings.Contains(*input.ConditionExpression, "NOT contains(promotedTo, :branch)") {
+			return nil, errors.New("update should only append to promotedTo if the branch isn't there")
+		}
+		if *input.Key["buildId"].N != "26" {
+			//25 already lists the branch and 99 doesn't exist
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{BuildId: 26, Branch: "somebranch", ProductName: "test product", Withdrawn: true,
+			PromotedTo: []string{*input.ExpressionAttributeValues[":branch"].S}})
+		return &dynamodb.UpdateItemOutput{Attributes: record}, nil
+	} else if *input.TableName == "withdrawtest" {
+		if *input.UpdateExpression != setWithdrawnExpression || input.ConditionExpression == nil {
+			return nil, errors.New("update should only set the withdrawal of an existing record")
+		}
+		if input.Key["buildId"] == nil || *input.Key["buildId"].N != "26" {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+		percentage := 25
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{BuildId: 26, Branch: "master", ProductName: "test product", RolloutPercentage: &percentage,
+			Withdrawn: *input.ExpressionAttributeValues[":withdrawn"].BOOL, WithdrawnReason: *input.ExpressionAttributeValues[":reason"].S})
+		return &dynamodb.UpdateItemOutput{Attributes: record}, nil
+	} else if *input.TableName == "rollouttest" {
+		if *input.UpdateExpression != "SET rolloutPercentage = :percentage" || input.ConditionExpression == nil {
+			return nil, errors.New("update should only set the rollout percentage of an existing record")
+		}
+		if input.Key["buildId"] == nil || *input.Key["buildId"].N != "26" {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+		percentage, _ := strconv.Atoi(*input.ExpressionAttributeValues[":percentage"].N)
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{BuildId: 26, Branch: "master", ProductName: "test product", Withdrawn: true, RolloutPercentage: &percentage})
+		return &dynamodb.UpdateItemOutput{Attributes: record}, nil
+	} else {
+		return nil, errors.New("kaboom!")
+	}
+}
+
+func (*MockedDynamo) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	if *input.TableName == "successtest" {
+		return &dynamodb.DeleteItemOutput{}, nil
 	} else {
 		return nil, errors.New("kaboom!")
 	}
@@ -71,6 +220,148 @@ func (*MockedDynamo) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, e
 			Count: aws.Int64(0),
 		}
 		return out, nil
+	} else if *input.TableName == "listtest" {
+		/* first page returns a LastEvaluatedKey, the second page (requested with that key) does not */
+		var buildId int
+		var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+		if input.ExclusiveStartKey == nil {
+			buildId = 26
+			lastEvaluatedKey = map[string]*dynamodb.AttributeValue{
+				"productName": {S: aws.String("test product")},
+				"buildId":     {N: aws.String("26")},
+			}
+		} else {
+			buildId = 25
+		}
+
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{
+			Event:       "test",
+			BuildId:     buildId,
+			Branch:      "somebranch",
+			DownloadUrl: "https://some/url",
+			ProductName: "test product",
+		})
+		out := &dynamodb.QueryOutput{
+			Items:            []map[string]*dynamodb.AttributeValue{record},
+			Count:            aws.Int64(1),
+			LastEvaluatedKey: lastEvaluatedKey,
+		}
+		return out, nil
+	} else if *input.TableName == "pagedtest" {
+		/* the first two pages come back empty, the third has the record */
+		var pageNumber int64
+		if input.ExclusiveStartKey != nil {
+			pageNumber, _ = strconv.ParseInt(*input.ExclusiveStartKey["page"].N, 10, 64)
+		}
+
+		if pageNumber < 2 {
+			out := &dynamodb.QueryOutput{
+				Items: []map[string]*dynamodb.AttributeValue{},
+				Count: aws.Int64(0),
+				LastEvaluatedKey: map[string]*dynamodb.AttributeValue{
+					"page": {N: aws.String(strconv.FormatInt(pageNumber+1, 10))},
+				},
+			}
+			return out, nil
+		}
+
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{
+			Event:       "test",
+			BuildId:     3,
+			Branch:      "quietbranch",
+			DownloadUrl: "https://some/url/3",
+			ProductName: "test product",
+		})
+		out := &dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{record},
+			Count: aws.Int64(1),
+		}
+		return out, nil
+	} else if *input.TableName == "promotionstest" {
+		if *input.ExpressionAttributeValues[":productBranchSubst"].S != "test product#master" {
+			return nil, errors.New("query was not for the right branch")
+		}
+		if input.ScanIndexForward == nil || *input.ScanIndexForward {
+			return nil, errors.New("promotions should be read newest first")
+		}
+		records := make([]map[string]*dynamodb.AttributeValue, 0, 2)
+		for _, buildId := range []int{27, 25} {
+			record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{BuildId: buildId, Branch: "master", ProductName: "test product", PromotedFrom: "somebranch"})
+			records = append(records, record)
+		}
+		return &dynamodb.QueryOutput{Items: records, Count: aws.Int64(2)}, nil
+	} else if *input.TableName == "channelstest" {
+		if *input.ExpressionAttributeValues[":productNameSubst"].S != "test product" {
+			return nil, errors.New("query was not for the right product")
+		}
+		//two pages, to check that every page is read
+		var name string
+		var lastKey map[string]*dynamodb.AttributeValue
+		if input.ExclusiveStartKey == nil {
+			name = "beta"
+			lastKey = map[string]*dynamodb.AttributeValue{"name": {S: aws.String("beta")}}
+		} else {
+			name = "stable"
+		}
+		record, _ := dynamodbattribute.MarshalMap(Channel{ProductName: "test product", Name: name, Branches: []string{"master"}})
+		out := &dynamodb.QueryOutput{
+			Items:            []map[string]*dynamodb.AttributeValue{record},
+			Count:            aws.Int64(1),
+			LastEvaluatedKey: lastKey,
+		}
+		return out, nil
+	} else if *input.TableName == "withdrawntest" {
+		notWithdrawn := input.ExpressionAttributeValues[":notWithdrawn"]
+		if input.FilterExpression == nil || *input.FilterExpression != notWithdrawnFilter || notWithdrawn == nil || *notWithdrawn.BOOL != false {
+			return nil, errors.New("query did not filter out withdrawn releases")
+		}
+		out := &dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{},
+			Count: aws.Int64(0),
+		}
+		return out, nil
+	} else if *input.TableName == "indextest" {
+		if input.IndexName == nil || *input.IndexName != BranchIndexName {
+			return nil, errors.New("query did not use the branch index")
+		}
+		if *input.ExpressionAttributeValues[":productBranchSubst"].S != "test product#somebranch" {
+			return nil, errors.New("query did not use the composite productBranch key")
+		}
+		if input.FilterExpression != nil && *input.FilterExpression != notWithdrawnFilter {
+			return nil, errors.New("query should not need a filter other than for withdrawn releases")
+		}
+		out := &dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{},
+			Count: aws.Int64(0),
+		}
+		return out, nil
+	} else if *input.TableName == "semverindextest" {
+		if input.IndexName == nil || *input.IndexName != SemverIndexName || input.ScanIndexForward == nil || *input.ScanIndexForward {
+			return nil, errors.New("query did not read the semver index highest first")
+		}
+		if input.FilterExpression == nil || *input.FilterExpression != notWithdrawnFilter {
+			return nil, errors.New("query did not filter out withdrawn releases")
+		}
+		//two pages, to check that the next one is only read if it is needed
+		semver := "2.15.0"
+		var lastKey map[string]*dynamodb.AttributeValue
+		if input.ExclusiveStartKey == nil {
+			lastKey = map[string]*dynamodb.AttributeValue{"semverKey": {S: aws.String("page1")}}
+		} else {
+			semver = "2.14.0"
+		}
+		record, _ := dynamodbattribute.MarshalMap(NewReleaseEvent{BuildId: 10, Branch: "master", ProductName: "test product", Semver: semver})
+		return &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{record}, Count: aws.Int64(1), LastEvaluatedKey: lastKey}, nil
+	} else if *input.TableName == "downloadstest" {
+		if *input.ExpressionAttributeValues[":productNameSubst"].S != "test product" || *input.ExpressionAttributeValues[":prefixSubst"].S != "12:" {
+			return nil, errors.New("query was not for the right build")
+		}
+		records := make([]map[string]*dynamodb.AttributeValue, 0, 2)
+		for _, key := range []string{"12:any", "12:macos/arm64"} {
+			record, _ := dynamodbattribute.MarshalMap(DownloadCount{ProductName: "test product", BuildId: 12, Downloads: 3, CountKey: key})
+			records = append(records, record)
+		}
+		return &dynamodb.QueryOutput{Items: records, Count: aws.Int64(2)}, nil
 	} else if *input.TableName == "failtest" {
 		return nil, errors.New("Kaboom!")
 	} else {
@@ -94,12 +385,51 @@ func TestNewReleaseEvent_LogRelease(t *testing.T) {
 		t.Errorf("put test should have succeeded but got %s", err)
 	}
 
+	keyErr := evt.LogRelease(dynamoClient, "branchkeytest")
+	if keyErr != nil {
+		t.Errorf("put test should have set the productBranch key but got %s", keyErr)
+	}
+
+	semverEvt := evt
+	semverEvt.Semver = "2.14.0-rc.3"
+	semverErr := semverEvt.LogRelease(dynamoClient, "semverkeytest")
+	if semverErr != nil {
+		t.Errorf("put test should have set the semverKey but got %s", semverErr)
+	}
+
 	shouldErr := evt.LogRelease(dynamoClient, "failtest")
 	if shouldErr == nil {
 		t.Errorf("put test should have failed but returned no error")
 	}
 }
 
+func TestNewReleaseEvent_CreateRelease(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	evt := NewReleaseEvent{
+		Event:       "test",
+		BuildId:     1234,
+		Branch:      "master",
+		DownloadUrl: "https://some/url",
+		ProductName: "test product",
+	}
+
+	err := evt.CreateRelease(dynamoClient, "successtest")
+	if err != nil {
+		t.Errorf("create test should have succeeded but got %s", err)
+	}
+
+	existsErr := evt.CreateRelease(dynamoClient, "conditionaltest")
+	if existsErr != ErrReleaseExists {
+		t.Errorf("create test of an existing record should have returned ErrReleaseExists but got %s", existsErr)
+	}
+
+	shouldErr := evt.CreateRelease(dynamoClient, "failtest")
+	if shouldErr == nil || shouldErr == ErrReleaseExists {
+		t.Errorf("create test should have failed but got %s", shouldErr)
+	}
+}
+
 func TestMostRecentRelease(t *testing.T) {
 	dynamoClient := &MockedDynamo{}
 
@@ -139,3 +469,422 @@ func TestMostRecentRelease(t *testing.T) {
 		t.Errorf("failure test should have returned nil but got %s", spew.Sprint(*failedResult))
 	}
 }
+
+func TestMostRecentRelease_Paginated(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := MostRecentRelease(dynamoClient, "pagedtest", "test product", "quietbranch")
+	if err != nil {
+		t.Errorf("paged read test should have succeeded but got %s", err)
+		return
+	}
+	if result == nil {
+		t.Errorf("paged read test should have followed LastEvaluatedKey to find a record but returned nil")
+		return
+	}
+	if result.BuildId != 3 {
+		t.Errorf("returned build id was wrong, got %d", result.BuildId)
+	}
+}
+
+func TestMostRecentRelease_UsesBranchIndex(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	_, err := MostRecentRelease(dynamoClient, "indextest", "test product", "somebranch")
+	if err != nil {
+		t.Errorf("index test should have succeeded but got %s", err)
+	}
+
+	_, listErr := ListReleases(dynamoClient, "indextest", &ReleaseQuery{ProductName: "test product", Branch: "somebranch", PageSize: 1})
+	if listErr != nil {
+		t.Errorf("list index test should have succeeded but got %s", listErr)
+	}
+}
+
+func TestMostRecentRelease_SkipsWithdrawn(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	_, err := MostRecentRelease(dynamoClient, "withdrawntest", "test product", "somebranch")
+	if err != nil {
+		t.Errorf("withdrawn test should have succeeded but got %s", err)
+	}
+}
+
+func TestListReleases(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	query := &ReleaseQuery{ProductName: "test product", Branch: "somebranch", PageSize: 1}
+	firstPage, err := ListReleases(dynamoClient, "listtest", query)
+	if err != nil {
+		t.Errorf("list test should have succeeded but got %s", err)
+		return
+	}
+	if len(firstPage.Releases) != 1 || firstPage.Releases[0].BuildId != 26 {
+		t.Errorf("first page was wrong, got %s", spew.Sprint(firstPage.Releases))
+	}
+	if firstPage.NextPageToken == "" {
+		t.Errorf("first page should have had a next page token")
+	}
+
+	query.PageToken = firstPage.NextPageToken
+	secondPage, err := ListReleases(dynamoClient, "listtest", query)
+	if err != nil {
+		t.Errorf("list test for second page should have succeeded but got %s", err)
+		return
+	}
+	if len(secondPage.Releases) != 1 || secondPage.Releases[0].BuildId != 25 {
+		t.Errorf("second page was wrong, got %s", spew.Sprint(secondPage.Releases))
+	}
+	if secondPage.NextPageToken != "" {
+		t.Errorf("second page should not have had a next page token but got %s", secondPage.NextPageToken)
+	}
+
+	query.PageToken = "not a real token!"
+	_, tokenErr := ListReleases(dynamoClient, "listtest", query)
+	if tokenErr != ErrInvalidPageToken {
+		t.Errorf("invalid page token should have returned ErrInvalidPageToken but got %s", tokenErr)
+	}
+
+	failedResult, failedErr := ListReleases(dynamoClient, "failtest", &ReleaseQuery{ProductName: "test product", PageSize: 1})
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+	if failedResult != nil {
+		t.Errorf("failure test should have returned nil but got %s", spew.Sprint(*failedResult))
+	}
+}
+
+func TestGetRelease(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := GetRelease(dynamoClient, "recordstest", "test product", 25)
+	if err != nil {
+		t.Errorf("get test should have succeeded but got %s", err)
+	} else if result == nil || result.BuildId != 25 || result.ProductName != "test product" {
+		t.Errorf("get test returned the wrong record: %s", spew.Sprint(result))
+	}
+
+	missing, err := GetRelease(dynamoClient, "recordstest", "test product", 1)
+	if err != nil || missing != nil {
+		t.Errorf("get test for a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	_, failedErr := GetRelease(dynamoClient, "failtest", "test product", 25)
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestDeleteRelease(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	if err := DeleteRelease(dynamoClient, "successtest", "test product", 25); err != nil {
+		t.Errorf("delete test should have succeeded but got %s", err)
+	}
+	if err := DeleteRelease(dynamoClient, "failtest", "test product", 25); err == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestGetBranchSettings(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := GetBranchSettings(dynamoClient, "branchestest", "test product", "master")
+	if err != nil {
+		t.Errorf("get test should have succeeded but got %s", err)
+	} else if result == nil || result.MinimumBuildId != 20 || result.ProductName != "test product" {
+		t.Errorf("get test returned the wrong record: %s", spew.Sprint(result))
+	}
+
+	missing, err := GetBranchSettings(dynamoClient, "branchestest", "test product", "somebranch")
+	if err != nil || missing != nil {
+		t.Errorf("get test for a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	_, failedErr := GetBranchSettings(dynamoClient, "failtest", "test product", "master")
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestPutBranchSettings(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	if err := PutBranchSettings(dynamoClient, "successtest", &BranchSettings{ProductName: "test product", Branch: "master"}); err != nil {
+		t.Errorf("success test should have succeeded but got %s", err)
+	}
+	if err := PutBranchSettings(dynamoClient, "failtest", &BranchSettings{ProductName: "test product", Branch: "master"}); err == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestGetChannel(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := GetChannel(dynamoClient, "channelstest", "test product", "beta")
+	if err != nil {
+		t.Errorf("get test should have succeeded but got %s", err)
+	} else if result == nil || result.Fallback != "stable" || len(result.Branches) != 1 {
+		t.Errorf("get test returned the wrong record: %s", spew.Sprint(result))
+	}
+
+	missing, err := GetChannel(dynamoClient, "channelstest", "test product", "nightly")
+	if err != nil || missing != nil {
+		t.Errorf("get test for a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	_, failedErr := GetChannel(dynamoClient, "failtest", "test product", "beta")
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestListChannels(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := ListChannels(dynamoClient, "channelstest", "test product")
+	if err != nil {
+		t.Errorf("list test should have succeeded but got %s", err)
+	} else if len(result) != 2 || result[0].Name != "beta" || result[1].Name != "stable" {
+		t.Errorf("list test returned the wrong records: %s", spew.Sprint(result))
+	}
+
+	_, failedErr := ListChannels(dynamoClient, "failtest", "test product")
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestPutAndDeleteChannel(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+	channel := &Channel{ProductName: "test product", Name: "beta", Branches: []string{"develop"}}
+
+	if err := PutChannel(dynamoClient, "successtest", channel); err != nil {
+		t.Errorf("put success test should have succeeded but got %s", err)
+	}
+	if err := PutChannel(dynamoClient, "failtest", channel); err == nil {
+		t.Errorf("put failure test should have failed but got nil error")
+	}
+	if err := DeleteChannel(dynamoClient, "successtest", "test product", "beta"); err != nil {
+		t.Errorf("delete success test should have succeeded but got %s", err)
+	}
+	if err := DeleteChannel(dynamoClient, "failtest", "test product", "beta"); err == nil {
+		t.Errorf("delete failure test should have failed but got nil error")
+	}
+}
+
+func TestPutPromotion(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+	promoted := &NewReleaseEvent{BuildId: 25, Branch: "master", DownloadUrl: "https://some/url/25", ProductName: "test product", PromotedFrom: "somebranch"}
+
+	//branchkeytest checks that productBranch is the key of the branch it was promoted to
+	if err := PutPromotion(dynamoClient, "branchkeytest", promoted); err != nil {
+		t.Errorf("put test should have succeeded but got %s", err)
+	}
+	if err := PutPromotion(dynamoClient, "failtest", promoted); err == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestGetPromotion(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := GetPromotion(dynamoClient, "promotionstest", "test product", "master", 25)
+	if err != nil {
+		t.Errorf("get test should have succeeded but got %s", err)
+	} else if result == nil || result.PromotedFrom != "somebranch" || result.Branch != "master" {
+		t.Errorf("get test returned the wrong record: %s", spew.Sprint(result))
+	}
+
+	missing, err := GetPromotion(dynamoClient, "promotionstest", "test product", "develop", 25)
+	if err != nil || missing != nil {
+		t.Errorf("get test for a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+}
+
+func TestListPromotions(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := ListPromotions(dynamoClient, "promotionstest", "test product", "master")
+	if err != nil {
+		t.Errorf("list test should have succeeded but got %s", err)
+	} else if len(result) != 2 || result[0].BuildId != 27 || result[1].BuildId != 25 {
+		t.Errorf("list test returned the wrong records: %s", spew.Sprint(result))
+	}
+
+	_, failedErr := ListPromotions(dynamoClient, "failtest", "test product", "master")
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestGetProduct(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := GetProduct(dynamoClient, "productstest", "test product")
+	if err != nil {
+		t.Errorf("get test should have succeeded but got %s", err)
+	} else if result == nil || result.DefaultBranch != "main" {
+		t.Errorf("get test returned the wrong record: %s", spew.Sprint(result))
+	}
+
+	missing, err := GetProduct(dynamoClient, "productstest", "other product")
+	if err != nil || missing != nil {
+		t.Errorf("get test for a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	_, failedErr := GetProduct(dynamoClient, "failtest", "test product")
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestPutProduct(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+	product := &Product{ProductName: "test product", DefaultBranch: "main"}
+
+	if err := PutProduct(dynamoClient, "successtest", product); err != nil {
+		t.Errorf("put success test should have succeeded but got %s", err)
+	}
+	if err := PutProduct(dynamoClient, "failtest", product); err == nil {
+		t.Errorf("put failure test should have failed but got nil error")
+	}
+}
+
+func TestListProducts(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := ListProducts(dynamoClient, "productstest")
+	if err != nil {
+		t.Errorf("list test should have succeeded but got %s", err)
+	} else if len(result) != 2 || result[0].ProductName != "aardvark" || result[1].ProductName != "zebra" {
+		t.Errorf("list test returned the wrong records: %s", spew.Sprint(result))
+	}
+
+	_, failedErr := ListProducts(dynamoClient, "failtest")
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestCountDownload(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	if err := CountDownload(dynamoClient, "downloadstest", "test product", 12, "macos/arm64"); err != nil {
+		t.Errorf("count test should have succeeded but got %s", err)
+	}
+	if err := CountDownload(dynamoClient, "failtest", "test product", 12, "macos/arm64"); err == nil {
+		t.Errorf("count failure test should have failed but got nil error")
+	}
+}
+
+func TestDownloadCounts(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := DownloadCounts(dynamoClient, "downloadstest", "test product", 12)
+	if err != nil {
+		t.Errorf("counts test should have succeeded but got %s", err)
+	} else if len(result) != 2 || result[0].Platform != AnyPlatform || result[1].Platform != "macos/arm64" || result[1].Downloads != 3 {
+		t.Errorf("counts test returned the wrong records: %s", spew.Sprint(result))
+	}
+
+	_, failedErr := DownloadCounts(dynamoClient, "failtest", "test product", 12)
+	if failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestEachReleaseBySemver(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	var versions []string
+	err := EachReleaseBySemver(dynamoClient, "semverindextest", "test product", "master", func(ev *NewReleaseEvent) bool {
+		versions = append(versions, ev.Semver)
+		return true
+	})
+	if err != nil || len(versions) != 2 || versions[0] != "2.15.0" || versions[1] != "2.14.0" {
+		t.Errorf("semver index test should have read both pages but got %v, %s", versions, err)
+	}
+
+	versions = nil
+	EachReleaseBySemver(dynamoClient, "semverindextest", "test product", "master", func(ev *NewReleaseEvent) bool {
+		versions = append(versions, ev.Semver)
+		return false
+	})
+	if len(versions) != 1 {
+		t.Errorf("semver index test should have stopped after the first release but got %v", versions)
+	}
+
+	if failedErr := EachReleaseBySemver(dynamoClient, "failtest", "test product", "master", nil); failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestUpdateRolloutPercentage(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := UpdateRolloutPercentage(dynamoClient, "rollouttest", "test product", 26, 25)
+	if err != nil || result == nil || *result.RolloutPercentage != 25 || !result.Withdrawn {
+		t.Errorf("rollout test returned the wrong record: %s, %s", spew.Sprint(result), err)
+	}
+
+	missing, err := UpdateRolloutPercentage(dynamoClient, "rollouttest", "test product", 99, 25)
+	if err != nil || missing != nil {
+		t.Errorf("rollout of a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	promoted, err := UpdatePromotionRolloutPercentage(dynamoClient, "rollouttest", "test product", "master", 26, 10)
+	if err != nil || promoted == nil || *promoted.RolloutPercentage != 10 {
+		t.Errorf("promotion rollout test returned the wrong record: %s, %s", spew.Sprint(promoted), err)
+	}
+
+	if _, failedErr := UpdateRolloutPercentage(dynamoClient, "failtest", "test product", 26, 25); failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestMarkWithdrawn(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := MarkWithdrawn(dynamoClient, "withdrawtest", "test product", 26, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || result == nil || !result.Withdrawn || result.WithdrawnReason != "bad build" || *result.RolloutPercentage != 25 {
+		t.Errorf("withdraw test returned the wrong record: %s, %s", spew.Sprint(result), err)
+	}
+
+	missing, err := MarkWithdrawn(dynamoClient, "withdrawtest", "test product", 99, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || missing != nil {
+		t.Errorf("withdrawal of a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	promoted, err := MarkPromotionWithdrawn(dynamoClient, "withdrawtest", "test product", "master", 26, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || promoted == nil || !promoted.Withdrawn {
+		t.Errorf("promotion withdraw test returned the wrong record: %s, %s", spew.Sprint(promoted), err)
+	}
+
+	if _, failedErr := MarkWithdrawn(dynamoClient, "failtest", "test product", 26, "bad build", "2019-11-04T10:00:00Z"); failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
+
+func TestAddPromotedTo(t *testing.T) {
+	dynamoClient := &MockedDynamo{}
+
+	result, err := AddPromotedTo(dynamoClient, "recordstest", "test product", 26, "master")
+	if err != nil || result == nil || len(result.PromotedTo) != 1 || result.PromotedTo[0] != "master" || !result.Withdrawn {
+		t.Errorf("promotedTo test returned the wrong record: %s, %s", spew.Sprint(result), err)
+	}
+
+	existing, err := AddPromotedTo(dynamoClient, "recordstest", "test product", 25, "master")
+	if err != nil || existing == nil || existing.BuildId != 25 {
+		t.Errorf("promotedTo test for a release that already lists the branch should have returned it but got %s, %s", spew.Sprint(existing), err)
+	}
+
+	missing, err := AddPromotedTo(dynamoClient, "recordstest", "test product", 99, "master")
+	if err != nil || missing != nil {
+		t.Errorf("promotedTo test for a missing release should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	if _, failedErr := AddPromotedTo(dynamoClient, "failtest", "test product", 26, "master"); failedErr == nil {
+		t.Errorf("failure test should have failed but got nil error")
+	}
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+//electron-updater asks for latest.yml on Windows, latest-mac.yml on macOS and latest-linux.yml (x64) or
+//latest-linux-{arch}.yml on Linux
+var electronFileName = regexp.MustCompile(`^latest(-mac|-linux(-[a-z0-9]+)?)?\.yml$`)
+
+/**
+work out the platform that electron-updater is asking about from the name of the file that it asked for.
+returns the os and arch, and false if the name isn't one that electron-updater uses
+*/
+func ElectronPlatform(fileName string) (string, string, bool) {
+	match := electronFileName.FindStringSubmatch(fileName)
+	if match == nil {
+		return "", "", false
+	}
+	switch {
+	case match[1] == "":
+		return "windows", "", true
+	case match[1] == "-mac":
+		return "macos", "", true
+	case match[2] == "":
+		return "linux", "x64", true
+	default:
+		return "linux", NormalisePlatform(match[2][1:]), true
+	}
+}
+
+type ElectronUpdateFile struct {
+	Url    string
+	Sha512 string
+	Size   int64
+}
+
+/**
+the update info document that electron-updater's generic provider reads, see
+https://www.electron.build/auto-update
+*/
+type ElectronUpdateInfo struct {
+	Version      string
+	Files        []ElectronUpdateFile
+	ReleaseDate  string
+	ReleaseNotes string
+}
+
+/**
+the downloads of a release that electron-updater can use on a platform. On Windows and macOS that is every artifact
+for the os, as one installer can cover several architectures and electron-updater picks between them itself; on
+Linux it is the artifact for the arch. Artifacts without a sha512 are left out, as electron-updater won't install
+a download that it can't check.
+*/
+func electronFiles(release *NewReleaseEvent, os string, arch string) []ElectronUpdateFile {
+	var candidates []Artifact
+	if os == "linux" {
+		if artifact := release.FindArtifact(os, arch); artifact != nil {
+			candidates = []Artifact{*artifact}
+		}
+	} else {
+		for _, artifact := range release.AllArtifacts() {
+			artifactOs := NormalisePlatform(artifact.Os)
+			if artifactOs == "" || artifactOs == os {
+				candidates = append(candidates, artifact)
+			}
+		}
+	}
+
+	files := make([]ElectronUpdateFile, 0, len(candidates))
+	for _, artifact := range candidates {
+		if artifact.Sha512 != "" {
+			files = append(files, ElectronUpdateFile{Url: artifact.Url, Sha512: artifact.Sha512, Size: artifact.Size})
+		}
+	}
+	return files
+}
+
+/**
+build the update info for a release on a platform. electron-updater compares versions with semver, so releases
+without one can't be offered.
+returns nil if the release has no semver or no downloads for the platform with a sha512
+*/
+func NewElectronUpdateInfo(release *NewReleaseEvent, os string, arch string, lang string) *ElectronUpdateInfo {
+	if release.Semver == "" {
+		return nil
+	}
+	files := electronFiles(release, os, arch)
+	if len(files) == 0 {
+		return nil
+	}
+
+	_, notes := release.ReleaseNotes.Select(lang)
+	return &ElectronUpdateInfo{
+		Version:      release.Semver,
+		Files:        files,
+		ReleaseDate:  release.Timestamp,
+		ReleaseNotes: notes,
+	}
+}
+
+/**
+render the update info as YAML. The document is simple enough to write directly; every string is double-quoted,
+which YAML reads with the same escapes as Go.
+*/
+func (u *ElectronUpdateInfo) RenderYAML() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "version: %s\n", strconv.Quote(u.Version))
+	buf.WriteString("files:\n")
+	for _, file := range u.Files {
+		fmt.Fprintf(&buf, "  - url: %s\n", strconv.Quote(file.Url))
+		fmt.Fprintf(&buf, "    sha512: %s\n", strconv.Quote(file.Sha512))
+		fmt.Fprintf(&buf, "    size: %d\n", file.Size)
+	}
+	//older versions of electron-updater only read the first file, from these
+	fmt.Fprintf(&buf, "path: %s\n", strconv.Quote(u.Files[0].Url))
+	fmt.Fprintf(&buf, "sha512: %s\n", strconv.Quote(u.Files[0].Sha512))
+	if u.ReleaseDate != "" {
+		fmt.Fprintf(&buf, "releaseDate: %s\n", strconv.Quote(u.ReleaseDate))
+	}
+	if u.ReleaseNotes != "" {
+		fmt.Fprintf(&buf, "releaseNotes: %s\n", strconv.Quote(u.ReleaseNotes))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,79 @@
+package common
+
+import "testing"
+
+const testSha512 = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEyMzQ1Njc4OTo7PD0+Pw=="
+
+func TestElectronPlatform(t *testing.T) {
+	tests := []struct {
+		fileName string
+		os       string
+		arch     string
+		valid    bool
+	}{
+		{"latest.yml", "windows", "", true},
+		{"latest-mac.yml", "macos", "", true},
+		{"latest-linux.yml", "linux", "x64", true},
+		{"latest-linux-arm64.yml", "linux", "arm64", true},
+		{"beta.yml", "", "", false},
+		{"latest.json", "", "", false},
+	}
+	for _, test := range tests {
+		os, arch, valid := ElectronPlatform(test.fileName)
+		if os != test.os || arch != test.arch || valid != test.valid {
+			t.Errorf("ElectronPlatform(%s) returned %s, %s, %t", test.fileName, os, arch, valid)
+		}
+	}
+}
+
+func TestNewElectronUpdateInfo(t *testing.T) {
+	release := NewReleaseEvent{
+		BuildId:      123,
+		ProductName:  "some product",
+		Semver:       "2.14.0",
+		Timestamp:    "2019-11-01T10:00:00Z",
+		ReleaseNotes: ReleaseNotes{"en": "Fixed \"crashes\"\n- really"},
+		Artifacts: []Artifact{
+			{Os: "windows", Arch: "x64", Url: "https://someurl.server.com/Setup-x64.exe", Size: 1234, Sha512: testSha512},
+			{Os: "windows", Arch: "ia32", Url: "https://someurl.server.com/Setup-ia32.exe", Size: 1000, Sha512: testSha512},
+			{Os: "macos", Url: "https://someurl.server.com/app.zip"},
+			{Os: "linux", Arch: "x64", Url: "https://someurl.server.com/app.AppImage", Sha512: testSha512},
+		},
+	}
+
+	windows := NewElectronUpdateInfo(&release, "windows", "", "en")
+	if windows == nil || len(windows.Files) != 2 {
+		t.Fatalf("NewElectronUpdateInfo should have listed both Windows installers but got %v", windows)
+	}
+	expected := `version: "2.14.0"
+files:
+  - url: "https://someurl.server.com/Setup-x64.exe"
+    sha512: "` + testSha512 + `"
+    size: 1234
+  - url: "https://someurl.server.com/Setup-ia32.exe"
+    sha512: "` + testSha512 + `"
+    size: 1000
+path: "https://someurl.server.com/Setup-x64.exe"
+sha512: "` + testSha512 + `"
+releaseDate: "2019-11-01T10:00:00Z"
+releaseNotes: "Fixed \"crashes\"\n- really"
+`
+	if rendered := string(windows.RenderYAML()); rendered != expected {
+		t.Errorf("RenderYAML returned the wrong document:\n%s", rendered)
+	}
+
+	linux := NewElectronUpdateInfo(&release, "linux", "x64", "en")
+	if linux == nil || len(linux.Files) != 1 || linux.Files[0].Url != "https://someurl.server.com/app.AppImage" {
+		t.Errorf("NewElectronUpdateInfo should have found the Linux x64 download but got %v", linux)
+	}
+
+	if mac := NewElectronUpdateInfo(&release, "macos", "", "en"); mac != nil {
+		t.Errorf("NewElectronUpdateInfo should have returned nil for downloads without a sha512 but got %v", mac)
+	}
+
+	noSemver := release
+	noSemver.Semver = ""
+	if info := NewElectronUpdateInfo(&noSemver, "windows", "", "en"); info != nil {
+		t.Errorf("NewElectronUpdateInfo should have returned nil for a release without a semver but got %v", info)
+	}
+}
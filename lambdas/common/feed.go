@@ -0,0 +1,131 @@
+package common
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//the most releases that are listed in a feed
+const MaxFeedEntries = 50
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+/**
+an Atom feed of releases, see RFC 4287
+*/
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  AtomAuthor  `xml:"author"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type AtomEntry struct {
+	Id      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Links   []AtomLink   `xml:"link"`
+	Content *AtomContent `xml:"content,omitempty"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type AtomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+/**
+a permanent identifier for a feed or an entry in it. Atom ids have to be IRIs that never change, so they are built
+from the product name and buildId rather than from the URL the feed happens to be served from.
+*/
+func atomId(productName string, rest ...string) string {
+	id := "urn:x-downloadmanager-versions:" + url.PathEscape(productName)
+	for _, part := range rest {
+		id += ":" + url.PathEscape(part)
+	}
+	return id
+}
+
+/**
+build the feed entry for a release. The entry links to the release's DownloadUrl, or its first artifact if it only has
+artifacts, and lists every download as an enclosure. The notes for lang, if there are any, are the content.
+*/
+func NewAtomEntry(release *NewReleaseEvent, lang string) (*AtomEntry, error) {
+	title := release.ProductName + " build " + strconv.Itoa(release.BuildId)
+	if release.Semver != "" {
+		title = release.ProductName + " " + release.Semver + " (build " + strconv.Itoa(release.BuildId) + ")"
+	}
+	entry := &AtomEntry{
+		Id:      atomId(release.ProductName, strconv.Itoa(release.BuildId), release.Branch),
+		Title:   title + " on " + release.Branch,
+		Updated: release.Timestamp,
+	}
+
+	artifacts := release.AllArtifacts()
+	if release.DownloadUrl != "" {
+		entry.Links = append(entry.Links, AtomLink{Href: release.DownloadUrl})
+	} else if len(artifacts) > 0 {
+		entry.Links = append(entry.Links, AtomLink{Href: artifacts[0].Url})
+	}
+	for _, artifact := range artifacts {
+		platform := NormalisePlatform(artifact.Os)
+		if artifact.Arch != "" {
+			platform += " " + NormalisePlatform(artifact.Arch)
+		}
+		entry.Links = append(entry.Links, AtomLink{Href: artifact.Url, Rel: "enclosure", Title: platform, Length: artifact.Size})
+	}
+
+	if len(release.ReleaseNotes) > 0 {
+		_, notes := release.ReleaseNotes.Select(lang)
+		html, renderErr := RenderNotesHTML(notes)
+		if renderErr != nil {
+			return nil, renderErr
+		}
+		entry.Content = &AtomContent{Type: "html", Body: html}
+	}
+	return entry, nil
+}
+
+/**
+render an Atom feed of entries, newest first. branch is empty for a feed of every branch of the product.
+*/
+func RenderAtomFeed(productName string, branch string, title string, entries []AtomEntry) ([]byte, error) {
+	feed := AtomFeed{
+		Xmlns:   atomNamespace,
+		Id:      atomId(productName),
+		Title:   title + " releases",
+		Author:  AtomAuthor{Name: title},
+		Entries: entries,
+	}
+	if branch != "" {
+		feed.Id = atomId(productName, "branch", branch)
+		feed.Title = title + " releases on " + branch
+	}
+
+	//the feed was last updated when its newest entry was
+	feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	if len(entries) > 0 && entries[0].Updated != "" {
+		feed.Updated = entries[0].Updated
+	}
+
+	content, marshalErr := xml.MarshalIndent(feed, "", "  ")
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return append([]byte(xml.Header), content...), nil
+}
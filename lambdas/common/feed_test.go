@@ -0,0 +1,78 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewAtomEntry(t *testing.T) {
+	release := NewReleaseEvent{
+		BuildId:      123,
+		Branch:       "release/2.14",
+		ProductName:  "some product",
+		Semver:       "2.14.0",
+		Timestamp:    "2019-11-01T10:00:00Z",
+		ReleaseNotes: ReleaseNotes{"en": "## Fixed"},
+		Artifacts: []Artifact{
+			{Os: "darwin", Arch: "arm64", Url: "https://someurl.server.com/app.dmg", Size: 1234},
+			{Os: "windows", Url: "https://someurl.server.com/setup.exe"},
+		},
+	}
+
+	entry, err := NewAtomEntry(&release, "en")
+	if err != nil {
+		t.Fatalf("NewAtomEntry should have succeeded but got %s", err)
+	}
+	if entry.Id != "urn:x-downloadmanager-versions:some%20product:123:release%2F2.14" || entry.Title != "some product 2.14.0 (build 123) on release/2.14" || entry.Updated != release.Timestamp {
+		t.Errorf("NewAtomEntry got the id, title or date wrong: %v", entry)
+	}
+	if len(entry.Links) != 3 || entry.Links[0].Href != "https://someurl.server.com/app.dmg" || entry.Links[0].Rel != "" {
+		t.Errorf("NewAtomEntry should have linked to the first artifact but got %v", entry.Links)
+	}
+	if entry.Links[1].Rel != "enclosure" || entry.Links[1].Title != "macos arm64" || entry.Links[1].Length != 1234 || entry.Links[2].Title != "windows" {
+		t.Errorf("NewAtomEntry got the enclosures wrong: %v", entry.Links)
+	}
+	if entry.Content == nil || entry.Content.Type != "html" || entry.Content.Body != "<h2>Fixed</h2>\n" {
+		t.Errorf("NewAtomEntry got the content wrong: %v", entry.Content)
+	}
+
+	single := NewReleaseEvent{BuildId: 124, Branch: "master", ProductName: "some product", DownloadUrl: "https://someurl.server.com/file"}
+	singleEntry, _ := NewAtomEntry(&single, "en")
+	if singleEntry.Title != "some product build 124 on master" || singleEntry.Links[0].Href != single.DownloadUrl || singleEntry.Content != nil {
+		t.Errorf("NewAtomEntry got a release with just a downloadUrl wrong: %v", singleEntry)
+	}
+}
+
+func TestRenderAtomFeed(t *testing.T) {
+	entry, _ := NewAtomEntry(&NewReleaseEvent{
+		BuildId:     123,
+		Branch:      "master",
+		ProductName: "some product",
+		DownloadUrl: "https://someurl.server.com/file?a=1&b=2",
+		Timestamp:   "2019-11-01T10:00:00Z",
+	}, "")
+
+	output, err := RenderAtomFeed("some product", "master", "Some Product", []AtomEntry{*entry})
+	if err != nil {
+		t.Fatalf("RenderAtomFeed should have succeeded but got %s", err)
+	}
+	expected := []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<id>urn:x-downloadmanager-versions:some%20product:branch:master</id>`,
+		`<title>Some Product releases on master</title>`,
+		`<updated>2019-11-01T10:00:00Z</updated>`,
+		`<author>`,
+		`<link href="https://someurl.server.com/file?a=1&amp;b=2"></link>`,
+		`<link href="https://someurl.server.com/file?a=1&amp;b=2" rel="enclosure"></link>`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(string(output), fragment) {
+			t.Errorf("feed should have contained %s but got %s", fragment, output)
+		}
+	}
+
+	empty, err := RenderAtomFeed("some product", "", "Some Product", nil)
+	if err != nil || !strings.Contains(string(empty), "<title>Some Product releases</title>") || !strings.Contains(string(empty), "<updated>") {
+		t.Errorf("empty feed should still have had a title and updated date but got %s, %s", empty, err)
+	}
+}
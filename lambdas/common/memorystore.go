@@ -0,0 +1,336 @@
+package common
+
+import (
+	"sort"
+	"sync"
+)
+
+/**
+ReleaseStore implementation that keeps everything in memory. Nothing is persisted, so this is only useful
+for tests and for trying the service out locally.
+*/
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	releases map[string]map[int]NewReleaseEvent
+	branches map[string]BranchSettings
+	channels map[string]map[string]Channel
+	//promoted copies, keyed by ProductBranchKey of the branch they were promoted to and then buildId
+	promotions map[string]map[int]NewReleaseEvent
+	products   map[string]Product
+	//download counts, keyed by productName, then buildId and then platform
+	downloads map[string]map[int]map[string]int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		releases:   make(map[string]map[int]NewReleaseEvent),
+		branches:   make(map[string]BranchSettings),
+		channels:   make(map[string]map[string]Channel),
+		promotions: make(map[string]map[int]NewReleaseEvent),
+		products:   make(map[string]Product),
+		downloads:  make(map[string]map[int]map[string]int64),
+	}
+}
+
+/**
+returns every release of productName, newest first. Caller must hold the mutex.
+*/
+func (s *MemoryStore) releasesFor(productName string) []NewReleaseEvent {
+	productReleases := s.releases[productName]
+	result := make([]NewReleaseEvent, 0, len(productReleases))
+	for _, ev := range productReleases {
+		result = append(result, ev)
+	}
+	sortNewestFirst(result)
+	return result
+}
+
+func (s *MemoryStore) LogRelease(ev *NewReleaseEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	productReleases, haveProduct := s.releases[ev.ProductName]
+	if !haveProduct {
+		productReleases = make(map[int]NewReleaseEvent)
+		s.releases[ev.ProductName] = productReleases
+	}
+	productReleases[ev.BuildId] = *ev
+	return nil
+}
+
+func (s *MemoryStore) CreateRelease(ev *NewReleaseEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	productReleases, haveProduct := s.releases[ev.ProductName]
+	if !haveProduct {
+		productReleases = make(map[int]NewReleaseEvent)
+		s.releases[ev.ProductName] = productReleases
+	}
+	if _, exists := productReleases[ev.BuildId]; exists {
+		return ErrReleaseExists
+	}
+	productReleases[ev.BuildId] = *ev
+	return nil
+}
+
+func (s *MemoryStore) MostRecentRelease(productName string, branch string) (*NewReleaseEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return mostRecentOf(s.releasesFor(productName), branch), nil
+}
+
+func (s *MemoryStore) GetRelease(productName string, buildId int) (*NewReleaseEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ev, haveRelease := s.releases[productName][buildId]
+	if !haveRelease {
+		return nil, nil
+	}
+	return &ev, nil
+}
+
+func (s *MemoryStore) ListReleases(query *ReleaseQuery) (*ReleasePage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return pageOfReleases(s.releasesFor(query.ProductName), query)
+}
+
+func (s *MemoryStore) DeleteRelease(productName string, buildId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.releases[productName], buildId)
+	return nil
+}
+
+/**
+apply change to the record for buildId in records, which can be nil, in place. Caller must hold the mutex for writing.
+returns the updated record, or nil if there is none
+*/
+func memoryUpdateRecord(records map[int]NewReleaseEvent, buildId int, change func(ev *NewReleaseEvent)) *NewReleaseEvent {
+	ev, haveRecord := records[buildId]
+	if !haveRecord {
+		return nil
+	}
+	change(&ev)
+	records[buildId] = ev
+	return &ev
+}
+
+func (s *MemoryStore) UpdateRolloutPercentage(productName string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return memoryUpdateRecord(s.releases[productName], buildId, func(ev *NewReleaseEvent) {
+		ev.RolloutPercentage = &percentage
+	}), nil
+}
+
+func (s *MemoryStore) MarkWithdrawn(productName string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return memoryUpdateRecord(s.releases[productName], buildId, func(ev *NewReleaseEvent) {
+		ev.markWithdrawn(reason, withdrawnAt)
+	}), nil
+}
+
+func (s *MemoryStore) GetBranchSettings(productName string, branch string) (*BranchSettings, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	settings, haveSettings := s.branches[ProductBranchKey(productName, branch)]
+	if !haveSettings {
+		return nil, nil
+	}
+	return &settings, nil
+}
+
+func (s *MemoryStore) PutBranchSettings(settings *BranchSettings) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.branches[ProductBranchKey(settings.ProductName, settings.Branch)] = *settings
+	return nil
+}
+
+func (s *MemoryStore) GetChannel(productName string, name string) (*Channel, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	channel, haveChannel := s.channels[productName][name]
+	if !haveChannel {
+		return nil, nil
+	}
+	return &channel, nil
+}
+
+func (s *MemoryStore) ListChannels(productName string) ([]Channel, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]Channel, 0, len(s.channels[productName]))
+	for _, channel := range s.channels[productName] {
+		result = append(result, channel)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (s *MemoryStore) PutChannel(channel *Channel) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	productChannels, haveProduct := s.channels[channel.ProductName]
+	if !haveProduct {
+		productChannels = make(map[string]Channel)
+		s.channels[channel.ProductName] = productChannels
+	}
+	productChannels[channel.Name] = *channel
+	return nil
+}
+
+func (s *MemoryStore) DeleteChannel(productName string, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.channels[productName], name)
+	return nil
+}
+
+func (s *MemoryStore) PutPromotion(ev *NewReleaseEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := ProductBranchKey(ev.ProductName, ev.Branch)
+	branchPromotions, haveBranch := s.promotions[key]
+	if !haveBranch {
+		branchPromotions = make(map[int]NewReleaseEvent)
+		s.promotions[key] = branchPromotions
+	}
+	branchPromotions[ev.BuildId] = *ev
+	return nil
+}
+
+func (s *MemoryStore) GetPromotion(productName string, branch string, buildId int) (*NewReleaseEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ev, havePromotion := s.promotions[ProductBranchKey(productName, branch)][buildId]
+	if !havePromotion {
+		return nil, nil
+	}
+	return &ev, nil
+}
+
+func (s *MemoryStore) UpdatePromotionRolloutPercentage(productName string, branch string, buildId int, percentage int) (*NewReleaseEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return memoryUpdateRecord(s.promotions[ProductBranchKey(productName, branch)], buildId, func(ev *NewReleaseEvent) {
+		ev.RolloutPercentage = &percentage
+	}), nil
+}
+
+func (s *MemoryStore) MarkPromotionWithdrawn(productName string, branch string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return memoryUpdateRecord(s.promotions[ProductBranchKey(productName, branch)], buildId, func(ev *NewReleaseEvent) {
+		ev.markWithdrawn(reason, withdrawnAt)
+	}), nil
+}
+
+func (s *MemoryStore) AddPromotedTo(productName string, buildId int, branch string) (*NewReleaseEvent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return memoryUpdateRecord(s.releases[productName], buildId, func(ev *NewReleaseEvent) {
+		ev.addPromotedTo(branch)
+	}), nil
+}
+
+func (s *MemoryStore) ListPromotions(productName string, branch string) ([]NewReleaseEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	branchPromotions := s.promotions[ProductBranchKey(productName, branch)]
+	result := make([]NewReleaseEvent, 0, len(branchPromotions))
+	for _, ev := range branchPromotions {
+		result = append(result, ev)
+	}
+	sortNewestFirst(result)
+	return result, nil
+}
+
+func (s *MemoryStore) GetProduct(productName string) (*Product, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	product, haveProduct := s.products[productName]
+	if !haveProduct {
+		return nil, nil
+	}
+	return &product, nil
+}
+
+func (s *MemoryStore) ListProducts() ([]Product, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]Product, 0, len(s.products))
+	for _, product := range s.products {
+		result = append(result, product)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProductName < result[j].ProductName
+	})
+	return result, nil
+}
+
+func (s *MemoryStore) PutProduct(product *Product) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.products[product.ProductName] = *product
+	return nil
+}
+
+func (s *MemoryStore) CountDownload(productName string, buildId int, platform string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	productDownloads, haveProduct := s.downloads[productName]
+	if !haveProduct {
+		productDownloads = make(map[int]map[string]int64)
+		s.downloads[productName] = productDownloads
+	}
+	buildDownloads, haveBuild := productDownloads[buildId]
+	if !haveBuild {
+		buildDownloads = make(map[string]int64)
+		productDownloads[buildId] = buildDownloads
+	}
+	buildDownloads[platform]++
+	return nil
+}
+
+func (s *MemoryStore) DownloadCounts(productName string, buildId int) ([]DownloadCount, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	buildDownloads := s.downloads[productName][buildId]
+	result := make([]DownloadCount, 0, len(buildDownloads))
+	for platform, downloads := range buildDownloads {
+		result = append(result, DownloadCount{ProductName: productName, BuildId: buildId, Platform: platform, Downloads: downloads})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Platform < result[j].Platform
+	})
+	return result, nil
+}
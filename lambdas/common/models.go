@@ -1,8 +1,13 @@
 package common
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
+	"time"
 )
 
 type NewReleaseEvent struct {
@@ -13,28 +18,392 @@ type NewReleaseEvent struct {
 	ProductName string `json:"productName"`
 	Timestamp   string `json:"timestamp"`
 	BuildSHA    string `json:"buildSHA"`
+	//optional semantic version, e.g. 2.14.0-rc.3
+	Semver string `json:"semver,omitempty"`
+	//optional hex-encoded SHA-256 and size in bytes of the file at DownloadUrl, so that clients can verify it
+	Sha256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	//optional hex-encoded SHA-1 of the file at DownloadUrl, for Squirrel.Windows clients
+	Sha1 string `json:"sha1,omitempty"`
+	//optional base64-encoded SHA-512 of the file at DownloadUrl, for electron-updater clients
+	Sha512 string `json:"sha512,omitempty"`
+	//optional base64-encoded EdDSA signature of the file at DownloadUrl, for Sparkle clients, see Artifact
+	EdSignature string `json:"edSignature,omitempty"`
+	//optional per-platform downloads. If this is empty then DownloadUrl is the only download
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	//optional Markdown release notes keyed by language tag
+	ReleaseNotes ReleaseNotes `json:"releaseNotes,omitempty"`
+	//optional link to release notes that are published somewhere else
+	ReleaseNotesUrl string `json:"releaseNotesUrl,omitempty"`
+	//set for releases that clients must install, e.g. security fixes
+	Mandatory bool `json:"mandatory,omitempty"`
+	//optional percentage of clients to offer the release to, see OfferedTo. nil offers it to everyone.
+	RolloutPercentage *int `json:"rolloutPercentage,omitempty"`
+	//set by WithdrawRelease for releases that must not be offered to clients any more. Not accepted from build pipelines.
+	Withdrawn       bool   `json:"withdrawn,omitempty"`
+	WithdrawnReason string `json:"withdrawnReason,omitempty"`
+	WithdrawnAt     string `json:"withdrawnAt,omitempty"`
+	//set on a copy made by Promote: the branch it was built on, who promoted it and when. Not accepted from build pipelines.
+	PromotedFrom string `json:"promotedFrom,omitempty"`
+	PromotedBy   string `json:"promotedBy,omitempty"`
+	PromotedAt   string `json:"promotedAt,omitempty"`
+	//set on the original by Promote: the branches it has been promoted to
+	PromotedTo []string `json:"promotedTo,omitempty"`
+	//composite productName#branch key for the branch index, not part of the API
+	ProductBranch string `json:"-" dynamodbav:"productBranch,omitempty"`
+	//Semver encoded by SemVer.SortKey so that it sorts by precedence, for the semver index. Not part of the API
+	SemverKey string `json:"-" dynamodbav:"semverKey,omitempty"`
 }
 
-func (e *NewReleaseEvent) Validate() error {
-	urlValidator := regexp.MustCompile(`(?:http(s)?://)?[\w.-]+(?:\.[\w.-]+)+[\w\-_~:/?#[\]@!$&'()*+,;=.]+$`)
+/**
+returns the parsed Semver of the release, or nil if it does not have one
+*/
+func (e *NewReleaseEvent) ParsedSemver() (*SemVer, error) {
+	if e.Semver == "" {
+		return nil, nil
+	}
+	return ParseSemVer(e.Semver)
+}
+
+/**
+returns the composite key that identifies a product's branch in the branch index
+*/
+func ProductBranchKey(productName string, branch string) string {
+	return productName + "#" + branch
+}
+
+var urlValidator = regexp.MustCompile(`(?:http(s)?://)?[\w.-]+(?:\.[\w.-]+)+[\w\-_~:/?#[\]@!$&'()*+,;=.]+$`)
 
+func (e *NewReleaseEvent) Validate() error {
 	if e.ProductName == "" {
 		return errors.New("productName must be specified")
 	}
-	if e.DownloadUrl == "" {
-		return errors.New("downloadUrl must be specified")
+	if e.DownloadUrl == "" && len(e.Artifacts) == 0 {
+		return errors.New("downloadUrl or artifacts must be specified")
 	}
-	if !urlValidator.MatchString(e.DownloadUrl) {
+	if e.DownloadUrl != "" && !urlValidator.MatchString(e.DownloadUrl) {
 		return errors.New("downloadUrl does not look like a valid URL")
 	}
+	if e.Sha256 != "" && !checksumValidator.MatchString(e.Sha256) {
+		return errors.New("sha256 must be a hex-encoded SHA-256")
+	}
+	if e.Size < 0 {
+		return errors.New("size can't be negative")
+	}
+	if e.Sha1 != "" && !sha1Validator.MatchString(e.Sha1) {
+		return errors.New("sha1 must be a hex-encoded SHA-1")
+	}
+	if e.Sha512 != "" && !validSha512(e.Sha512) {
+		return errors.New("sha512 must be a base64-encoded SHA-512")
+	}
+	if e.EdSignature != "" && !validEdSignature(e.EdSignature) {
+		return errors.New("edSignature must be a base64-encoded ed25519 signature")
+	}
+	if e.ReleaseNotesUrl != "" && !urlValidator.MatchString(e.ReleaseNotesUrl) {
+		return errors.New("releaseNotesUrl does not look like a valid URL")
+	}
+	seenPlatforms := make(map[string]bool, len(e.Artifacts))
+	for i := range e.Artifacts {
+		if artifactErr := e.Artifacts[i].Validate(); artifactErr != nil {
+			return artifactErr
+		}
+		platform := DownloadPlatform(&e.Artifacts[i])
+		if seenPlatforms[platform] {
+			return fmt.Errorf("there is more than one artifact for %s", platform)
+		}
+		seenPlatforms[platform] = true
+	}
 	if e.Branch == "" {
 		return errors.New("branch must be specified")
 	}
+	if _, semverErr := e.ParsedSemver(); semverErr != nil {
+		return semverErr
+	}
+	if notesErr := e.ReleaseNotes.Validate(); notesErr != nil {
+		return notesErr
+	}
+	if e.RolloutPercentage != nil {
+		if rolloutErr := validateRolloutPercentage(*e.RolloutPercentage); rolloutErr != nil {
+			return rolloutErr
+		}
+	}
 	return nil
 }
 
+/**
+returns true if other describes the same build as e, i.e. a pipeline that logged e and was then run again would have
+sent other. The fields that are set by the server or changed later through other endpoints (the timestamp, rollout,
+withdrawal and promotion fields) are ignored.
+*/
+func (e *NewReleaseEvent) SameBuildAs(other *NewReleaseEvent) bool {
+	first, firstErr := json.Marshal(e.asLogged())
+	second, secondErr := json.Marshal(other.asLogged())
+	return firstErr == nil && secondErr == nil && bytes.Equal(first, second)
+}
+
+/**
+a copy of the release with only the fields that a build pipeline sends
+*/
+func (e *NewReleaseEvent) asLogged() *NewReleaseEvent {
+	logged := *e
+	logged.Timestamp = ""
+	logged.RolloutPercentage = nil
+	logged.Withdrawn = false
+	logged.WithdrawnReason = ""
+	logged.WithdrawnAt = ""
+	logged.PromotedFrom = ""
+	logged.PromotedBy = ""
+	logged.PromotedAt = ""
+	logged.PromotedTo = nil
+	return &logged
+}
+
+/**
+set the withdrawal fields of the release, see WithdrawRelease
+*/
+func (e *NewReleaseEvent) markWithdrawn(reason string, withdrawnAt string) {
+	e.Withdrawn = true
+	e.WithdrawnReason = reason
+	e.WithdrawnAt = withdrawnAt
+}
+
+/**
+record that the release has been promoted to branch, see Promote
+*/
+func (e *NewReleaseEvent) addPromotedTo(branch string) {
+	if !containsString(e.PromotedTo, branch) {
+		e.PromotedTo = append(e.PromotedTo, branch)
+	}
+}
+
+const OrderByBuildId = "buildId"
+const OrderBySemver = "semver"
+
 type SearchRequest struct {
 	Branch           string `json:"branch"`
+	Channel          string `json:"channel"` //look in the branches of this channel instead of a single branch
 	ProductName      string `json:"productName"`
+	AlsoShowDefault  bool   `json:"alsoShowDefault"`
 	AlwaysShowMaster bool   `json:"alwaysShowMaster"`
+	OrderBy          string `json:"orderBy"` //OrderByBuildId (the default) or OrderBySemver
+	Os               string `json:"os"`      //optional, only return the artifact for this platform
+	Arch             string `json:"arch"`
+	Notes            string `json:"notes"` //optional, one of the NotesFormat constants
+	Lang             string `json:"lang"`  //optional, preferred language for release notes
+	//optional, the build the client is running. If given, the response says whether it needs to update
+	CurrentBuildId *int `json:"currentBuildId,omitempty"`
+	//optional, the semver the client is running, used along with CurrentBuildId
+	CurrentSemver string `json:"currentSemver,omitempty"`
+	//optional stable identifier for the client, used to decide whether it is inside a staged rollout
+	ClientId string `json:"clientId,omitempty"`
+	//optional, one of the Format constants. The default is the usual JSON response
+	Format string `json:"format,omitempty"`
+}
+
+/**
+build a SearchRequest from the query string parameters of a GET request, i.e.
+/lookup?productName=...&branch=...&alsoShowDefault=true
+returns an error if a parameter is present but can't be understood
+*/
+func SearchRequestFromQuery(params map[string]string) (*SearchRequest, error) {
+	req := SearchRequest{
+		Branch:        params["branch"],
+		Channel:       params["channel"],
+		ProductName:   params["productName"],
+		OrderBy:       params["orderBy"],
+		Os:            params["os"],
+		Arch:          params["arch"],
+		Notes:         params["notes"],
+		Lang:          params["lang"],
+		CurrentSemver: params["currentSemver"],
+		ClientId:      params["clientId"],
+		Format:        params["format"],
+	}
+
+	if showDefaultString, haveShowDefault := params["alsoShowDefault"]; haveShowDefault && showDefaultString != "" {
+		showDefault, parseErr := strconv.ParseBool(showDefaultString)
+		if parseErr != nil {
+			return nil, fmt.Errorf("alsoShowDefault must be true or false, not '%s'", showDefaultString)
+		}
+		req.AlsoShowDefault = showDefault
+	}
+
+	if showMasterString, haveShowMaster := params["alwaysShowMaster"]; haveShowMaster && showMasterString != "" {
+		showMaster, parseErr := strconv.ParseBool(showMasterString)
+		if parseErr != nil {
+			return nil, fmt.Errorf("alwaysShowMaster must be true or false, not '%s'", showMasterString)
+		}
+		req.AlwaysShowMaster = showMaster
+	}
+
+	if currentBuildString, haveCurrentBuild := params["currentBuildId"]; haveCurrentBuild && currentBuildString != "" {
+		currentBuildId, parseErr := strconv.Atoi(currentBuildString)
+		if parseErr != nil {
+			return nil, fmt.Errorf("currentBuildId must be a number, not '%s'", currentBuildString)
+		}
+		req.CurrentBuildId = &currentBuildId
+	}
+	return &req, nil
+}
+
+/**
+returns true if the lookup should also return the latest build of the product's default branch, whichever of
+alsoShowDefault or the older alwaysShowMaster the client sent
+*/
+func (s *SearchRequest) ShowsDefault() bool {
+	return s.AlsoShowDefault || s.AlwaysShowMaster
+}
+
+func (s *SearchRequest) Validate() error {
+	if s.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if s.Branch == "" && s.Channel == "" {
+		return errors.New("branch or channel must be specified")
+	}
+	if s.Branch != "" && s.Channel != "" {
+		return errors.New("branch and channel can't both be specified")
+	}
+	if s.OrderBy != "" && s.OrderBy != OrderByBuildId && s.OrderBy != OrderBySemver {
+		return fmt.Errorf("orderBy must be %s or %s", OrderByBuildId, OrderBySemver)
+	}
+	if s.Arch != "" && s.Os == "" {
+		return errors.New("os must be specified if arch is")
+	}
+	if s.Notes != "" && s.Notes != NotesFormatNone && s.Notes != NotesFormatMarkdown && s.Notes != NotesFormatHTML {
+		return fmt.Errorf("notes must be %s, %s or %s", NotesFormatNone, NotesFormatMarkdown, NotesFormatHTML)
+	}
+	if s.Format != "" && s.Format != FormatJSON && s.Format != FormatSquirrelWindows && s.Format != FormatSquirrelMac {
+		return fmt.Errorf("format must be %s, %s or %s", FormatJSON, FormatSquirrelWindows, FormatSquirrelMac)
+	}
+	if len(s.ClientId) > MaxClientIdLength {
+		return fmt.Errorf("clientId can't be more than %d characters", MaxClientIdLength)
+	}
+	if s.CurrentSemver != "" {
+		if s.CurrentBuildId == nil {
+			return errors.New("currentBuildId must be specified if currentSemver is")
+		}
+		if _, semverErr := ParseSemVer(s.CurrentSemver); semverErr != nil {
+			return semverErr
+		}
+	}
+	return nil
+}
+
+/**
+the response to a lookup that gives currentBuildId
+*/
+type UpdateCheckResponse struct {
+	Status         string             `json:"status"`                   //StatusUpToDate, StatusUpdateAvailable or StatusAheadOfServer
+	Update         string             `json:"update"`                   //UpdateRequired, UpdateOptional or UpdateNone
+	Release        *NewReleaseEvent   `json:"release"`                  //the newest release on the branch, that the client should be running
+	BuildsBehind   int                `json:"buildsBehind"`             //how many releases on the branch are newer than the client
+	MinimumBuildId int                `json:"minimumBuildId,omitempty"` //the minimum supported build of the branch, if there is one
+	Releases       []*NewReleaseEvent `json:"releases"`                 //as returned by a lookup without currentBuildId
+}
+
+/**
+request to withdraw a release, see WithdrawRelease
+*/
+type WithdrawRequest struct {
+	ProductName string `json:"productName"`
+	BuildId     int    `json:"buildId"`
+	Reason      string `json:"reason"`
+}
+
+func (w *WithdrawRequest) Validate() error {
+	if w.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if w.BuildId == 0 {
+		return errors.New("buildId must be specified")
+	}
+	if w.Reason == "" {
+		return errors.New("reason must be specified")
+	}
+	return nil
+}
+
+const DefaultPageSize = 20
+const MaxPageSize = 100
+
+/**
+parameters for listing the release history of a product
+*/
+type ReleaseQuery struct {
+	ProductName string
+	Branch      string //optional, only list builds from this branch
+	Since       string //optional, RFC3339 timestamp of the earliest build to list
+	Until       string //optional, RFC3339 timestamp of the latest build to list
+	PageSize    int
+	PageToken   string //optional, the NextPageToken from a previous ReleasePage
+}
+
+/**
+a single page of release history, newest first. NextPageToken is empty if there are no more results
+*/
+type ReleasePage struct {
+	Releases      []NewReleaseEvent `json:"releases"`
+	NextPageToken string            `json:"nextPageToken,omitempty"`
+}
+
+/**
+normalise a timestamp query parameter into the same RFC3339/UTC form that is stored in the database,
+so that the two can be compared as strings
+*/
+func normaliseTimestamp(paramName string, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	parsed, parseErr := time.Parse(time.RFC3339, value)
+	if parseErr != nil {
+		return "", fmt.Errorf("%s must be an RFC3339 timestamp, not '%s'", paramName, value)
+	}
+	return parsed.UTC().Format(time.RFC3339), nil
+}
+
+/**
+build a ReleaseQuery from the query string parameters of a GET request, i.e.
+/releases?productName=...&branch=...&since=...&until=...&pageSize=...&pageToken=...
+returns an error if a parameter is present but can't be understood
+*/
+func ReleaseQueryFromQuery(params map[string]string) (*ReleaseQuery, error) {
+	query := ReleaseQuery{
+		ProductName: params["productName"],
+		Branch:      params["branch"],
+		PageSize:    DefaultPageSize,
+		PageToken:   params["pageToken"],
+	}
+
+	var tsErr error
+	query.Since, tsErr = normaliseTimestamp("since", params["since"])
+	if tsErr != nil {
+		return nil, tsErr
+	}
+	query.Until, tsErr = normaliseTimestamp("until", params["until"])
+	if tsErr != nil {
+		return nil, tsErr
+	}
+
+	if pageSizeString, havePageSize := params["pageSize"]; havePageSize && pageSizeString != "" {
+		pageSize, parseErr := strconv.Atoi(pageSizeString)
+		if parseErr != nil {
+			return nil, fmt.Errorf("pageSize must be a number, not '%s'", pageSizeString)
+		}
+		query.PageSize = pageSize
+	}
+	return &query, nil
+}
+
+func (q *ReleaseQuery) Validate() error {
+	if q.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if q.PageSize < 1 || q.PageSize > MaxPageSize {
+		return fmt.Errorf("pageSize must be between 1 and %d", MaxPageSize)
+	}
+	if q.Since != "" && q.Until != "" && q.Since > q.Until {
+		return errors.New("since must be before until")
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"github.com/davecgh/go-spew/spew"
 	"testing"
 )
 
@@ -70,3 +71,248 @@ func TestNewReleaseEvent_Validate(t *testing.T) {
 		t.Errorf("Validation on malformed URL should have failed but it succeeded")
 	}
 }
+
+func TestSearchRequestFromQuery(t *testing.T) {
+	req1, err1 := SearchRequestFromQuery(map[string]string{"productName": "some product", "branch": "somebranch", "alwaysShowMaster": "true"})
+	if err1 != nil {
+		t.Errorf("valid query string should have parsed but got %s", err1)
+	}
+	if req1.ProductName != "some product" {
+		t.Errorf("returned product name was wrong, got %s", req1.ProductName)
+	}
+	if req1.Branch != "somebranch" {
+		t.Errorf("returned branch was wrong, got %s", req1.Branch)
+	}
+	if req1.AlwaysShowMaster != true {
+		t.Errorf("alwaysShowMaster should have been set")
+	}
+
+	req2, err2 := SearchRequestFromQuery(map[string]string{"productName": "some product", "branch": "somebranch"})
+	if err2 != nil {
+		t.Errorf("query string without alwaysShowMaster should have parsed but got %s", err2)
+	}
+	if req2.AlwaysShowMaster != false {
+		t.Errorf("alwaysShowMaster should default to false")
+	}
+
+	_, err3 := SearchRequestFromQuery(map[string]string{"productName": "some product", "branch": "somebranch", "alwaysShowMaster": "sometimes"})
+	if err3 == nil {
+		t.Errorf("invalid alwaysShowMaster value should have failed but it succeeded")
+	}
+
+	showDefault, showDefaultErr := SearchRequestFromQuery(map[string]string{"productName": "some product", "branch": "somebranch", "alsoShowDefault": "true"})
+	if showDefaultErr != nil || !showDefault.AlsoShowDefault || !showDefault.ShowsDefault() {
+		t.Errorf("alsoShowDefault should have been set but got %s, %s", spew.Sprint(showDefault), showDefaultErr)
+	}
+	if !req1.ShowsDefault() || req2.ShowsDefault() {
+		t.Errorf("alwaysShowMaster should be an alias for alsoShowDefault")
+	}
+
+	req4, err4 := SearchRequestFromQuery(map[string]string{"productName": "some product", "branch": "somebranch", "currentBuildId": "42"})
+	if err4 != nil || req4.CurrentBuildId == nil || *req4.CurrentBuildId != 42 {
+		t.Errorf("currentBuildId should have been parsed but got %s, %s", spew.Sprint(req4), err4)
+	}
+	if req2.CurrentBuildId != nil {
+		t.Errorf("currentBuildId should default to nil")
+	}
+
+	_, err5 := SearchRequestFromQuery(map[string]string{"productName": "some product", "branch": "somebranch", "currentBuildId": "latest"})
+	if err5 == nil {
+		t.Errorf("invalid currentBuildId value should have failed but it succeeded")
+	}
+}
+
+func TestSearchRequest_Validate(t *testing.T) {
+	req1 := SearchRequest{ProductName: "some product", Branch: "somebranch"}
+	if err := req1.Validate(); err != nil {
+		t.Errorf("Test failed to validate: got %s", err)
+	}
+
+	req2 := SearchRequest{ProductName: "", Branch: "somebranch"}
+	if err := req2.Validate(); err == nil {
+		t.Errorf("Validation on empty product name should have failed but it succeeded")
+	}
+
+	req3 := SearchRequest{ProductName: "some product", Branch: ""}
+	if err := req3.Validate(); err == nil {
+		t.Errorf("Validation on empty branch should have failed but it succeeded")
+	}
+
+	channelReq := SearchRequest{ProductName: "some product", Channel: "beta"}
+	if err := channelReq.Validate(); err != nil {
+		t.Errorf("Validation with a channel instead of a branch should have succeeded but got %s", err)
+	}
+
+	bothReq := SearchRequest{ProductName: "some product", Branch: "somebranch", Channel: "beta"}
+	if err := bothReq.Validate(); err == nil {
+		t.Errorf("Validation with both a branch and a channel should have failed but it succeeded")
+	}
+
+	req4 := SearchRequest{ProductName: "some product", Branch: "somebranch", Notes: "pdf"}
+	if err := req4.Validate(); err == nil {
+		t.Errorf("Validation on an unknown notes format should have failed but it succeeded")
+	}
+
+	req5 := SearchRequest{ProductName: "some product", Branch: "somebranch", CurrentSemver: "1.0.0"}
+	if err := req5.Validate(); err == nil {
+		t.Errorf("Validation on currentSemver without currentBuildId should have failed but it succeeded")
+	}
+
+	currentBuildId := 12
+	req6 := SearchRequest{ProductName: "some product", Branch: "somebranch", CurrentBuildId: &currentBuildId, CurrentSemver: "1.0"}
+	if err := req6.Validate(); err == nil {
+		t.Errorf("Validation on an invalid currentSemver should have failed but it succeeded")
+	}
+}
+
+func TestReleaseQueryFromQuery(t *testing.T) {
+	q1, err1 := ReleaseQueryFromQuery(map[string]string{"productName": "some product", "since": "2019-11-01T10:00:00+01:00", "pageSize": "5"})
+	if err1 != nil {
+		t.Errorf("valid query string should have parsed but got %s", err1)
+		return
+	}
+	if q1.Since != "2019-11-01T09:00:00Z" {
+		t.Errorf("since should have been normalised to UTC, got %s", q1.Since)
+	}
+	if q1.PageSize != 5 {
+		t.Errorf("pageSize was wrong, got %d", q1.PageSize)
+	}
+	if err := q1.Validate(); err != nil {
+		t.Errorf("Test failed to validate: got %s", err)
+	}
+
+	q2, err2 := ReleaseQueryFromQuery(map[string]string{"productName": "some product"})
+	if err2 != nil {
+		t.Errorf("query string without pageSize should have parsed but got %s", err2)
+		return
+	}
+	if q2.PageSize != DefaultPageSize {
+		t.Errorf("pageSize should have defaulted to %d, got %d", DefaultPageSize, q2.PageSize)
+	}
+
+	_, err3 := ReleaseQueryFromQuery(map[string]string{"productName": "some product", "until": "yesterday"})
+	if err3 == nil {
+		t.Errorf("invalid timestamp should have failed but it succeeded")
+	}
+
+	_, err4 := ReleaseQueryFromQuery(map[string]string{"productName": "some product", "pageSize": "lots"})
+	if err4 == nil {
+		t.Errorf("invalid page size should have failed but it succeeded")
+	}
+
+	q5 := ReleaseQuery{ProductName: "some product", PageSize: MaxPageSize + 1}
+	if err := q5.Validate(); err == nil {
+		t.Errorf("Validation on oversized page should have failed but it succeeded")
+	}
+
+	q6 := ReleaseQuery{ProductName: "", PageSize: DefaultPageSize}
+	if err := q6.Validate(); err == nil {
+		t.Errorf("Validation on empty product name should have failed but it succeeded")
+	}
+}
+
+func TestNewReleaseEvent_ValidateSemver(t *testing.T) {
+	ev1 := NewReleaseEvent{
+		Event:       "test",
+		BuildId:     123,
+		Branch:      "somebranch",
+		DownloadUrl: "https://someurl.server.com/path",
+		ProductName: "some product",
+		Semver:      "2.14.0-rc.3",
+	}
+	if err := ev1.Validate(); err != nil {
+		t.Errorf("Test failed to validate: got %s", err)
+	}
+
+	ev2 := ev1
+	ev2.Semver = "2.14"
+	if err := ev2.Validate(); err == nil {
+		t.Errorf("Validation on malformed semver should have failed but it succeeded")
+	}
+}
+
+func TestNewReleaseEvent_ValidateArtifacts(t *testing.T) {
+	ev1 := multiPlatformRelease
+	if err := ev1.Validate(); err != nil {
+		t.Errorf("Test failed to validate: got %s", err)
+	}
+
+	ev2 := multiPlatformRelease
+	ev2.Artifacts = append([]Artifact{{Os: "darwin", Arch: "aarch64", Url: "https://someurl.server.com/other.dmg"}}, multiPlatformRelease.Artifacts...)
+	if err := ev2.Validate(); err == nil {
+		t.Errorf("Validation on duplicate platforms should have failed but it succeeded")
+	}
+
+	ev3 := multiPlatformRelease
+	ev3.Artifacts = nil
+	if err := ev3.Validate(); err == nil {
+		t.Errorf("Validation with no downloadUrl or artifacts should have failed but it succeeded")
+	}
+}
+
+func TestNewReleaseEvent_ValidateChecksum(t *testing.T) {
+	ev1 := NewReleaseEvent{
+		Event:       "test",
+		BuildId:     123,
+		Branch:      "somebranch",
+		DownloadUrl: "https://someurl.server.com/path",
+		ProductName: "some product",
+		Sha256:      "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Size:        1234,
+	}
+	if err := ev1.Validate(); err != nil {
+		t.Errorf("Test failed to validate: got %s", err)
+	}
+
+	ev2 := ev1
+	ev2.Sha256 = "not a checksum"
+	if err := ev2.Validate(); err == nil {
+		t.Errorf("Validation on malformed sha256 should have failed but it succeeded")
+	}
+
+	ev3 := ev1
+	ev3.Size = -1
+	if err := ev3.Validate(); err == nil {
+		t.Errorf("Validation on negative size should have failed but it succeeded")
+	}
+}
+
+func TestNewReleaseEvent_SameBuildAs(t *testing.T) {
+	rollout := 10
+	stored := NewReleaseEvent{
+		Event:             "newversion",
+		BuildId:           123,
+		Branch:            "somebranch",
+		DownloadUrl:       "https://someurl.server.com/path",
+		ProductName:       "some product",
+		Timestamp:         "2019-11-01T10:00:00Z",
+		RolloutPercentage: &rollout,
+		Withdrawn:         true,
+		WithdrawnReason:   "crashes on startup",
+		PromotedTo:        []string{"master"},
+	}
+
+	repeat := NewReleaseEvent{
+		Event:       "newversion",
+		BuildId:     123,
+		Branch:      "somebranch",
+		DownloadUrl: "https://someurl.server.com/path",
+		ProductName: "some product",
+		Timestamp:   "2019-11-02T10:00:00Z",
+	}
+	if !stored.SameBuildAs(&repeat) {
+		t.Errorf("a repeat of the same build should have matched")
+	}
+
+	otherBranch := repeat
+	otherBranch.Branch = "master"
+	if stored.SameBuildAs(&otherBranch) {
+		t.Errorf("a build on a different branch should not have matched")
+	}
+
+	otherUrl := repeat
+	otherUrl.DownloadUrl = "https://someurl.server.com/other"
+	if stored.SameBuildAs(&otherUrl) {
+		t.Errorf("a build with a different download should not have matched")
+	}
+}
@@ -0,0 +1,195 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const NotesFormatNone = "none"
+const NotesFormatMarkdown = "markdown"
+const NotesFormatHTML = "html"
+
+//the language that is used if the client's language isn't available
+const DefaultNotesLanguage = "en"
+
+//release notes are stored with the release record, so keep them well within Dynamo's 400KB item limit
+const MaxNotesLength = 64 * 1024
+
+var languageTagValidator = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+/**
+Markdown release notes keyed by language tag, e.g. {"en": "## Fixed\n- crashes", "de-DE": "..."}
+*/
+type ReleaseNotes map[string]string
+
+func (n ReleaseNotes) Validate() error {
+	totalLength := 0
+	for tag, notes := range n {
+		if !languageTagValidator.MatchString(tag) {
+			return fmt.Errorf("release notes language '%s' is not a valid language tag", tag)
+		}
+		totalLength += len(notes)
+	}
+	if totalLength > MaxNotesLength {
+		return fmt.Errorf("release notes can't be more than %d bytes in total", MaxNotesLength)
+	}
+	return nil
+}
+
+/**
+choose the best notes for the requested language: an exact match, then the base language (en for en-GB),
+then any notes in that base language (en-US for en-GB), then DefaultNotesLanguage, then whatever there is.
+Language tags are compared case-insensitively.
+returns the tag that was chosen and the notes, or two empty strings if there are no notes
+*/
+func (n ReleaseNotes) Select(lang string) (string, string) {
+	if len(n) == 0 {
+		return "", ""
+	}
+
+	tags := make([]string, 0, len(n))
+	for tag := range n {
+		tags = append(tags, tag)
+	}
+	//so that we always make the same choice when more than one would do
+	sort.Strings(tags)
+
+	wanted := strings.ToLower(lang)
+	base := strings.SplitN(wanted, "-", 2)[0]
+	candidates := []func(tag string) bool{
+		func(tag string) bool { return tag == wanted },
+		func(tag string) bool { return tag == base },
+		func(tag string) bool { return strings.HasPrefix(tag, base+"-") },
+		func(tag string) bool { return tag == DefaultNotesLanguage },
+		func(tag string) bool { return strings.HasPrefix(tag, DefaultNotesLanguage+"-") },
+	}
+	for _, matches := range candidates {
+		for _, tag := range tags {
+			if matches(strings.ToLower(tag)) {
+				return tag, n[tag]
+			}
+		}
+	}
+	return tags[0], n[tags[0]]
+}
+
+//the URL schemes that links and images in rendered release notes may use. Links without a scheme are relative and kept.
+var allowedNotesSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+var urlSchemeMatcher = regexp.MustCompile(`^([a-z][a-z0-9+.\-]*):`)
+
+/**
+check that a link in release notes can't run script when it is clicked. Browsers ignore the case of the scheme and
+any whitespace or control characters in it, so those are taken out before it is compared to allowedNotesSchemes.
+*/
+func safeNotesURL(destination []byte) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToLower(string(destination)))
+
+	if matched := urlSchemeMatcher.FindStringSubmatch(cleaned); matched != nil {
+		return allowedNotesSchemes[matched[1]]
+	}
+	//anything else with a colon before the path might still be read as a scheme by something, so don't risk it
+	beforePath := cleaned
+	if i := strings.IndexAny(cleaned, "/?#"); i >= 0 {
+		beforePath = cleaned[:i]
+	}
+	return !strings.Contains(beforePath, ":")
+}
+
+/**
+goldmark AST transformer that takes the destination off links and images that aren't safeNotesURL, and turns such
+autolinks back into plain text. goldmark's own check is case-sensitive and doesn't cover autolinks at all.
+*/
+type notesLinkFilter struct{}
+
+func (notesLinkFilter) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var unsafeAutoLinks []*ast.AutoLink
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			if !safeNotesURL(n.Destination) {
+				n.Destination = nil
+			}
+		case *ast.Image:
+			if !safeNotesURL(n.Destination) {
+				n.Destination = nil
+			}
+		case *ast.AutoLink:
+			if n.AutoLinkType == ast.AutoLinkURL && !safeNotesURL(n.URL(source)) {
+				unsafeAutoLinks = append(unsafeAutoLinks, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	//nodes can't be replaced while they are being walked
+	for _, n := range unsafeAutoLinks {
+		n.Parent().ReplaceChild(n.Parent(), n, ast.NewString(n.Label(source)))
+	}
+}
+
+var notesMarkdown = goldmark.New(goldmark.WithParserOptions(
+	parser.WithASTTransformers(util.Prioritized(notesLinkFilter{}, 1000)),
+))
+
+/**
+render Markdown release notes as HTML. Raw HTML in the notes is dropped and links and images are only kept if they
+are http, https or mailto, see safeNotesURL, so the result is safe to show in an "update available" dialog.
+*/
+func RenderNotesHTML(markdown string) (string, error) {
+	var buf bytes.Buffer
+	if err := notesMarkdown.Convert([]byte(markdown), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+/**
+returns a copy of the release with its notes in the requested format:
+    - NotesFormatNone: no notes at all
+    - NotesFormatMarkdown: the Markdown notes for the best language for lang, see ReleaseNotes.Select
+    - NotesFormatHTML: as NotesFormatMarkdown but rendered to sanitised HTML
+    - "": all the notes as they were posted
+*/
+func (e *NewReleaseEvent) WithNotes(format string, lang string) (*NewReleaseEvent, error) {
+	result := *e
+	switch format {
+	case "":
+		return &result, nil
+	case NotesFormatNone:
+		result.ReleaseNotes = nil
+		return &result, nil
+	case NotesFormatMarkdown, NotesFormatHTML:
+		if len(e.ReleaseNotes) == 0 {
+			return &result, nil
+		}
+		tag, notes := e.ReleaseNotes.Select(lang)
+		if format == NotesFormatHTML {
+			var renderErr error
+			notes, renderErr = RenderNotesHTML(notes)
+			if renderErr != nil {
+				return nil, renderErr
+			}
+		}
+		result.ReleaseNotes = ReleaseNotes{tag: notes}
+		return &result, nil
+	default:
+		return nil, fmt.Errorf("notes format must be %s, %s or %s", NotesFormatNone, NotesFormatMarkdown, NotesFormatHTML)
+	}
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReleaseNotes_Select(t *testing.T) {
+	notes := ReleaseNotes{"en-US": "us notes", "de": "german notes", "fr-CA": "canadian notes"}
+
+	tests := []struct {
+		lang        string
+		expectedTag string
+	}{
+		{"en-US", "en-US"},
+		{"de-AT", "de"},
+		{"fr-FR", "fr-CA"},
+		{"ja", "en-US"},
+		{"", "en-US"},
+		{"EN-us", "en-US"},
+	}
+	for _, test := range tests {
+		tag, _ := notes.Select(test.lang)
+		if tag != test.expectedTag {
+			t.Errorf("Select(%s) should have chosen %s but got %s", test.lang, test.expectedTag, tag)
+		}
+	}
+
+	tag, text := ReleaseNotes{"ja": "japanese notes", "de": "german notes"}.Select("es")
+	if tag != "de" || text != "german notes" {
+		t.Errorf("Select with no matching or default language should have chosen the first tag but got %s", tag)
+	}
+
+	if tag, text := (ReleaseNotes{}).Select("en"); tag != "" || text != "" {
+		t.Errorf("Select with no notes should have returned nothing but got %s, %s", tag, text)
+	}
+}
+
+func TestReleaseNotes_Validate(t *testing.T) {
+	if err := (ReleaseNotes{"en": "fine", "pt-BR": "fine"}).Validate(); err != nil {
+		t.Errorf("valid notes failed validation: %s", err)
+	}
+	if err := (ReleaseNotes{"not a tag": "notes"}).Validate(); err == nil {
+		t.Errorf("notes with an invalid language tag should have failed validation")
+	}
+	if err := (ReleaseNotes{"en": strings.Repeat("x", MaxNotesLength+1)}).Validate(); err == nil {
+		t.Errorf("notes that are too long should have failed validation")
+	}
+}
+
+func TestRenderNotesHTML(t *testing.T) {
+	rendered, err := RenderNotesHTML("## Fixed\n\n- <script>alert('hi')</script>crashes\n- [click me](javascript:alert(1))\n")
+	if err != nil {
+		t.Fatalf("RenderNotesHTML failed: %s", err)
+	}
+	if !strings.Contains(rendered, "<h2>Fixed</h2>") {
+		t.Errorf("rendered notes should have contained a heading but got %s", rendered)
+	}
+	if strings.Contains(rendered, "<script>") {
+		t.Errorf("rendered notes should not have contained raw HTML but got %s", rendered)
+	}
+	if strings.Contains(rendered, "javascript:") {
+		t.Errorf("rendered notes should not have contained a javascript link but got %s", rendered)
+	}
+}
+
+func TestRenderNotesHTML_LinkSchemes(t *testing.T) {
+	linked := regexp.MustCompile(`(href|src)="[^"]`)
+	for _, markdown := range []string{
+		"[click me](JAVASCRIPT:alert(1))",
+		"[click me](JavaScript:alert(1))",
+		"[click me](<java\tscript:alert(1)>)",
+		"[click me](VBScript:msgbox)",
+		"[click me](DATA:text/html;base64,PHNjcmlwdD4=)",
+		"![picture](JaVaScRiPt:alert(1))",
+		"<JAVASCRIPT:alert(1)>",
+	} {
+		rendered, err := RenderNotesHTML(markdown)
+		if err != nil {
+			t.Fatalf("RenderNotesHTML failed: %s", err)
+		}
+		if linked.MatchString(rendered) {
+			t.Errorf("rendered notes for %s should not have contained the link but got %s", markdown, rendered)
+		}
+	}
+
+	safe := []struct {
+		markdown string
+		expected string
+	}{
+		{"[site](HTTPS://example.com/notes)", `href="HTTPS://example.com/notes"`},
+		{"[mail](mailto:support@example.com)", `href="mailto:support@example.com"`},
+		{"[section](#fixed)", `href="#fixed"`},
+		{"<https://example.com>", `href="https://example.com"`},
+	}
+	for _, test := range safe {
+		rendered, _ := RenderNotesHTML(test.markdown)
+		if !strings.Contains(rendered, test.expected) {
+			t.Errorf("rendered notes for %s should have contained %s but got %s", test.markdown, test.expected, rendered)
+		}
+	}
+}
+
+func TestNewReleaseEvent_WithNotes(t *testing.T) {
+	release := &NewReleaseEvent{BuildId: 1, ReleaseNotes: ReleaseNotes{"en": "**bold**", "de": "*fett*"}}
+
+	all, _ := release.WithNotes("", "de")
+	if len(all.ReleaseNotes) != 2 {
+		t.Errorf("empty format should have kept all the notes but got %v", all.ReleaseNotes)
+	}
+
+	none, _ := release.WithNotes(NotesFormatNone, "de")
+	if none.ReleaseNotes != nil {
+		t.Errorf("none format should have removed the notes but got %v", none.ReleaseNotes)
+	}
+
+	markdown, _ := release.WithNotes(NotesFormatMarkdown, "de-DE")
+	if len(markdown.ReleaseNotes) != 1 || markdown.ReleaseNotes["de"] != "*fett*" {
+		t.Errorf("markdown format should have returned the german notes but got %v", markdown.ReleaseNotes)
+	}
+
+	html, _ := release.WithNotes(NotesFormatHTML, "en")
+	if html.ReleaseNotes["en"] != "<p><strong>bold</strong></p>\n" {
+		t.Errorf("html format should have rendered the english notes but got %v", html.ReleaseNotes)
+	}
+
+	if len(release.ReleaseNotes) != 2 {
+		t.Errorf("WithNotes should not have changed the original release")
+	}
+
+	if _, err := release.WithNotes("pdf", "en"); err == nil {
+		t.Errorf("an unknown format should have failed")
+	}
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+//the default branch of products that haven't been configured with one
+const FallbackDefaultBranch = "master"
+
+/**
+an entry in the product registry. Build pipelines can only log releases of registered products, on the branches and
+download hosts that the product allows.
+*/
+type Product struct {
+	ProductName string `json:"productName"`
+	DisplayName string `json:"displayName,omitempty"` //optional human-friendly name
+	Owner       string `json:"owner,omitempty"`       //optional team or person to contact about the product
+	//the branch that lookups with alsoShowDefault also return the latest build of, e.g. main or trunk
+	DefaultBranch string `json:"defaultBranch"`
+	//optional path.Match patterns, e.g. release-*, of the branches that releases can be logged on. Empty allows any branch.
+	AllowedBranches []string `json:"allowedBranches,omitempty"`
+	//optional hosts that downloads can be served from. *.example.com allows any subdomain of example.com. Empty allows any host.
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+}
+
+func (p *Product) Validate() error {
+	if p.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if p.DefaultBranch == "" {
+		return errors.New("defaultBranch must be specified")
+	}
+	for _, pattern := range p.AllowedBranches {
+		if _, matchErr := path.Match(pattern, ""); pattern == "" || matchErr != nil {
+			return fmt.Errorf("'%s' is not a valid branch pattern", pattern)
+		}
+	}
+	if !p.AllowsBranch(p.DefaultBranch) {
+		return errors.New("defaultBranch must be one of the allowed branches")
+	}
+	for _, host := range p.AllowedHosts {
+		name := strings.TrimPrefix(host, "*.")
+		if name == "" || strings.ContainsAny(name, "*/:") {
+			return fmt.Errorf("'%s' is not a valid host, expected a name like downloads.example.com or *.example.com", host)
+		}
+	}
+	return nil
+}
+
+/**
+returns true if releases of the product can be logged on branch
+*/
+func (p *Product) AllowsBranch(branch string) bool {
+	if len(p.AllowedBranches) == 0 {
+		return true
+	}
+	for _, pattern := range p.AllowedBranches {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+returns true if the product's downloads can be served from downloadUrl
+*/
+func (p *Product) AllowsDownload(downloadUrl string) bool {
+	if len(p.AllowedHosts) == 0 {
+		return true
+	}
+	//downloadUrl is allowed to leave out the scheme, see urlValidator
+	if !strings.Contains(downloadUrl, "://") {
+		downloadUrl = "https://" + downloadUrl
+	}
+	parsed, parseErr := url.Parse(downloadUrl)
+	if parseErr != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+check a new release against the branches and download hosts that the product allows
+*/
+func (p *Product) CheckRelease(ev *NewReleaseEvent) error {
+	if !p.AllowsBranch(ev.Branch) {
+		return fmt.Errorf("branch %s is not one of the allowed branches of %s", ev.Branch, p.ProductName)
+	}
+	if ev.DownloadUrl != "" && !p.AllowsDownload(ev.DownloadUrl) {
+		return fmt.Errorf("%s is not on one of the allowed download hosts of %s", ev.DownloadUrl, p.ProductName)
+	}
+	for _, artifact := range ev.Artifacts {
+		if !p.AllowsDownload(artifact.Url) {
+			return fmt.Errorf("%s is not on one of the allowed download hosts of %s", artifact.Url, p.ProductName)
+		}
+	}
+	return nil
+}
+
+/**
+ProductStore is implemented by the stores that can also hold the product registry.
+New releases are checked against it, and lookups read the default branch from it.
+*/
+type ProductStore interface {
+	//return the registry entry of a product, or nil and nil if it has not been registered
+	GetProduct(productName string) (*Product, error)
+	//return every registered product, in productName order
+	ListProducts() ([]Product, error)
+	//save the registry entry of a product, replacing any that was saved before
+	PutProduct(product *Product) error
+}
+
+/**
+returns the configured default branch of a product, or FallbackDefaultBranch if it hasn't got one.
+store can be nil, for backends that can't hold Product configuration.
+*/
+func DefaultBranchOf(store ProductStore, productName string) (string, error) {
+	if store == nil {
+		return FallbackDefaultBranch, nil
+	}
+
+	product, getErr := store.GetProduct(productName)
+	if getErr != nil {
+		return "", getErr
+	}
+	if product == nil || product.DefaultBranch == "" {
+		return FallbackDefaultBranch, nil
+	}
+	return product.DefaultBranch, nil
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestDefaultBranchOf(t *testing.T) {
+	store := NewMemoryStore()
+	store.PutProduct(&Product{ProductName: "test product", DefaultBranch: "main"})
+
+	if branch, err := DefaultBranchOf(store, "test product"); err != nil || branch != "main" {
+		t.Errorf("DefaultBranchOf should have returned the configured branch but got '%s', %s", branch, err)
+	}
+	if branch, err := DefaultBranchOf(store, "other product"); err != nil || branch != FallbackDefaultBranch {
+		t.Errorf("DefaultBranchOf an unconfigured product should have returned %s but got '%s', %s", FallbackDefaultBranch, branch, err)
+	}
+	if branch, err := DefaultBranchOf(nil, "test product"); err != nil || branch != FallbackDefaultBranch {
+		t.Errorf("DefaultBranchOf without a store should have returned %s but got '%s', %s", FallbackDefaultBranch, branch, err)
+	}
+}
+
+func TestProduct_Validate(t *testing.T) {
+	valid := Product{ProductName: "test product", DefaultBranch: "trunk"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid product failed validation: %s", err)
+	}
+
+	invalid := []Product{
+		{ProductName: "test product"},
+		{ProductName: "test product", DefaultBranch: "main", AllowedBranches: []string{"release-[0-9"}},
+		{ProductName: "test product", DefaultBranch: "main", AllowedBranches: []string{"release-*"}},
+		{ProductName: "test product", DefaultBranch: "main", AllowedHosts: []string{"https://example.com"}},
+		{ProductName: "test product", DefaultBranch: "main", AllowedHosts: []string{"downloads.*.com"}},
+	}
+	for _, product := range invalid {
+		if err := product.Validate(); err == nil {
+			t.Errorf("product should have failed validation: %v", product)
+		}
+	}
+}
+
+func TestProduct_CheckRelease(t *testing.T) {
+	product := Product{
+		ProductName:     "test product",
+		DefaultBranch:   "main",
+		AllowedBranches: []string{"main", "release/*"},
+		AllowedHosts:    []string{"downloads.example.com", "*.cdn.example.net"},
+	}
+
+	allowed := []NewReleaseEvent{
+		{Branch: "main", DownloadUrl: "https://downloads.example.com/file"},
+		{Branch: "release/2.1", DownloadUrl: "downloads.example.com/file"},
+		{Branch: "main", Artifacts: []Artifact{{Url: "https://eu.cdn.example.net:8443/setup.exe"}}},
+		{Branch: "main", DownloadUrl: "https://DOWNLOADS.example.com/file"},
+	}
+	for _, ev := range allowed {
+		if err := product.CheckRelease(&ev); err != nil {
+			t.Errorf("release should have been allowed but got %s", err)
+		}
+	}
+
+	rejected := []NewReleaseEvent{
+		{Branch: "feature/thing", DownloadUrl: "https://downloads.example.com/file"},
+		{Branch: "main", DownloadUrl: "https://example.com/file"},
+		{Branch: "main", DownloadUrl: "https://downloads.example.com.evil.org/file"},
+		{Branch: "main", DownloadUrl: "https://cdn.example.net/file"},
+		{Branch: "main", DownloadUrl: "https://downloads.example.com/file", Artifacts: []Artifact{{Url: "https://evilcdn.example.net/setup.exe"}}},
+	}
+	for _, ev := range rejected {
+		if err := product.CheckRelease(&ev); err == nil {
+			t.Errorf("release should have been rejected: %v", ev)
+		}
+	}
+}
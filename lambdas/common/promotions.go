@@ -0,0 +1,191 @@
+package common
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+/**
+PromotionStore is implemented by the stores that can also hold promoted copies of releases. A promoted copy is the
+same build, with the same buildId, published on another branch; lookups and update checks on that branch treat it
+like any other release of the branch.
+*/
+type PromotionStore interface {
+	//save a promoted copy, replacing any earlier promotion of the same build to the same branch
+	PutPromotion(ev *NewReleaseEvent) error
+	//return the copy of buildId that was promoted to branch, or nil and nil if it was not promoted there
+	GetPromotion(productName string, branch string, buildId int) (*NewReleaseEvent, error)
+	//return every build that was promoted to branch, newest buildId first
+	ListPromotions(productName string, branch string) ([]NewReleaseEvent, error)
+	//change the rollout percentage of the copy of buildId on branch in place, as ReleaseStore.UpdateRolloutPercentage does.
+	//returns the updated copy, or nil and nil if it was not promoted there
+	UpdatePromotionRolloutPercentage(productName string, branch string, buildId int, percentage int) (*NewReleaseEvent, error)
+	//mark the copy of buildId on branch as withdrawn in place, as ReleaseStore.MarkWithdrawn does.
+	//returns the updated copy, or nil and nil if it was not promoted there
+	MarkPromotionWithdrawn(productName string, branch string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error)
+	//add branch to the PromotedTo of the original release in place, unless it is already there, so that a withdrawal or
+	//rollout change made at the same time is kept. returns the updated original, or nil and nil if there is none
+	AddPromotedTo(productName string, buildId int, branch string) (*NewReleaseEvent, error)
+}
+
+/**
+request to promote a release to another branch or channel, see Promote
+*/
+type PromoteRequest struct {
+	ProductName string `json:"productName"`
+	BuildId     int    `json:"buildId"`
+	Branch      string `json:"branch"`  //the branch to publish the build on
+	Channel     string `json:"channel"` //or a channel, in which case the build is published on its first branch
+	//optional rollout percentage of the promoted copy, nil offers it to everyone
+	RolloutPercentage *int `json:"rolloutPercentage,omitempty"`
+}
+
+func (p *PromoteRequest) Validate() error {
+	if p.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if p.BuildId == 0 {
+		return errors.New("buildId must be specified")
+	}
+	if p.Branch == "" && p.Channel == "" {
+		return errors.New("branch or channel must be specified")
+	}
+	if p.Branch != "" && p.Channel != "" {
+		return errors.New("branch and channel can't both be specified")
+	}
+	if p.RolloutPercentage != nil {
+		return validateRolloutPercentage(*p.RolloutPercentage)
+	}
+	return nil
+}
+
+/**
+publish an existing release on another branch without rebuilding it. The copy keeps the buildId, downloads and
+checksums of the original and records where it came from, who promoted it and when; the original records the
+branches it was promoted to, so that withdrawing it withdraws the copies too.
+The branch is added to the original in place, so a withdrawal made while the build is being promoted is kept. If the
+original turns out to have been withdrawn by then, the copy is withdrawn as well.
+arguments:
+    - promotions: where to save the copy
+    - release: the original release, as returned by GetRelease
+    - branch: the branch to publish it on, which must not be the branch it was built on
+    - promotedBy: who asked for the promotion
+    - rolloutPercentage: rollout percentage of the copy, nil offers it to everyone
+returns the promoted copy
+*/
+func Promote(promotions PromotionStore, release *NewReleaseEvent, branch string, promotedBy string, rolloutPercentage *int) (*NewReleaseEvent, error) {
+	if branch == release.Branch {
+		return nil, errors.New("a build can't be promoted to the branch it was built on")
+	}
+
+	promoted := *release
+	promoted.Branch = branch
+	promoted.ProductBranch = ""
+	promoted.PromotedFrom = release.Branch
+	promoted.PromotedBy = promotedBy
+	promoted.PromotedAt = time.Now().UTC().Format(time.RFC3339)
+	promoted.PromotedTo = nil
+	promoted.RolloutPercentage = rolloutPercentage
+	if putErr := promotions.PutPromotion(&promoted); putErr != nil {
+		return nil, putErr
+	}
+
+	original, updateErr := promotions.AddPromotedTo(release.ProductName, release.BuildId, branch)
+	if updateErr != nil {
+		return nil, updateErr
+	}
+	if original != nil && original.Withdrawn {
+		//WithdrawRelease read PromotedTo before the branch was added, so it is up to us to withdraw the copy
+		log.Printf("%s build %d was withdrawn while it was being promoted to %s", release.ProductName, release.BuildId, branch)
+		withdrawn, withdrawErr := promotions.MarkPromotionWithdrawn(release.ProductName, branch, release.BuildId, original.WithdrawnReason, original.WithdrawnAt)
+		if withdrawErr != nil {
+			return nil, withdrawErr
+		}
+		if withdrawn != nil {
+			promoted = *withdrawn
+		}
+	}
+	log.Printf("%s promoted %s build %d from %s to %s", promotedBy, release.ProductName, release.BuildId, release.Branch, branch)
+	return &promoted, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+the newest release on a branch that has not been withdrawn, whether it was built there or promoted there.
+This is ReleaseStore.MostRecentRelease for stores that don't hold promotions.
+returns nil and nil if there is none
+*/
+func MostRecentOnBranch(store ReleaseStore, productName string, branch string) (*NewReleaseEvent, error) {
+	newest, getErr := store.MostRecentRelease(productName, branch)
+	if getErr != nil {
+		return nil, getErr
+	}
+
+	promotions, isPromotionStore := store.(PromotionStore)
+	if !isPromotionStore {
+		return newest, nil
+	}
+	promoted, listErr := promotions.ListPromotions(productName, branch)
+	if listErr != nil {
+		return nil, listErr
+	}
+	for i := range promoted {
+		if promoted[i].Withdrawn {
+			continue
+		}
+		if newest == nil || promoted[i].BuildId > newest.BuildId {
+			newest = &promoted[i]
+		}
+		break
+	}
+	return newest, nil
+}
+
+/**
+the builds promoted to the branch of the query that pass its filters, newest first. Queries that aren't for a single
+branch, and stores that don't hold promotions, have none.
+*/
+func promotionsMatching(store ReleaseStore, query *ReleaseQuery) ([]NewReleaseEvent, error) {
+	promotions, isPromotionStore := store.(PromotionStore)
+	if query.Branch == "" || !isPromotionStore {
+		return nil, nil
+	}
+
+	promoted, listErr := promotions.ListPromotions(query.ProductName, query.Branch)
+	if listErr != nil {
+		return nil, listErr
+	}
+	result := make([]NewReleaseEvent, 0, len(promoted))
+	for _, ev := range promoted {
+		if query.Matches(&ev) {
+			result = append(result, ev)
+		}
+	}
+	return result, nil
+}
+
+/**
+mark the promoted copies of a release as withdrawn along with it
+*/
+func withdrawPromotions(store ReleaseStore, release *NewReleaseEvent) error {
+	promotions, isPromotionStore := store.(PromotionStore)
+	if !isPromotionStore {
+		return nil
+	}
+
+	for _, branch := range release.PromotedTo {
+		if _, updateErr := promotions.MarkPromotionWithdrawn(release.ProductName, branch, release.BuildId, release.WithdrawnReason, release.WithdrawnAt); updateErr != nil {
+			return updateErr
+		}
+	}
+	return nil
+}
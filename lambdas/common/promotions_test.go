@@ -0,0 +1,127 @@
+package common
+
+import (
+	"github.com/davecgh/go-spew/spew"
+	"testing"
+)
+
+func TestPromote(t *testing.T) {
+	store := NewMemoryStore()
+	store.LogRelease(&NewReleaseEvent{BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product", Sha256: "abcd"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 14, Branch: "master", DownloadUrl: "https://some/url/14", ProductName: "test product"})
+
+	original, _ := store.GetRelease("test product", 12)
+	promoted, err := Promote(store, original, "master", "qa-team", nil)
+	if err != nil {
+		t.Fatalf("Promote should have succeeded but got %s", err)
+	}
+	if promoted.Branch != "master" || promoted.PromotedFrom != "release-candidate" || promoted.PromotedBy != "qa-team" || promoted.PromotedAt == "" || promoted.Sha256 != "abcd" {
+		t.Errorf("Promote returned the wrong copy: %s", spew.Sprint(promoted))
+	}
+	if updated, _ := store.GetRelease("test product", 12); len(updated.PromotedTo) != 1 || updated.PromotedTo[0] != "master" || updated.Branch != "release-candidate" {
+		t.Errorf("the original should have recorded the promotion: %s", spew.Sprint(updated))
+	}
+
+	var seen []int
+	EachRelease(store, ReleaseQuery{ProductName: "test product", Branch: "master"}, func(ev *NewReleaseEvent) bool {
+		seen = append(seen, ev.BuildId)
+		return true
+	})
+	if len(seen) != 3 || seen[0] != 14 || seen[1] != 12 || seen[2] != 10 {
+		t.Errorf("EachRelease should have slotted the promoted build in by buildId but got %v", seen)
+	}
+
+	store.DeleteRelease("test product", 14)
+	newest, _ := MostRecentOnBranch(store, "test product", "master")
+	if newest == nil || newest.BuildId != 12 || newest.PromotedFrom != "release-candidate" {
+		t.Errorf("MostRecentOnBranch should have returned the promoted build but got %s", spew.Sprint(newest))
+	}
+
+	if _, sameErr := Promote(store, original, "release-candidate", "qa-team", nil); sameErr == nil {
+		t.Errorf("promoting to the branch the build is already on should have failed")
+	}
+}
+
+func TestPromote_KeepsConcurrentWithdrawal(t *testing.T) {
+	store := NewMemoryStore()
+	store.LogRelease(&NewReleaseEvent{BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+
+	//the release is withdrawn after the promotion read it but before the promotion was recorded
+	original, _ := store.GetRelease("test product", 12)
+	WithdrawRelease(store, "test product", 12, "bad build")
+
+	promoted, err := Promote(store, original, "master", "qa-team", nil)
+	if err != nil {
+		t.Fatalf("Promote should have succeeded but got %s", err)
+	}
+	withdrawn, _ := store.GetRelease("test product", 12)
+	if !withdrawn.Withdrawn || len(withdrawn.PromotedTo) != 1 {
+		t.Errorf("Promote should have kept the withdrawal of the original and recorded the promotion: %s", spew.Sprint(withdrawn))
+	}
+	stored, _ := store.GetPromotion("test product", "master", 12)
+	if !promoted.Withdrawn || !stored.Withdrawn || stored.WithdrawnReason != "bad build" {
+		t.Errorf("the copy of a release withdrawn during promotion should have been withdrawn too: %s", spew.Sprint(stored))
+	}
+	if newest, _ := MostRecentOnBranch(store, "test product", "master"); newest != nil {
+		t.Errorf("a release withdrawn during promotion should not have been published but got %s", spew.Sprint(newest))
+	}
+}
+
+func TestWithdrawRelease_WithdrawsPromotions(t *testing.T) {
+	store := NewMemoryStore()
+	store.LogRelease(&NewReleaseEvent{BuildId: 10, Branch: "master", DownloadUrl: "https://some/url/10", ProductName: "test product"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+	original, _ := store.GetRelease("test product", 12)
+	Promote(store, original, "master", "qa-team", nil)
+
+	if _, err := WithdrawRelease(store, "test product", 12, "bad build"); err != nil {
+		t.Fatalf("WithdrawRelease should have succeeded but got %s", err)
+	}
+
+	promoted, _ := store.GetPromotion("test product", "master", 12)
+	if promoted == nil || !promoted.Withdrawn || promoted.WithdrawnReason != "bad build" {
+		t.Errorf("the promoted copy should have been withdrawn too: %s", spew.Sprint(promoted))
+	}
+	newest, _ := MostRecentOnBranch(store, "test product", "master")
+	if newest == nil || newest.BuildId != 10 {
+		t.Errorf("MostRecentOnBranch should have skipped the withdrawn promotion but got %s", spew.Sprint(newest))
+	}
+}
+
+/**
+a store whose reads always return the release as it was before anything else changed it, as a read that races
+with another request would
+*/
+type staleReadStore struct {
+	*MemoryStore
+	stale NewReleaseEvent
+}
+
+func (s *staleReadStore) GetRelease(productName string, buildId int) (*NewReleaseEvent, error) {
+	stale := s.stale
+	return &stale, nil
+}
+
+func TestWithdrawRelease_KeepsConcurrentChanges(t *testing.T) {
+	store := &staleReadStore{MemoryStore: NewMemoryStore()}
+	store.LogRelease(&NewReleaseEvent{BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+	original, _ := store.MemoryStore.GetRelease("test product", 12)
+	store.stale = *original
+	Promote(store, original, "master", "qa-team", nil)
+	SetRolloutPercentage(store.MemoryStore, "test product", 12, "", 25)
+	SetRolloutPercentage(store.MemoryStore, "test product", 12, "master", 10)
+
+	if _, err := WithdrawRelease(store, "test product", 12, "bad build"); err != nil {
+		t.Fatalf("WithdrawRelease should have succeeded but got %s", err)
+	}
+
+	withdrawn, _ := store.MemoryStore.GetRelease("test product", 12)
+	if !withdrawn.Withdrawn || withdrawn.RolloutPercentage == nil || *withdrawn.RolloutPercentage != 25 || len(withdrawn.PromotedTo) != 1 {
+		t.Errorf("WithdrawRelease should have kept the rollout and promotion made since the release was read: %s", spew.Sprint(withdrawn))
+	}
+	promoted, _ := store.GetPromotion("test product", "master", 12)
+	if !promoted.Withdrawn || promoted.RolloutPercentage == nil || *promoted.RolloutPercentage != 10 {
+		t.Errorf("WithdrawRelease should have kept the rollout of the promoted copy: %s", spew.Sprint(promoted))
+	}
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"log"
+)
+
+//a client identifier is only hashed, but there is no reason for one to be this long
+const MaxClientIdLength = 256
+
+/**
+put a client into one of 100 buckets, numbered 0 to 99. The same client always lands in the same bucket for a product,
+so as a rollout grows the clients that already had the new build keep it. The product name is mixed in so that it's
+not the same clients who go first for every product.
+*/
+func RolloutBucket(productName string, clientId string) int {
+	hash := sha256.Sum256([]byte(productName + "\x00" + clientId))
+	return int(binary.BigEndian.Uint64(hash[:8]) % 100)
+}
+
+/**
+returns true if the release should be offered to the given client. Releases without a RolloutPercentage are offered
+to everyone; otherwise the client is offered the release if its bucket is inside the percentage. Clients that don't
+send an identifier are only offered a release once it is rolled out to 100%.
+*/
+func (e *NewReleaseEvent) OfferedTo(clientId string) bool {
+	if e.RolloutPercentage == nil {
+		return true
+	}
+	if clientId == "" {
+		return *e.RolloutPercentage >= 100
+	}
+	return RolloutBucket(e.ProductName, clientId) < *e.RolloutPercentage
+}
+
+func validateRolloutPercentage(percentage int) error {
+	if percentage < 0 || percentage > 100 {
+		return errors.New("rolloutPercentage must be between 0 and 100")
+	}
+	return nil
+}
+
+/**
+request to change the rollout percentage of a release, see SetRolloutPercentage
+*/
+type RolloutRequest struct {
+	ProductName string `json:"productName"`
+	BuildId     int    `json:"buildId"`
+	//optional branch that the build was promoted to. A promoted copy has a rollout of its own (see Promote), so this
+	//changes the copy on that branch instead of the original
+	Branch            string `json:"branch,omitempty"`
+	RolloutPercentage *int   `json:"rolloutPercentage"`
+}
+
+func (r *RolloutRequest) Validate() error {
+	if r.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if r.BuildId == 0 {
+		return errors.New("buildId must be specified")
+	}
+	if r.RolloutPercentage == nil {
+		return errors.New("rolloutPercentage must be specified")
+	}
+	return validateRolloutPercentage(*r.RolloutPercentage)
+}
+
+/**
+change the percentage of clients that a release is offered to. Setting it to 100 offers the release to everyone,
+including clients that don't send an identifier.
+Rollout is per copy: the original and each copy that was promoted to another branch have their own percentage, so
+that a build can be fully rolled out on one branch while it is still being tried on another. branch picks the copy to
+change; it is empty (or the branch the release was built on) for the original.
+Only the percentage is written, so a withdrawal made at the same time isn't undone.
+returns the updated release or copy, or nil and nil if there is no such release or it wasn't promoted to branch
+*/
+func SetRolloutPercentage(store ReleaseStore, productName string, buildId int, branch string, percentage int) (*NewReleaseEvent, error) {
+	if validationErr := validateRolloutPercentage(percentage); validationErr != nil {
+		return nil, validationErr
+	}
+
+	var updated *NewReleaseEvent
+	var updateErr error
+	if branch == "" {
+		updated, updateErr = store.UpdateRolloutPercentage(productName, buildId, percentage)
+	} else {
+		updated, updateErr = setBranchRolloutPercentage(store, productName, buildId, branch, percentage)
+	}
+	if updated == nil || updateErr != nil {
+		return nil, updateErr
+	}
+	log.Printf("Set rollout of %s build %d on %s to %d%%", productName, buildId, updated.Branch, percentage)
+	return updated, nil
+}
+
+/**
+change the rollout percentage of whichever copy of a build is on branch, see SetRolloutPercentage
+*/
+func setBranchRolloutPercentage(store ReleaseStore, productName string, buildId int, branch string, percentage int) (*NewReleaseEvent, error) {
+	release, getErr := store.GetRelease(productName, buildId)
+	if release == nil || getErr != nil {
+		return nil, getErr
+	}
+	if release.Branch == branch {
+		return store.UpdateRolloutPercentage(productName, buildId, percentage)
+	}
+
+	promotions, isPromotionStore := store.(PromotionStore)
+	if !isPromotionStore {
+		return nil, nil
+	}
+	return promotions.UpdatePromotionRolloutPercentage(productName, branch, buildId, percentage)
+}
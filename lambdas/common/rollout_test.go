@@ -0,0 +1,127 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRolloutBucket(t *testing.T) {
+	if RolloutBucket("test product", "client-1") != RolloutBucket("test product", "client-1") {
+		t.Errorf("the same client should always get the same bucket")
+	}
+
+	counts := make([]int, 100)
+	for i := 0; i < 10000; i++ {
+		bucket := RolloutBucket("test product", fmt.Sprintf("client-%d", i))
+		if bucket < 0 || bucket > 99 {
+			t.Fatalf("bucket %d is out of range", bucket)
+		}
+		counts[bucket]++
+	}
+	//10000 clients over 100 buckets should be roughly even
+	for bucket, count := range counts {
+		if count < 50 || count > 150 {
+			t.Errorf("bucket %d got %d clients, the hash is not spreading them evenly", bucket, count)
+		}
+	}
+}
+
+func TestNewReleaseEvent_OfferedTo(t *testing.T) {
+	everyone := NewReleaseEvent{ProductName: "test product"}
+	if !everyone.OfferedTo("") || !everyone.OfferedTo("client-1") {
+		t.Errorf("a release without a rollout percentage should be offered to everyone")
+	}
+
+	zero, five, full := 0, 5, 100
+	if (&NewReleaseEvent{ProductName: "test product", RolloutPercentage: &zero}).OfferedTo("client-1") {
+		t.Errorf("a release at 0%% should not be offered to anyone")
+	}
+	if !(&NewReleaseEvent{ProductName: "test product", RolloutPercentage: &full}).OfferedTo("") {
+		t.Errorf("a release at 100%% should be offered to clients without an identifier")
+	}
+	if (&NewReleaseEvent{ProductName: "test product", RolloutPercentage: &five}).OfferedTo("") {
+		t.Errorf("a release at 5%% should not be offered to clients without an identifier")
+	}
+
+	offered := 0
+	partial := NewReleaseEvent{ProductName: "test product", RolloutPercentage: &five}
+	for i := 0; i < 1000; i++ {
+		if partial.OfferedTo(fmt.Sprintf("client-%d", i)) {
+			offered++
+		}
+	}
+	if offered < 20 || offered > 80 {
+		t.Errorf("a release at 5%% was offered to %d out of 1000 clients", offered)
+	}
+}
+
+func TestSetRolloutPercentage(t *testing.T) {
+	store := NewMemoryStore()
+	populateStore(t, store)
+
+	updated, err := SetRolloutPercentage(store, "test product", 26, "", 25)
+	if err != nil || updated == nil || *updated.RolloutPercentage != 25 {
+		t.Fatalf("SetRolloutPercentage returned the wrong record: %v, %s", updated, err)
+	}
+	stored, _ := store.GetRelease("test product", 26)
+	if stored.RolloutPercentage == nil || *stored.RolloutPercentage != 25 {
+		t.Errorf("SetRolloutPercentage did not save the percentage")
+	}
+
+	if _, err := SetRolloutPercentage(store, "test product", 26, "", 101); err == nil {
+		t.Errorf("SetRolloutPercentage should have rejected a percentage over 100")
+	}
+	missing, err := SetRolloutPercentage(store, "test product", 99, "", 50)
+	if err != nil || missing != nil {
+		t.Errorf("SetRolloutPercentage of a missing release should have returned nil, nil but got %v, %s", missing, err)
+	}
+}
+
+func TestSetRolloutPercentage_Branch(t *testing.T) {
+	store := NewMemoryStore()
+	store.LogRelease(&NewReleaseEvent{BuildId: 12, Branch: "release-candidate", DownloadUrl: "https://some/url/12", ProductName: "test product"})
+	original, _ := store.GetRelease("test product", 12)
+	Promote(store, original, "master", "qa-team", nil)
+
+	updated, err := SetRolloutPercentage(store, "test product", 12, "master", 10)
+	if err != nil || updated == nil || updated.Branch != "master" || *updated.RolloutPercentage != 10 {
+		t.Fatalf("SetRolloutPercentage returned the wrong copy: %v, %s", updated, err)
+	}
+	promoted, _ := store.GetPromotion("test product", "master", 12)
+	if promoted.RolloutPercentage == nil || *promoted.RolloutPercentage != 10 {
+		t.Errorf("SetRolloutPercentage did not save the percentage of the promoted copy")
+	}
+	original, _ = store.GetRelease("test product", 12)
+	if original.RolloutPercentage != nil {
+		t.Errorf("SetRolloutPercentage of a promoted copy should not have changed the original")
+	}
+
+	updated, err = SetRolloutPercentage(store, "test product", 12, "release-candidate", 50)
+	if err != nil || updated == nil || updated.Branch != "release-candidate" || *updated.RolloutPercentage != 50 {
+		t.Errorf("SetRolloutPercentage on the branch of the original returned the wrong record: %v, %s", updated, err)
+	}
+
+	missing, err := SetRolloutPercentage(store, "test product", 12, "some-other-branch", 50)
+	if err != nil || missing != nil {
+		t.Errorf("SetRolloutPercentage on a branch the release wasn't promoted to should have returned nil, nil but got %v, %s", missing, err)
+	}
+}
+
+func TestSetRolloutPercentage_KeepsWithdrawal(t *testing.T) {
+	store := NewMemoryStore()
+	populateStore(t, store)
+
+	//a withdrawal that lands between reading the release and writing the percentage must not be undone
+	release, _ := store.GetRelease("test product", 26)
+	release.Withdrawn = true
+	store.LogRelease(release)
+
+	updated, err := SetRolloutPercentage(store, "test product", 26, "", 25)
+	if err != nil || updated == nil {
+		t.Fatalf("SetRolloutPercentage failed: %v, %s", updated, err)
+	}
+	stored, _ := store.GetRelease("test product", 26)
+	if !stored.Withdrawn || *stored.RolloutPercentage != 25 {
+		t.Errorf("SetRolloutPercentage should have kept the withdrawal and saved the percentage: %v", stored)
+	}
+}
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//the regex suggested by semver.org, without the leading v that some tools like to add
+var semverValidator = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+/**
+a parsed semantic version, see https://semver.org
+*/
+type SemVer struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	PreRelease []string //empty for a normal release
+	Build      string   //build metadata, ignored when comparing
+}
+
+func ParseSemVer(version string) (*SemVer, error) {
+	parts := semverValidator.FindStringSubmatch(version)
+	if parts == nil {
+		return nil, fmt.Errorf("'%s' is not a valid semantic version", version)
+	}
+
+	var v SemVer
+	var parseErr error
+	if v.Major, parseErr = strconv.ParseUint(parts[1], 10, 64); parseErr != nil {
+		return nil, fmt.Errorf("major version of '%s' is out of range", version)
+	}
+	if v.Minor, parseErr = strconv.ParseUint(parts[2], 10, 64); parseErr != nil {
+		return nil, fmt.Errorf("minor version of '%s' is out of range", version)
+	}
+	if v.Patch, parseErr = strconv.ParseUint(parts[3], 10, 64); parseErr != nil {
+		return nil, fmt.Errorf("patch version of '%s' is out of range", version)
+	}
+	if parts[4] != "" {
+		v.PreRelease = strings.Split(parts[4], ".")
+		for _, identifier := range v.PreRelease {
+			if isNumericIdentifier(identifier) && len(identifier) > 20 {
+				return nil, fmt.Errorf("pre-release identifier '%s' of '%s' is out of range", identifier, version)
+			}
+		}
+	}
+	v.Build = parts[5]
+	return &v, nil
+}
+
+func isNumericIdentifier(identifier string) bool {
+	for _, c := range identifier {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+compare two pre-release identifiers. Numeric identifiers compare as numbers and always have lower precedence
+than alphanumeric ones, which compare in ASCII order
+*/
+func compareIdentifiers(a string, b string) int {
+	aNumeric := isNumericIdentifier(a)
+	bNumeric := isNumericIdentifier(b)
+
+	switch {
+	case aNumeric && bNumeric:
+		//identifiers can be longer than will fit in an int, but never have leading zeroes
+		if len(a) != len(b) {
+			return compareInts(len(a), len(b))
+		}
+		return strings.Compare(a, b)
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInts(a int, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareUints(a uint64, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+/**
+compare the precedence of two versions according to semver rules.
+returns -1 if v is older than other, 1 if it is newer and 0 if they have the same precedence
+*/
+func (v *SemVer) Compare(other *SemVer) int {
+	if result := compareUints(v.Major, other.Major); result != 0 {
+		return result
+	}
+	if result := compareUints(v.Minor, other.Minor); result != 0 {
+		return result
+	}
+	if result := compareUints(v.Patch, other.Patch); result != 0 {
+		return result
+	}
+
+	//a pre-release has lower precedence than the normal release
+	if len(v.PreRelease) == 0 || len(other.PreRelease) == 0 {
+		return -compareInts(len(v.PreRelease), len(other.PreRelease))
+	}
+
+	for i := 0; i < len(v.PreRelease) && i < len(other.PreRelease); i++ {
+		if result := compareIdentifiers(v.PreRelease[i], other.PreRelease[i]); result != 0 {
+			return result
+		}
+	}
+	//a larger set of pre-release fields has higher precedence if all the preceding ones are equal
+	return compareInts(len(v.PreRelease), len(other.PreRelease))
+}
+
+/**
+encode the version as a string that sorts in the same order as Compare, so that it can be used as a database key.
+major, minor and patch are zero-padded, then normal releases get a "~" suffix which sorts after any pre-release.
+pre-release identifiers are separated by "!", which sorts before any character allowed in an identifier, and
+numeric identifiers are zero-padded behind a "0" so that they sort before alphanumeric ones (behind a "1").
+build metadata is not included, as it has no effect on precedence.
+*/
+func (v *SemVer) SortKey() string {
+	key := fmt.Sprintf("%020d.%020d.%020d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) == 0 {
+		return key + "~"
+	}
+
+	encoded := make([]string, len(v.PreRelease))
+	for i, identifier := range v.PreRelease {
+		if isNumericIdentifier(identifier) {
+			encoded[i] = "0" + strings.Repeat("0", 20-len(identifier)) + identifier
+		} else {
+			encoded[i] = "1" + identifier
+		}
+	}
+	return key + "!" + strings.Join(encoded, "!")
+}
+
+func (v *SemVer) String() string {
+	result := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		result += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if v.Build != "" {
+		result += "+" + v.Build
+	}
+	return result
+}
+
+/**
+compare two releases, using semver precedence where both have one and buildId otherwise.
+releases that have a semver are always newer than ones that don't, so that the ordering is consistent
+when a product starts using semver part way through its history.
+returns -1 if a is older than b, 1 if it is newer and 0 if they are the same
+*/
+func CompareReleases(a *NewReleaseEvent, b *NewReleaseEvent) int {
+	aVersion, _ := a.ParsedSemver()
+	bVersion, _ := b.ParsedSemver()
+
+	if aVersion != nil && bVersion != nil {
+		if result := aVersion.Compare(bVersion); result != 0 {
+			return result
+		}
+	} else if aVersion != nil {
+		return 1
+	} else if bVersion != nil {
+		return -1
+	}
+	return compareInts(a.BuildId, b.BuildId)
+}
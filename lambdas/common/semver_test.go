@@ -0,0 +1,200 @@
+package common
+
+import (
+	"sort"
+	"testing"
+)
+
+//in increasing order of precedence, from semver.org plus a few of our own
+var orderedVersions = []string{
+	"0.9.12",
+	"1.0.0-alpha",
+	"1.0.0-alpha.1",
+	"1.0.0-alpha.beta",
+	"1.0.0-beta",
+	"1.0.0-beta.2",
+	"1.0.0-beta.11",
+	"1.0.0-rc.1",
+	"1.0.0",
+	"2.14.0-rc.3",
+	"2.14.0-rc.3-x",
+	"2.14.0",
+	"2.14.1",
+	"10.0.0",
+}
+
+func TestParseSemVer(t *testing.T) {
+	v, err := ParseSemVer("2.14.0-rc.3+build.99")
+	if err != nil {
+		t.Fatalf("valid version should have parsed but got %s", err)
+	}
+	if v.Major != 2 || v.Minor != 14 || v.Patch != 0 {
+		t.Errorf("version numbers were wrong, got %d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+	if len(v.PreRelease) != 2 || v.PreRelease[0] != "rc" || v.PreRelease[1] != "3" {
+		t.Errorf("pre-release was wrong, got %v", v.PreRelease)
+	}
+	if v.Build != "build.99" {
+		t.Errorf("build metadata was wrong, got %s", v.Build)
+	}
+	if v.String() != "2.14.0-rc.3+build.99" {
+		t.Errorf("version did not format back to its original form, got %s", v.String())
+	}
+
+	for _, invalid := range []string{"", "1", "1.2", "v1.2.3", "01.2.3", "1.2.3-", "1.2.3-01", "1.2.3-rc..1", "1.2.3+"} {
+		if _, err := ParseSemVer(invalid); err == nil {
+			t.Errorf("'%s' should not have parsed", invalid)
+		}
+	}
+}
+
+func TestSemVer_Compare(t *testing.T) {
+	for i := range orderedVersions {
+		for j := range orderedVersions {
+			a, _ := ParseSemVer(orderedVersions[i])
+			b, _ := ParseSemVer(orderedVersions[j])
+			expected := compareInts(i, j)
+			if result := a.Compare(b); result != expected {
+				t.Errorf("comparing %s to %s should have given %d but got %d", a, b, expected, result)
+			}
+			keyResult := 0
+			if a.SortKey() < b.SortKey() {
+				keyResult = -1
+			} else if a.SortKey() > b.SortKey() {
+				keyResult = 1
+			}
+			if keyResult != expected {
+				t.Errorf("sort keys for %s and %s are in the wrong order: %s, %s", a, b, a.SortKey(), b.SortKey())
+			}
+		}
+	}
+
+	withBuild, _ := ParseSemVer("1.0.0+abc")
+	withoutBuild, _ := ParseSemVer("1.0.0")
+	if withBuild.Compare(withoutBuild) != 0 {
+		t.Errorf("build metadata should not affect precedence")
+	}
+}
+
+func TestCompareReleases(t *testing.T) {
+	older := NewReleaseEvent{BuildId: 20, Semver: "2.0.0"}
+	newer := NewReleaseEvent{BuildId: 10, Semver: "2.1.0-rc.1"}
+	noSemver := NewReleaseEvent{BuildId: 30}
+	sameSemver := NewReleaseEvent{BuildId: 21, Semver: "2.0.0"}
+
+	if CompareReleases(&newer, &older) != 1 {
+		t.Errorf("semver should take precedence over buildId")
+	}
+	if CompareReleases(&noSemver, &older) != -1 {
+		t.Errorf("releases without a semver should be older than ones with one")
+	}
+	if CompareReleases(&sameSemver, &older) != 1 {
+		t.Errorf("buildId should be used when semvers are equal")
+	}
+}
+
+func TestMostRecentReleaseBySemver(t *testing.T) {
+	store := NewMemoryStore()
+	store.LogRelease(&NewReleaseEvent{BuildId: 1, Branch: "master", ProductName: "test product", Semver: "2.14.0"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 2, Branch: "master", ProductName: "test product", Semver: "2.15.0-rc.1"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 3, Branch: "master", ProductName: "test product", Semver: "2.14.1"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 4, Branch: "otherbranch", ProductName: "test product", Semver: "3.0.0"})
+
+	result, err := MostRecentReleaseBySemver(store, "test product", "master")
+	if err != nil {
+		t.Fatalf("semver lookup should have succeeded but got %s", err)
+	}
+	if result == nil || result.BuildId != 2 {
+		t.Errorf("semver lookup returned the wrong release: %v", result)
+	}
+
+	missing, err := MostRecentReleaseBySemver(store, "test product", "nobranch")
+	if err != nil || missing != nil {
+		t.Errorf("semver lookup for an unknown branch should have returned nil, nil but got %v, %s", missing, err)
+	}
+}
+
+/**
+a MemoryStore with a semver index, standing in for DynamoStore's. It counts the releases read from the index.
+*/
+type semverIndexedMemoryStore struct {
+	*MemoryStore
+	read       int
+	notCreated bool
+}
+
+func (s *semverIndexedMemoryStore) HasSemverIndex() bool {
+	return !s.notCreated
+}
+
+func (s *semverIndexedMemoryStore) EachReleaseBySemver(productName string, branch string, fn func(ev *NewReleaseEvent) bool) error {
+	indexed := make([]NewReleaseEvent, 0)
+	for _, ev := range s.releasesFor(productName) {
+		if ev.Branch == branch && ev.Semver != "" && !ev.Withdrawn {
+			indexed = append(indexed, ev)
+		}
+	}
+	sort.SliceStable(indexed, func(i, j int) bool {
+		return semverKeyOf(&indexed[i]) > semverKeyOf(&indexed[j])
+	})
+	for i := range indexed {
+		s.read++
+		if !fn(&indexed[i]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestFindRelease_SemverIndex(t *testing.T) {
+	store := &semverIndexedMemoryStore{MemoryStore: NewMemoryStore()}
+	store.LogRelease(&NewReleaseEvent{BuildId: 1, Branch: "master", ProductName: "test product", Semver: "2.14.0"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 2, Branch: "master", ProductName: "test product", Semver: "2.15.0-rc.1"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 3, Branch: "master", ProductName: "test product", Semver: "2.15.0-rc.1+rebuild"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 4, Branch: "master", ProductName: "test product", Semver: "2.14.1"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 5, Branch: "master", ProductName: "test product", Semver: "3.0.0", Withdrawn: true})
+	store.LogRelease(&NewReleaseEvent{BuildId: 6, Branch: "master", ProductName: "test product"})
+
+	result, err := MostRecentReleaseBySemver(store, "test product", "master")
+	if err != nil || result == nil || result.BuildId != 3 {
+		t.Errorf("semver lookup should have found build 3, the higher buildId of the two 2.15.0-rc.1 builds, but got %v, %s", result, err)
+	}
+	if store.read != 3 {
+		t.Errorf("semver lookup should have stopped reading the index after 2.15.0-rc.1 but read %d releases", store.read)
+	}
+
+	store.read = 0
+	stable, err := FindRelease(store, "test product", "master", OrderBySemver, func(ev *NewReleaseEvent) bool {
+		return ev.BuildId != 2 && ev.BuildId != 3
+	})
+	if err != nil || stable == nil || stable.BuildId != 4 {
+		t.Errorf("semver lookup with a filter should have found build 4 but got %v, %s", stable, err)
+	}
+
+	store.PutPromotion(&NewReleaseEvent{BuildId: 7, Branch: "master", ProductName: "test product", Semver: "2.16.0", PromotedFrom: "develop"})
+	promoted, err := MostRecentReleaseBySemver(store, "test product", "master")
+	if err != nil || promoted == nil || promoted.BuildId != 7 {
+		t.Errorf("semver lookup should have found the promoted build 7 but got %v, %s", promoted, err)
+	}
+
+	legacy, err := FindRelease(store, "test product", "master", OrderBySemver, func(ev *NewReleaseEvent) bool {
+		return ev.Semver == ""
+	})
+	if err != nil || legacy == nil || legacy.BuildId != 6 {
+		t.Errorf("semver lookup should have fallen back to buildId order for releases without a semver but got %v, %s", legacy, err)
+	}
+}
+
+func TestFindRelease_SemverIndexNotCreated(t *testing.T) {
+	store := &semverIndexedMemoryStore{MemoryStore: NewMemoryStore(), notCreated: true}
+	store.LogRelease(&NewReleaseEvent{BuildId: 1, Branch: "master", ProductName: "test product", Semver: "2.15.0"})
+	store.LogRelease(&NewReleaseEvent{BuildId: 2, Branch: "master", ProductName: "test product", Semver: "2.14.1"})
+
+	result, err := MostRecentReleaseBySemver(store, "test product", "master")
+	if err != nil || result == nil || result.BuildId != 1 {
+		t.Errorf("semver lookup should have found build 1 but got %v, %s", result, err)
+	}
+	if store.read != 0 {
+		t.Errorf("semver lookup should not have read an index that has not been created yet but read %d releases", store.read)
+	}
+}
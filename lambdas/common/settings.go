@@ -0,0 +1,79 @@
+package common
+
+import (
+	"errors"
+)
+
+/**
+per-branch configuration that is set by an administrator rather than by the build pipeline
+*/
+type BranchSettings struct {
+	ProductName string `json:"productName"`
+	Branch      string `json:"branch"`
+	//clients running an older build than this must update. 0 means there is no minimum.
+	MinimumBuildId int `json:"minimumBuildId"`
+}
+
+func (s *BranchSettings) Validate() error {
+	if s.ProductName == "" {
+		return errors.New("productName must be specified")
+	}
+	if s.Branch == "" {
+		return errors.New("branch must be specified")
+	}
+	if s.MinimumBuildId < 0 {
+		return errors.New("minimumBuildId can't be negative")
+	}
+	return nil
+}
+
+/**
+SettingsStore is implemented by the stores that can also hold BranchSettings.
+Lookups read them to tell clients on an old build that they must update.
+*/
+type SettingsStore interface {
+	//return the settings for a branch, or nil and nil if none have been saved
+	GetBranchSettings(productName string, branch string) (*BranchSettings, error)
+	//save the settings for a branch, replacing any that were saved before
+	PutBranchSettings(settings *BranchSettings) error
+}
+
+const UpdateRequired = "required"
+const UpdateOptional = "optional"
+const UpdateNone = "none"
+
+/**
+work out whether a client on currentBuildId has to update to latest.
+An update is required if the client is older than the minimum build for the branch, or if any release newer than
+the client is marked mandatory (and hasn't been withdrawn); otherwise it is optional if latest is newer than the client.
+Releases are compared to the client with CompareToClient, so semver precedence is used if the client gave its semver.
+arguments:
+    - store: the ReleaseStore to look for mandatory releases in
+    - latest: the release that the client would update to, nil if there is none
+    - settings: the settings for the branch, nil if there are none
+    - currentBuildId: the build that the client is running
+    - currentSemver: the semver of the build that the client is running, "" if it didn't give one
+    - accept: optional function to narrow down the releases that count, e.g. to the client's platform. nil accepts everything
+returns one of UpdateRequired, UpdateOptional or UpdateNone
+*/
+func UpdateStatus(store ReleaseStore, latest *NewReleaseEvent, settings *BranchSettings, currentBuildId int, currentSemver string, accept func(ev *NewReleaseEvent) bool) (string, error) {
+	if latest == nil || CompareToClient(latest, currentBuildId, currentSemver) <= 0 {
+		return UpdateNone, nil
+	}
+	if settings != nil && currentBuildId < settings.MinimumBuildId {
+		return UpdateRequired, nil
+	}
+
+	status := UpdateOptional
+	err := eachNewerRelease(store, latest.ProductName, latest.Branch, currentBuildId, currentSemver, func(ev *NewReleaseEvent) bool {
+		if ev.Mandatory && (accept == nil || accept(ev)) {
+			status = UpdateRequired
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+/**
+parse a base64-encoded Ed25519 private key, as given in configuration. Either the 32-byte seed or the 64-byte
+private key is accepted.
+*/
+func LoadSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	raw, decodeErr := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if decodeErr != nil {
+		return nil, fmt.Errorf("signing key is not valid base64: %s", decodeErr)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("signing key should be %d or %d bytes but is %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+/**
+make a base64-encoded detached signature of payload, which verify.Signature can check. Responses are signed with the
+request they answer and the time, see verify.Payload, rather than just their body.
+*/
+func SignPayload(key ed25519.PrivateKey, payload []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+}
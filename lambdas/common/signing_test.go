@@ -0,0 +1,44 @@
+package common
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"github.com/fredex42/downloadmanager-versions-api/lambdas/verify"
+	"testing"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+
+	fromSeed, seedErr := LoadSigningKey(base64.StdEncoding.EncodeToString(privateKey.Seed()))
+	if seedErr != nil {
+		t.Errorf("seed should have loaded but got %s", seedErr)
+	} else if !bytes.Equal(privateKey, fromSeed) {
+		t.Errorf("key loaded from seed was wrong")
+	}
+
+	fromKey, keyErr := LoadSigningKey(base64.StdEncoding.EncodeToString(privateKey) + "\n")
+	if keyErr != nil {
+		t.Errorf("private key should have loaded but got %s", keyErr)
+	} else if !bytes.Equal(privateKey, fromKey) {
+		t.Errorf("key loaded from private key was wrong")
+	}
+
+	if _, err := LoadSigningKey("not base64!"); err == nil {
+		t.Errorf("malformed key should not have loaded")
+	}
+	if _, err := LoadSigningKey(base64.StdEncoding.EncodeToString([]byte("too short"))); err == nil {
+		t.Errorf("short key should not have loaded")
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	body := []byte(`[{"buildId":12}]`)
+
+	signature := SignPayload(privateKey, body)
+	if err := verify.Signature(publicKey, body, signature); err != nil {
+		t.Errorf("signature should have verified but got %s", err)
+	}
+}
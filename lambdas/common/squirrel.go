@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//the response formats that /lookup can give, see SearchRequest.Format
+const FormatJSON = "json"
+const FormatSquirrelWindows = "squirrel-windows"
+const FormatSquirrelMac = "squirrel-mac"
+
+/**
+the platform that a response format is for, or an empty string if it can be used on any platform
+*/
+func FormatOs(format string) string {
+	switch format {
+	case FormatSquirrelWindows:
+		return "windows"
+	case FormatSquirrelMac:
+		return "macos"
+	default:
+		return ""
+	}
+}
+
+/**
+the update that Squirrel.Mac expects when there is one, see
+https://github.com/Squirrel/Squirrel.Mac#update-json-format
+*/
+type SquirrelMacUpdate struct {
+	Url     string `json:"url"`
+	Name    string `json:"name"`
+	Notes   string `json:"notes,omitempty"`
+	PubDate string `json:"pub_date,omitempty"`
+}
+
+/**
+build the Squirrel.Mac update for a release that has already been narrowed down to a single download with ForPlatform.
+notes are the release's notes for lang, in whatever format they are in.
+*/
+func NewSquirrelMacUpdate(release *NewReleaseEvent, lang string) *SquirrelMacUpdate {
+	update := &SquirrelMacUpdate{
+		Url:     release.DownloadUrl,
+		Name:    release.Semver,
+		PubDate: release.Timestamp,
+	}
+	if update.Name == "" {
+		update.Name = strconv.Itoa(release.BuildId)
+	}
+	_, update.Notes = release.ReleaseNotes.Select(lang)
+	return update
+}
+
+/**
+the line of a Squirrel.Windows RELEASES file for a release that has already been narrowed down to a single download
+with ForPlatform, i.e. "{SHA1} {url} {size}". Squirrel reads the version from the file name at the end of the URL, so
+it has to point at a full .nupkg package.
+returns an error if the release doesn't have the SHA1 that Squirrel checks the download against
+*/
+func SquirrelReleasesLine(release *NewReleaseEvent) (string, error) {
+	if release.Sha1 == "" {
+		return "", fmt.Errorf("%s build %d has no sha1 for %s, which Squirrel.Windows needs", release.ProductName, release.BuildId, release.DownloadUrl)
+	}
+	return fmt.Sprintf("%s %s %d", strings.ToUpper(release.Sha1), release.DownloadUrl, release.Size), nil
+}
@@ -0,0 +1,44 @@
+package common
+
+import "testing"
+
+func TestSquirrelReleasesLine(t *testing.T) {
+	release := NewReleaseEvent{
+		BuildId:     123,
+		ProductName: "some product",
+		DownloadUrl: "https://someurl.server.com/SomeProduct-2.14.0-full.nupkg",
+		Size:        1234,
+		Sha1:        "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+	}
+	line, err := SquirrelReleasesLine(&release)
+	if err != nil {
+		t.Fatalf("SquirrelReleasesLine should have succeeded but got %s", err)
+	}
+	if line != "A94A8FE5CCB19BA61C4C0873D391E987982FBBD3 https://someurl.server.com/SomeProduct-2.14.0-full.nupkg 1234" {
+		t.Errorf("SquirrelReleasesLine returned the wrong line: %s", line)
+	}
+
+	release.Sha1 = ""
+	if _, err := SquirrelReleasesLine(&release); err == nil {
+		t.Errorf("SquirrelReleasesLine without a sha1 should have failed")
+	}
+}
+
+func TestNewSquirrelMacUpdate(t *testing.T) {
+	release := NewReleaseEvent{
+		BuildId:      123,
+		ProductName:  "some product",
+		DownloadUrl:  "https://someurl.server.com/app.zip",
+		Timestamp:    "2019-11-01T10:00:00Z",
+		ReleaseNotes: ReleaseNotes{"en": "Fixed crashes", "fr": "Correction des plantages"},
+	}
+	update := NewSquirrelMacUpdate(&release, "fr-CA")
+	if update.Url != release.DownloadUrl || update.Name != "123" || update.PubDate != "2019-11-01T10:00:00Z" || update.Notes != "Correction des plantages" {
+		t.Errorf("NewSquirrelMacUpdate returned the wrong update: %v", update)
+	}
+
+	release.Semver = "2.14.0"
+	if named := NewSquirrelMacUpdate(&release, ""); named.Name != "2.14.0" || named.Notes != "Fixed crashes" {
+		t.Errorf("NewSquirrelMacUpdate should have used the semver and English notes but got %v", named)
+	}
+}
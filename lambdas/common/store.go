@@ -0,0 +1,311 @@
+package common
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/**
+ReleaseStore is implemented by everything that can hold release records.
+DynamoStore is used when running in Lambda, MemoryStore and BoltStore let the handlers (and tests) run without AWS.
+*/
+type ReleaseStore interface {
+	//write a new release record, replacing any existing record with the same productName and buildId
+	LogRelease(ev *NewReleaseEvent) error
+	//write a new release record, or return ErrReleaseExists if there is already one with the same productName and buildId
+	CreateRelease(ev *NewReleaseEvent) error
+	//return the newest release of productName on branch that has not been withdrawn, or nil and nil if there is none
+	MostRecentRelease(productName string, branch string) (*NewReleaseEvent, error)
+	//return the release with the given productName and buildId, or nil and nil if there is none
+	GetRelease(productName string, buildId int) (*NewReleaseEvent, error)
+	//return a page of release history for a product, newest first
+	ListReleases(query *ReleaseQuery) (*ReleasePage, error)
+	//remove the release with the given productName and buildId. Removing a release that does not exist is not an error.
+	DeleteRelease(productName string, buildId int) error
+	//change the rollout percentage of a release in place, without writing back the rest of the record, so that it can't
+	//undo a change made at the same time such as a withdrawal. returns the updated release, or nil and nil if there is none
+	UpdateRolloutPercentage(productName string, buildId int, percentage int) (*NewReleaseEvent, error)
+	//mark a release as withdrawn in place, like UpdateRolloutPercentage, so that it can't undo a rollout change or
+	//promotion made at the same time. returns the updated release, or nil and nil if there is none
+	MarkWithdrawn(productName string, buildId int, reason string, withdrawnAt string) (*NewReleaseEvent, error)
+}
+
+/**
+SemverIndexedStore is implemented by the stores that can list the releases on a branch in semver order without
+reading the whole branch, using the SemverKey that is saved with every release that has a semver. DynamoStore does
+this with a secondary index. MemoryStore and BoltStore have everything to hand already, so FindRelease just sorts
+their releases in memory.
+*/
+type SemverIndexedStore interface {
+	//whether the index can be read yet. DynamoStore's index is added by a separate deploy, see SemverIndexEnabled.
+	HasSemverIndex() bool
+	//call fn for every release on the branch that has a semver and has not been withdrawn, highest semver first.
+	//releases with the same semver come out in no particular order. stops early if fn returns false.
+	EachReleaseBySemver(productName string, branch string, fn func(ev *NewReleaseEvent) bool) error
+}
+
+//returned by CreateRelease when a release with the same productName and buildId has already been logged
+var ErrReleaseExists = errors.New("a release with this productName and buildId already exists")
+
+/**
+sort a list of releases so that the highest buildId comes first
+*/
+func sortNewestFirst(releases []NewReleaseEvent) {
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].BuildId > releases[j].BuildId
+	})
+}
+
+/**
+the page tokens used by the local stores are just the last buildId that was returned
+*/
+func encodeBuildIdToken(buildId int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(buildId)))
+}
+
+func decodeBuildIdToken(token string) (int, error) {
+	raw, decodeErr := base64.RawURLEncoding.DecodeString(token)
+	if decodeErr != nil {
+		return 0, ErrInvalidPageToken
+	}
+	buildId, parseErr := strconv.Atoi(string(raw))
+	if parseErr != nil {
+		return 0, ErrInvalidPageToken
+	}
+	return buildId, nil
+}
+
+/**
+returns true if the release passes the branch and timestamp filters of the query
+*/
+func (q *ReleaseQuery) Matches(ev *NewReleaseEvent) bool {
+	if q.Branch != "" && ev.Branch != q.Branch {
+		return false
+	}
+	if q.Since != "" && ev.Timestamp < q.Since {
+		return false
+	}
+	if q.Until != "" && ev.Timestamp > q.Until {
+		return false
+	}
+	return true
+}
+
+/**
+build a ReleasePage from every release of a product. This is used by the stores that can't do filtering or
+pagination themselves.
+arguments:
+    - releases: all the releases of the product, newest first
+    - query: the ReleaseQuery to apply
+*/
+func pageOfReleases(releases []NewReleaseEvent, query *ReleaseQuery) (*ReleasePage, error) {
+	var lastBuildId int
+	if query.PageToken != "" {
+		var tokenErr error
+		lastBuildId, tokenErr = decodeBuildIdToken(query.PageToken)
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+	}
+
+	page := ReleasePage{Releases: make([]NewReleaseEvent, 0, query.PageSize)}
+	for _, ev := range releases {
+		if query.PageToken != "" && ev.BuildId >= lastBuildId {
+			continue
+		}
+		if !query.Matches(&ev) {
+			continue
+		}
+		if len(page.Releases) == query.PageSize {
+			page.NextPageToken = encodeBuildIdToken(page.Releases[len(page.Releases)-1].BuildId)
+			break
+		}
+		page.Releases = append(page.Releases, ev)
+	}
+	return &page, nil
+}
+
+/**
+find the newest release on a branch that has not been withdrawn from every release of a product, newest first
+*/
+func mostRecentOf(releases []NewReleaseEvent, branch string) *NewReleaseEvent {
+	for _, ev := range releases {
+		if ev.Branch == branch && !ev.Withdrawn {
+			result := ev
+			return &result
+		}
+	}
+	return nil
+}
+
+/**
+call fn for every release matching the query, newest buildId first, following page tokens as needed.
+If the query is for a branch then builds that were promoted to it are included, see Promote.
+stops early if fn returns false.
+*/
+func EachRelease(store ReleaseStore, query ReleaseQuery, fn func(ev *NewReleaseEvent) bool) error {
+	if query.PageSize == 0 {
+		query.PageSize = MaxPageSize
+	}
+
+	promoted, promotionsErr := promotionsMatching(store, &query)
+	if promotionsErr != nil {
+		return promotionsErr
+	}
+
+	for {
+		page, listErr := store.ListReleases(&query)
+		if listErr != nil {
+			return listErr
+		}
+		for i := range page.Releases {
+			//slot in the promoted builds that are newer than this one
+			for len(promoted) > 0 && promoted[0].BuildId > page.Releases[i].BuildId {
+				if !fn(&promoted[0]) {
+					return nil
+				}
+				promoted = promoted[1:]
+			}
+			if !fn(&page.Releases[i]) {
+				return nil
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		query.PageToken = page.NextPageToken
+	}
+
+	for i := range promoted {
+		if !fn(&promoted[i]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+/**
+find the newest release of productName on branch that accept returns true for. Withdrawn releases are skipped.
+arguments:
+    - store: the ReleaseStore to search
+    - productName: product name to filter on
+    - branch: branch to filter on
+    - orderBy: OrderByBuildId or OrderBySemver (see CompareReleases). Ordering by semver uses the semver index of
+      stores that have one, see SemverIndexedStore, and otherwise has to read the whole branch history.
+    - accept: optional function to narrow down the releases that are considered, nil accepts everything
+returns nil and nil if nothing on the branch is acceptable
+*/
+func FindRelease(store ReleaseStore, productName string, branch string, orderBy string, accept func(ev *NewReleaseEvent) bool) (*NewReleaseEvent, error) {
+	if indexed, isIndexed := store.(SemverIndexedStore); isIndexed && indexed.HasSemverIndex() && orderBy == OrderBySemver {
+		return findBySemverIndex(store, indexed, productName, branch, accept)
+	}
+
+	var newest *NewReleaseEvent
+	err := EachRelease(store, ReleaseQuery{ProductName: productName, Branch: branch}, func(ev *NewReleaseEvent) bool {
+		if ev.Withdrawn || (accept != nil && !accept(ev)) {
+			return true
+		}
+		if newest == nil || CompareReleases(ev, newest) > 0 {
+			found := *ev
+			newest = &found
+		}
+		//releases come out in buildId order, so the first acceptable one is the answer unless we want semver order
+		return orderBy == OrderBySemver
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newest, nil
+}
+
+/**
+the SemverKey of a release, worked out from its semver, or "" if it hasn't got one
+*/
+func semverKeyOf(ev *NewReleaseEvent) string {
+	version, semverErr := ev.ParsedSemver()
+	if version == nil || semverErr != nil {
+		return ""
+	}
+	return version.SortKey()
+}
+
+/**
+FindRelease in semver order for a store with a semver index. The index is read from the highest semver down until
+an acceptable release is found, carrying on through any other releases with the same semver so that buildId can
+break the tie. The few builds promoted to the branch are compared in memory. Releases without a semver are older than
+any with one (see CompareReleases) and aren't in the index, so buildId order is only needed if there is no acceptable
+release with a semver.
+*/
+func findBySemverIndex(store ReleaseStore, indexed SemverIndexedStore, productName string, branch string, accept func(ev *NewReleaseEvent) bool) (*NewReleaseEvent, error) {
+	var newest *NewReleaseEvent
+	var newestKey string
+	err := indexed.EachReleaseBySemver(productName, branch, func(ev *NewReleaseEvent) bool {
+		key := semverKeyOf(ev)
+		if newest != nil && key != newestKey {
+			return false
+		}
+		if ev.Withdrawn || (accept != nil && !accept(ev)) {
+			return true
+		}
+		if newest == nil || CompareReleases(ev, newest) > 0 {
+			found := *ev
+			newest = &found
+			newestKey = key
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	promoted, promotionsErr := promotionsMatching(store, &ReleaseQuery{ProductName: productName, Branch: branch})
+	if promotionsErr != nil {
+		return nil, promotionsErr
+	}
+	for i := range promoted {
+		if promoted[i].Withdrawn || (accept != nil && !accept(&promoted[i])) {
+			continue
+		}
+		if newest == nil || CompareReleases(&promoted[i], newest) > 0 {
+			newest = &promoted[i]
+		}
+	}
+
+	if newest != nil && semverKeyOf(newest) != "" {
+		return newest, nil
+	}
+	return FindRelease(store, productName, branch, OrderByBuildId, accept)
+}
+
+/**
+find the newest release of productName on branch by semver precedence rather than buildId, see FindRelease.
+returns nil and nil if there are no releases on the branch
+*/
+func MostRecentReleaseBySemver(store ReleaseStore, productName string, branch string) (*NewReleaseEvent, error) {
+	return FindRelease(store, productName, branch, OrderBySemver, nil)
+}
+
+/**
+mark a release as withdrawn, so that lookups skip it and fall back to the next newest release on its branch.
+Copies of it that were promoted to other branches are withdrawn too. Only the withdrawal fields are written, so a
+rollout change or promotion made at the same time is kept.
+The record is kept so that it still shows up in the release history.
+returns the updated release, or nil and nil if there is no such release
+*/
+func WithdrawRelease(store ReleaseStore, productName string, buildId int, reason string) (*NewReleaseEvent, error) {
+	release, updateErr := store.MarkWithdrawn(productName, buildId, reason, time.Now().UTC().Format(time.RFC3339))
+	if release == nil || updateErr != nil {
+		return nil, updateErr
+	}
+	//PromotedTo is read back from the update, so a promotion that was recorded before it is always seen; Promote
+	//withdraws the copy itself if it records the promotion afterwards
+	if promotionsErr := withdrawPromotions(store, release); promotionsErr != nil {
+		return nil, promotionsErr
+	}
+	log.Printf("Withdrew %s build %d: %s", productName, buildId, reason)
+	return release, nil
+}
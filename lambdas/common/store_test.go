@@ -0,0 +1,428 @@
+package common
+
+import (
+	"github.com/davecgh/go-spew/spew"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+/**
+load some test data into the given store
+*/
+func populateStore(t *testing.T, store ReleaseStore) {
+	testData := []NewReleaseEvent{
+		{Event: "test", BuildId: 24, Branch: "master", DownloadUrl: "https://some/url/24", ProductName: "test product", Timestamp: "2019-11-01T10:00:00Z"},
+		{Event: "test", BuildId: 25, Branch: "somebranch", DownloadUrl: "https://some/url/25", ProductName: "test product", Timestamp: "2019-11-02T10:00:00Z"},
+		{Event: "test", BuildId: 26, Branch: "master", DownloadUrl: "https://some/url/26", ProductName: "test product", Timestamp: "2019-11-03T10:00:00Z"},
+		{Event: "test", BuildId: 27, Branch: "somebranch", DownloadUrl: "https://some/url/27", ProductName: "test product", Timestamp: "2019-11-04T10:00:00Z"},
+		{Event: "test", BuildId: 100, Branch: "master", DownloadUrl: "https://some/url/100", ProductName: "other product", Timestamp: "2019-11-05T10:00:00Z"},
+	}
+
+	for _, ev := range testData {
+		entry := ev
+		if err := store.LogRelease(&entry); err != nil {
+			t.Fatalf("could not log test data: %s", err)
+		}
+	}
+}
+
+/**
+tests that every ReleaseStore implementation should pass
+*/
+func testReleaseStore(t *testing.T, store ReleaseStore) {
+	populateStore(t, store)
+
+	latest, err := store.MostRecentRelease("test product", "master")
+	if err != nil {
+		t.Errorf("MostRecentRelease should have succeeded but got %s", err)
+	} else if latest == nil || latest.BuildId != 26 {
+		t.Errorf("MostRecentRelease returned the wrong record: %s", spew.Sprint(latest))
+	}
+
+	missing, err := store.MostRecentRelease("test product", "nobranch")
+	if err != nil || missing != nil {
+		t.Errorf("MostRecentRelease for an unknown branch should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	specific, err := store.GetRelease("test product", 25)
+	if err != nil {
+		t.Errorf("GetRelease should have succeeded but got %s", err)
+	} else if specific == nil || specific.DownloadUrl != "https://some/url/25" {
+		t.Errorf("GetRelease returned the wrong record: %s", spew.Sprint(specific))
+	}
+
+	firstPage, err := store.ListReleases(&ReleaseQuery{ProductName: "test product", PageSize: 3})
+	if err != nil {
+		t.Fatalf("ListReleases should have succeeded but got %s", err)
+	}
+	if len(firstPage.Releases) != 3 || firstPage.Releases[0].BuildId != 27 || firstPage.Releases[2].BuildId != 25 {
+		t.Errorf("ListReleases returned the wrong first page: %s", spew.Sprint(firstPage.Releases))
+	}
+	if firstPage.NextPageToken == "" {
+		t.Fatalf("ListReleases first page should have had a next page token")
+	}
+
+	secondPage, err := store.ListReleases(&ReleaseQuery{ProductName: "test product", PageSize: 3, PageToken: firstPage.NextPageToken})
+	if err != nil {
+		t.Fatalf("ListReleases second page should have succeeded but got %s", err)
+	}
+	if len(secondPage.Releases) != 1 || secondPage.Releases[0].BuildId != 24 || secondPage.NextPageToken != "" {
+		t.Errorf("ListReleases returned the wrong second page: %s", spew.Sprint(secondPage))
+	}
+
+	filtered, err := store.ListReleases(&ReleaseQuery{ProductName: "test product", Branch: "master", Since: "2019-11-02T00:00:00Z", PageSize: 10})
+	if err != nil {
+		t.Fatalf("ListReleases with filters should have succeeded but got %s", err)
+	}
+	if len(filtered.Releases) != 1 || filtered.Releases[0].BuildId != 26 {
+		t.Errorf("ListReleases with filters returned the wrong records: %s", spew.Sprint(filtered.Releases))
+	}
+
+	_, tokenErr := store.ListReleases(&ReleaseQuery{ProductName: "test product", PageSize: 3, PageToken: "not a real token!"})
+	if tokenErr != ErrInvalidPageToken {
+		t.Errorf("invalid page token should have returned ErrInvalidPageToken but got %s", tokenErr)
+	}
+
+	withdrawn, err := WithdrawRelease(store, "test product", 26, "crashes on startup")
+	if err != nil {
+		t.Fatalf("WithdrawRelease should have succeeded but got %s", err)
+	}
+	if withdrawn == nil || !withdrawn.Withdrawn || withdrawn.WithdrawnReason != "crashes on startup" || withdrawn.WithdrawnAt == "" {
+		t.Errorf("WithdrawRelease returned the wrong record: %s", spew.Sprint(withdrawn))
+	}
+	afterWithdraw, err := store.MostRecentRelease("test product", "master")
+	if err != nil || afterWithdraw == nil || afterWithdraw.BuildId != 24 {
+		t.Errorf("MostRecentRelease should have skipped the withdrawn release but got %s, %s", spew.Sprint(afterWithdraw), err)
+	}
+	found, err := FindRelease(store, "test product", "master", OrderBySemver, nil)
+	if err != nil || found == nil || found.BuildId != 24 {
+		t.Errorf("FindRelease should have skipped the withdrawn release but got %s, %s", spew.Sprint(found), err)
+	}
+	history, err := store.ListReleases(&ReleaseQuery{ProductName: "test product", Branch: "master", PageSize: 10})
+	if err != nil || len(history.Releases) != 2 || !history.Releases[0].Withdrawn {
+		t.Errorf("ListReleases should still have shown the withdrawn release but got %s, %s", spew.Sprint(history), err)
+	}
+	missingWithdraw, err := WithdrawRelease(store, "test product", 99, "not there")
+	if err != nil || missingWithdraw != nil {
+		t.Errorf("WithdrawRelease of a missing release should have returned nil, nil but got %s, %s", spew.Sprint(missingWithdraw), err)
+	}
+
+	if err := store.DeleteRelease("test product", 26); err != nil {
+		t.Errorf("DeleteRelease should have succeeded but got %s", err)
+	}
+	afterDelete, err := store.MostRecentRelease("test product", "master")
+	if err != nil || afterDelete == nil || afterDelete.BuildId != 24 {
+		t.Errorf("MostRecentRelease after delete returned the wrong record: %s, %s", spew.Sprint(afterDelete), err)
+	}
+
+	if err := store.DeleteRelease("no product", 1); err != nil {
+		t.Errorf("DeleteRelease of a missing record should have succeeded but got %s", err)
+	}
+
+	created := NewReleaseEvent{Event: "test", BuildId: 30, Branch: "master", DownloadUrl: "https://some/url/30", ProductName: "test product"}
+	if err := store.CreateRelease(&created); err != nil {
+		t.Errorf("CreateRelease of a new buildId should have succeeded but got %s", err)
+	}
+	duplicate := created
+	duplicate.DownloadUrl = "https://some/other/url"
+	if err := store.CreateRelease(&duplicate); err != ErrReleaseExists {
+		t.Errorf("CreateRelease of an existing buildId should have returned ErrReleaseExists but got %s", err)
+	}
+	afterCreate, err := store.GetRelease("test product", 30)
+	if err != nil || afterCreate == nil || afterCreate.DownloadUrl != "https://some/url/30" {
+		t.Errorf("CreateRelease should not have overwritten the existing record but got %s, %s", spew.Sprint(afterCreate), err)
+	}
+
+	rolledOut, err := store.UpdateRolloutPercentage("test product", 30, 40)
+	if err != nil || rolledOut == nil || rolledOut.RolloutPercentage == nil || *rolledOut.RolloutPercentage != 40 || rolledOut.DownloadUrl != "https://some/url/30" {
+		t.Errorf("UpdateRolloutPercentage returned the wrong record: %s, %s", spew.Sprint(rolledOut), err)
+	}
+	afterRollout, _ := store.GetRelease("test product", 30)
+	if afterRollout == nil || afterRollout.RolloutPercentage == nil || *afterRollout.RolloutPercentage != 40 {
+		t.Errorf("UpdateRolloutPercentage did not save the percentage: %s", spew.Sprint(afterRollout))
+	}
+	missingRollout, err := store.UpdateRolloutPercentage("test product", 99, 40)
+	if err != nil || missingRollout != nil {
+		t.Errorf("UpdateRolloutPercentage of a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missingRollout), err)
+	}
+
+	markedWithdrawn, err := store.MarkWithdrawn("test product", 30, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || markedWithdrawn == nil || !markedWithdrawn.Withdrawn || markedWithdrawn.WithdrawnReason != "bad build" || markedWithdrawn.WithdrawnAt != "2019-11-04T10:00:00Z" {
+		t.Errorf("MarkWithdrawn returned the wrong record: %s, %s", spew.Sprint(markedWithdrawn), err)
+	}
+	afterMark, _ := store.GetRelease("test product", 30)
+	if afterMark == nil || !afterMark.Withdrawn || afterMark.RolloutPercentage == nil || *afterMark.RolloutPercentage != 40 {
+		t.Errorf("MarkWithdrawn should have saved the withdrawal and kept the rollout: %s", spew.Sprint(afterMark))
+	}
+	missingMark, err := store.MarkWithdrawn("test product", 99, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || missingMark != nil {
+		t.Errorf("MarkWithdrawn of a missing record should have returned nil, nil but got %s, %s", spew.Sprint(missingMark), err)
+	}
+}
+
+/**
+tests that every SettingsStore implementation should pass
+*/
+func testSettingsStore(t *testing.T, store SettingsStore) {
+	missing, err := store.GetBranchSettings("test product", "master")
+	if err != nil || missing != nil {
+		t.Errorf("GetBranchSettings before any were saved should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	if err := store.PutBranchSettings(&BranchSettings{ProductName: "test product", Branch: "master", MinimumBuildId: 20}); err != nil {
+		t.Fatalf("PutBranchSettings should have succeeded but got %s", err)
+	}
+	if err := store.PutBranchSettings(&BranchSettings{ProductName: "test product", Branch: "master", MinimumBuildId: 22}); err != nil {
+		t.Fatalf("PutBranchSettings should have replaced the settings but got %s", err)
+	}
+
+	saved, err := store.GetBranchSettings("test product", "master")
+	if err != nil || saved == nil || saved.MinimumBuildId != 22 {
+		t.Errorf("GetBranchSettings returned the wrong settings: %s, %s", spew.Sprint(saved), err)
+	}
+
+	otherBranch, err := store.GetBranchSettings("test product", "somebranch")
+	if err != nil || otherBranch != nil {
+		t.Errorf("GetBranchSettings for another branch should have returned nil, nil but got %s, %s", spew.Sprint(otherBranch), err)
+	}
+}
+
+/**
+tests that every ChannelStore implementation should pass
+*/
+func testChannelStore(t *testing.T, store ChannelStore) {
+	missing, err := store.GetChannel("test product", "beta")
+	if err != nil || missing != nil {
+		t.Errorf("GetChannel before any were saved should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	for _, channel := range []Channel{
+		{ProductName: "test product", Name: "stable", Branches: []string{"master"}},
+		{ProductName: "test product", Name: "beta", Branches: []string{"develop"}, Fallback: "stable"},
+		{ProductName: "other product", Name: "stable", Branches: []string{"main"}},
+	} {
+		entry := channel
+		if err := store.PutChannel(&entry); err != nil {
+			t.Fatalf("PutChannel should have succeeded but got %s", err)
+		}
+	}
+
+	beta, err := store.GetChannel("test product", "beta")
+	if err != nil || beta == nil || beta.Fallback != "stable" || len(beta.Branches) != 1 || beta.Branches[0] != "develop" {
+		t.Errorf("GetChannel returned the wrong channel: %s, %s", spew.Sprint(beta), err)
+	}
+
+	channels, err := store.ListChannels("test product")
+	if err != nil || len(channels) != 2 || channels[0].Name != "beta" || channels[1].Name != "stable" {
+		t.Errorf("ListChannels returned the wrong channels: %s, %s", spew.Sprint(channels), err)
+	}
+
+	if err := store.DeleteChannel("test product", "beta"); err != nil {
+		t.Errorf("DeleteChannel should have succeeded but got %s", err)
+	}
+	if afterDelete, _ := store.ListChannels("test product"); len(afterDelete) != 1 {
+		t.Errorf("ListChannels after delete returned the wrong channels: %s", spew.Sprint(afterDelete))
+	}
+	if err := store.DeleteChannel("no product", "beta"); err != nil {
+		t.Errorf("DeleteChannel of a missing channel should have succeeded but got %s", err)
+	}
+
+	empty, err := store.ListChannels("no product")
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("ListChannels for an unknown product should have returned an empty list but got %s, %s", spew.Sprint(empty), err)
+	}
+}
+
+/**
+tests that every PromotionStore implementation should pass
+*/
+func testPromotionStore(t *testing.T, store PromotionStore) {
+	missing, err := store.GetPromotion("test product", "master", 10)
+	if err != nil || missing != nil {
+		t.Errorf("GetPromotion before any were saved should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	for _, buildId := range []int{10, 12, 11} {
+		promoted := NewReleaseEvent{BuildId: buildId, Branch: "master", DownloadUrl: "https://some/url", ProductName: "test product", PromotedFrom: "release"}
+		if err := store.PutPromotion(&promoted); err != nil {
+			t.Fatalf("PutPromotion should have succeeded but got %s", err)
+		}
+	}
+	other := NewReleaseEvent{BuildId: 13, Branch: "develop", DownloadUrl: "https://some/url", ProductName: "test product", PromotedFrom: "release"}
+	store.PutPromotion(&other)
+
+	got, err := store.GetPromotion("test product", "master", 11)
+	if err != nil || got == nil || got.PromotedFrom != "release" {
+		t.Errorf("GetPromotion returned the wrong promotion: %s, %s", spew.Sprint(got), err)
+	}
+
+	promoted, err := store.ListPromotions("test product", "master")
+	if err != nil || len(promoted) != 3 || promoted[0].BuildId != 12 || promoted[2].BuildId != 10 {
+		t.Errorf("ListPromotions returned the wrong promotions: %s, %s", spew.Sprint(promoted), err)
+	}
+
+	empty, err := store.ListPromotions("test product", "nobranch")
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("ListPromotions for a branch without any should have returned an empty list but got %s, %s", spew.Sprint(empty), err)
+	}
+
+	rolledOut, err := store.UpdatePromotionRolloutPercentage("test product", "master", 11, 20)
+	if err != nil || rolledOut == nil || rolledOut.RolloutPercentage == nil || *rolledOut.RolloutPercentage != 20 || rolledOut.PromotedFrom != "release" {
+		t.Errorf("UpdatePromotionRolloutPercentage returned the wrong promotion: %s, %s", spew.Sprint(rolledOut), err)
+	}
+	missingRollout, err := store.UpdatePromotionRolloutPercentage("test product", "develop", 11, 20)
+	if err != nil || missingRollout != nil {
+		t.Errorf("UpdatePromotionRolloutPercentage of a missing promotion should have returned nil, nil but got %s, %s", spew.Sprint(missingRollout), err)
+	}
+
+	withdrawn, err := store.MarkPromotionWithdrawn("test product", "master", 11, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || withdrawn == nil || !withdrawn.Withdrawn || withdrawn.RolloutPercentage == nil || *withdrawn.RolloutPercentage != 20 {
+		t.Errorf("MarkPromotionWithdrawn should have withdrawn the promotion and kept its rollout: %s, %s", spew.Sprint(withdrawn), err)
+	}
+	missingWithdraw, err := store.MarkPromotionWithdrawn("test product", "develop", 11, "bad build", "2019-11-04T10:00:00Z")
+	if err != nil || missingWithdraw != nil {
+		t.Errorf("MarkPromotionWithdrawn of a missing promotion should have returned nil, nil but got %s, %s", spew.Sprint(missingWithdraw), err)
+	}
+
+	releases, isReleaseStore := store.(ReleaseStore)
+	if !isReleaseStore {
+		return
+	}
+	releases.LogRelease(&NewReleaseEvent{BuildId: 11, Branch: "release", DownloadUrl: "https://some/url", ProductName: "test product"})
+	for i := 0; i < 2; i++ {
+		original, err := store.AddPromotedTo("test product", 11, "master")
+		if err != nil || original == nil || len(original.PromotedTo) != 1 || original.PromotedTo[0] != "master" {
+			t.Errorf("AddPromotedTo should have listed the branch once but got %s, %s", spew.Sprint(original), err)
+		}
+	}
+	original, _ := store.AddPromotedTo("test product", 11, "develop")
+	if original == nil || len(original.PromotedTo) != 2 || original.PromotedTo[1] != "develop" {
+		t.Errorf("AddPromotedTo should have added the second branch but got %s", spew.Sprint(original))
+	}
+	missingOriginal, err := store.AddPromotedTo("test product", 99, "master")
+	if err != nil || missingOriginal != nil {
+		t.Errorf("AddPromotedTo of a missing release should have returned nil, nil but got %s, %s", spew.Sprint(missingOriginal), err)
+	}
+}
+
+/**
+tests that every ProductStore implementation should pass
+*/
+func testProductStore(t *testing.T, store ProductStore) {
+	missing, err := store.GetProduct("test product")
+	if err != nil || missing != nil {
+		t.Errorf("GetProduct before any were saved should have returned nil, nil but got %s, %s", spew.Sprint(missing), err)
+	}
+
+	if err := store.PutProduct(&Product{ProductName: "test product", DefaultBranch: "main"}); err != nil {
+		t.Fatalf("PutProduct should have succeeded but got %s", err)
+	}
+	store.PutProduct(&Product{ProductName: "other product", DefaultBranch: "trunk"})
+
+	product, err := store.GetProduct("test product")
+	if err != nil || product == nil || product.DefaultBranch != "main" {
+		t.Errorf("GetProduct returned the wrong product: %s, %s", spew.Sprint(product), err)
+	}
+
+	products, err := store.ListProducts()
+	if err != nil || len(products) != 2 || products[0].ProductName != "other product" || products[1].ProductName != "test product" {
+		t.Errorf("ListProducts returned the wrong products: %s, %s", spew.Sprint(products), err)
+	}
+}
+
+/**
+tests that every DownloadStore implementation should pass
+*/
+func testDownloadStore(t *testing.T, store DownloadStore) {
+	none, err := store.DownloadCounts("test product", 12)
+	if err != nil || none == nil || len(none) != 0 {
+		t.Errorf("DownloadCounts before any downloads should have returned an empty list but got %s, %s", spew.Sprint(none), err)
+	}
+
+	for _, platform := range []string{"windows", "macos/arm64", "windows", AnyPlatform} {
+		if err := store.CountDownload("test product", 12, platform); err != nil {
+			t.Fatalf("CountDownload should have succeeded but got %s", err)
+		}
+	}
+	store.CountDownload("test product", 123, "windows")
+	store.CountDownload("other product", 12, "windows")
+
+	counts, err := store.DownloadCounts("test product", 12)
+	if err != nil || len(counts) != 3 {
+		t.Fatalf("DownloadCounts returned the wrong counts: %s, %s", spew.Sprint(counts), err)
+	}
+	if counts[0].Platform != AnyPlatform || counts[0].Downloads != 1 || counts[1].Platform != "macos/arm64" || counts[2].Platform != "windows" || counts[2].Downloads != 2 {
+		t.Errorf("DownloadCounts returned the wrong counts: %s", spew.Sprint(counts))
+	}
+	if counts[2].ProductName != "test product" || counts[2].BuildId != 12 {
+		t.Errorf("DownloadCounts should have filled in the release: %s", spew.Sprint(counts[2]))
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testReleaseStore(t, NewMemoryStore())
+	testSettingsStore(t, NewMemoryStore())
+	testChannelStore(t, NewMemoryStore())
+	testPromotionStore(t, NewMemoryStore())
+	testProductStore(t, NewMemoryStore())
+	testDownloadStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	tempDir, dirErr := ioutil.TempDir("", "boltstore")
+	if dirErr != nil {
+		t.Fatalf("could not create temporary directory: %s", dirErr)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, openErr := NewBoltStore(path.Join(tempDir, "test.db"))
+	if openErr != nil {
+		t.Fatalf("could not open bolt store: %s", openErr)
+	}
+	defer store.Close()
+
+	testReleaseStore(t, store)
+	testSettingsStore(t, store)
+	testChannelStore(t, store)
+	testPromotionStore(t, store)
+	testProductStore(t, store)
+	testDownloadStore(t, store)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	store := NewMemoryStore()
+	populateStore(t, store)
+	mandatory := NewReleaseEvent{Event: "test", BuildId: 22, Branch: "master", DownloadUrl: "https://some/url/22", ProductName: "test product", Mandatory: true}
+	store.LogRelease(&mandatory)
+	latest, _ := store.MostRecentRelease("test product", "master")
+
+	tests := []struct {
+		currentBuildId int
+		currentSemver  string
+		settings       *BranchSettings
+		accept         func(ev *NewReleaseEvent) bool
+		expected       string
+	}{
+		{26, "", nil, nil, UpdateNone},
+		{30, "", nil, nil, UpdateNone},
+		{24, "", nil, nil, UpdateOptional},
+		{24, "", &BranchSettings{MinimumBuildId: 26}, nil, UpdateRequired},
+		{24, "", &BranchSettings{MinimumBuildId: 24}, nil, UpdateOptional},
+		{20, "", nil, nil, UpdateRequired},
+		{20, "", nil, func(ev *NewReleaseEvent) bool { return ev.BuildId != 22 }, UpdateOptional},
+	}
+	for _, test := range tests {
+		status, err := UpdateStatus(store, latest, test.settings, test.currentBuildId, test.currentSemver, test.accept)
+		if err != nil {
+			t.Errorf("UpdateStatus for build %d should have succeeded but got %s", test.currentBuildId, err)
+		} else if status != test.expected {
+			t.Errorf("UpdateStatus for build %d with %s should have been %s but got %s", test.currentBuildId, spew.Sprint(test.settings), test.expected, status)
+		}
+	}
+
+	if status, _ := UpdateStatus(store, nil, nil, 1, "", nil); status != UpdateNone {
+		t.Errorf("UpdateStatus with no release should have been %s but got %s", UpdateNone, status)
+	}
+}
@@ -0,0 +1,111 @@
+package common
+
+const StatusUpToDate = "up-to-date"
+const StatusUpdateAvailable = "update-available"
+const StatusAheadOfServer = "ahead-of-server"
+
+/**
+compare a release to the build that a client is running. If the client gave its semver and the release has one then
+semver precedence is used (see CompareReleases), otherwise buildId.
+returns -1 if the release is older than the client, 1 if it is newer and 0 if they are the same
+*/
+func CompareToClient(release *NewReleaseEvent, currentBuildId int, currentSemver string) int {
+	if currentSemver == "" || release.Semver == "" {
+		return compareInts(release.BuildId, currentBuildId)
+	}
+	return CompareReleases(release, &NewReleaseEvent{BuildId: currentBuildId, Semver: currentSemver})
+}
+
+/**
+returns StatusUpdateAvailable if target is newer than the client, StatusAheadOfServer if it is older (e.g. a developer
+build) and StatusUpToDate if the client is running it
+*/
+func ClientStatus(target *NewReleaseEvent, currentBuildId int, currentSemver string) string {
+	switch CompareToClient(target, currentBuildId, currentSemver) {
+	case 1:
+		return StatusUpdateAvailable
+	case -1:
+		return StatusAheadOfServer
+	default:
+		return StatusUpToDate
+	}
+}
+
+/**
+call fn for every release of productName on branch that is newer than the client (see CompareToClient) and hasn't been
+withdrawn. stops early if fn returns false.
+Releases come out newest buildId first, so without currentSemver the scan stops at the client's build. With it, a
+release with a lower buildId can still have a higher semver, so the releases that have one are read from the semver
+index down to the client's version (see SemverIndexedStore), the few promoted builds are checked in memory and the
+releases without a semver, which are compared on buildId, are read down to the client's build. Stores without a
+semver index have to read the whole branch.
+*/
+func eachNewerRelease(store ReleaseStore, productName string, branch string, currentBuildId int, currentSemver string, fn func(ev *NewReleaseEvent) bool) error {
+	isNewer := func(ev *NewReleaseEvent) bool {
+		return !ev.Withdrawn && CompareToClient(ev, currentBuildId, currentSemver) > 0
+	}
+	query := ReleaseQuery{ProductName: productName, Branch: branch}
+
+	indexed, isIndexed := store.(SemverIndexedStore)
+	if currentSemver == "" || !isIndexed || !indexed.HasSemverIndex() {
+		return EachRelease(store, query, func(ev *NewReleaseEvent) bool {
+			if currentSemver == "" && ev.BuildId <= currentBuildId {
+				return false
+			}
+			return !isNewer(ev) || fn(ev)
+		})
+	}
+
+	currentKey := semverKeyOf(&NewReleaseEvent{Semver: currentSemver})
+	stopped := false
+	err := indexed.EachReleaseBySemver(productName, branch, func(ev *NewReleaseEvent) bool {
+		//releases with the client's own semver can still be newer on buildId, so carry on through them
+		if semverKeyOf(ev) < currentKey {
+			return false
+		}
+		if isNewer(ev) && !fn(ev) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if err != nil || stopped {
+		return err
+	}
+
+	promoted, promotionsErr := promotionsMatching(store, &query)
+	if promotionsErr != nil {
+		return promotionsErr
+	}
+	for i := range promoted {
+		if semverKeyOf(&promoted[i]) != "" && isNewer(&promoted[i]) && !fn(&promoted[i]) {
+			return nil
+		}
+	}
+
+	return EachRelease(store, query, func(ev *NewReleaseEvent) bool {
+		if ev.BuildId <= currentBuildId {
+			return false
+		}
+		return semverKeyOf(ev) != "" || !isNewer(ev) || fn(ev)
+	})
+}
+
+/**
+count the releases of productName on branch that are newer than the client, see CompareToClient. Withdrawn releases
+don't count.
+accept optionally narrows down the releases that are counted, e.g. to the client's platform; nil counts everything
+*/
+func BuildsBehind(store ReleaseStore, productName string, branch string, currentBuildId int, currentSemver string, accept func(ev *NewReleaseEvent) bool) (int, error) {
+	count := 0
+	err := eachNewerRelease(store, productName, branch, currentBuildId, currentSemver, func(ev *NewReleaseEvent) bool {
+		if accept == nil || accept(ev) {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClientStatus(t *testing.T) {
+	tests := []struct {
+		release        NewReleaseEvent
+		currentBuildId int
+		currentSemver  string
+		expected       string
+	}{
+		{NewReleaseEvent{BuildId: 26}, 26, "", StatusUpToDate},
+		{NewReleaseEvent{BuildId: 26}, 24, "", StatusUpdateAvailable},
+		{NewReleaseEvent{BuildId: 26}, 30, "", StatusAheadOfServer},
+		{NewReleaseEvent{BuildId: 26, Semver: "2.0.0"}, 30, "", StatusAheadOfServer},
+		{NewReleaseEvent{BuildId: 26}, 30, "1.0.0", StatusAheadOfServer},
+		{NewReleaseEvent{BuildId: 26, Semver: "2.0.0"}, 30, "1.9.0", StatusUpdateAvailable},
+		{NewReleaseEvent{BuildId: 26, Semver: "2.0.0"}, 20, "2.0.0-rc.1", StatusUpdateAvailable},
+		{NewReleaseEvent{BuildId: 26, Semver: "2.0.0"}, 26, "2.0.0", StatusUpToDate},
+		{NewReleaseEvent{BuildId: 26, Semver: "2.0.0"}, 20, "2.1.0", StatusAheadOfServer},
+	}
+	for _, test := range tests {
+		status := ClientStatus(&test.release, test.currentBuildId, test.currentSemver)
+		if status != test.expected {
+			t.Errorf("ClientStatus of build %d (%s) against release %d (%s) should have been %s but got %s",
+				test.currentBuildId, test.currentSemver, test.release.BuildId, test.release.Semver, test.expected, status)
+		}
+	}
+}
+
+func TestBuildsBehind(t *testing.T) {
+	store := NewMemoryStore()
+	populateStore(t, store)
+	store.LogRelease(&NewReleaseEvent{Event: "test", BuildId: 28, Branch: "master", DownloadUrl: "https://some/url/28", ProductName: "test product", Semver: "1.1.0"})
+	store.LogRelease(&NewReleaseEvent{Event: "test", BuildId: 29, Branch: "master", DownloadUrl: "https://some/url/29", ProductName: "test product", Semver: "1.0.1"})
+
+	tests := []struct {
+		currentBuildId int
+		currentSemver  string
+		accept         func(ev *NewReleaseEvent) bool
+		expected       int
+	}{
+		{24, "", nil, 3},
+		{26, "", nil, 2},
+		{29, "", nil, 0},
+		{24, "", func(ev *NewReleaseEvent) bool { return ev.BuildId != 28 }, 2},
+		{29, "1.0.1", nil, 1},
+		{28, "1.1.0", nil, 0},
+	}
+	for _, test := range tests {
+		behind, err := BuildsBehind(store, "test product", "master", test.currentBuildId, test.currentSemver, test.accept)
+		if err != nil {
+			t.Errorf("BuildsBehind for build %d should have succeeded but got %s", test.currentBuildId, err)
+		} else if behind != test.expected {
+			t.Errorf("BuildsBehind for build %d (%s) should have been %d but got %d", test.currentBuildId, test.currentSemver, test.expected, behind)
+		}
+	}
+}
+
+/**
+log the releases for the semver tests of BuildsBehind and UpdateStatus. 1.9.1 was a hotfix, built after 2.0.0.
+*/
+func populateSemverBranch(t *testing.T, store ReleaseStore) {
+	testData := []NewReleaseEvent{
+		{BuildId: 29, Semver: "1.9.5", Mandatory: true},
+		{BuildId: 30, Semver: "2.0.0"},
+		{BuildId: 31, Semver: "1.9.1"},
+		{BuildId: 32, Semver: "3.0.0", Withdrawn: true},
+		{BuildId: 40},
+	}
+	for i := 1; i <= 10; i++ {
+		testData = append(testData, NewReleaseEvent{BuildId: i, Semver: fmt.Sprintf("1.0.%d", i)})
+	}
+
+	for _, ev := range testData {
+		entry := ev
+		entry.ProductName = "test product"
+		entry.Branch = "master"
+		if err := store.LogRelease(&entry); err != nil {
+			t.Fatalf("could not log test data: %s", err)
+		}
+	}
+	promotions := store.(PromotionStore)
+	if err := promotions.PutPromotion(&NewReleaseEvent{BuildId: 5, Branch: "master", ProductName: "test product", Semver: "1.9.9", PromotedFrom: "develop"}); err != nil {
+		t.Fatalf("could not log test promotion: %s", err)
+	}
+}
+
+func TestBuildsBehind_SemverIndex(t *testing.T) {
+	store := &semverIndexedMemoryStore{MemoryStore: NewMemoryStore()}
+	populateSemverBranch(t, store)
+
+	behind, err := BuildsBehind(store, "test product", "master", 31, "1.9.1", nil)
+	if err != nil || behind != 4 {
+		t.Errorf("BuildsBehind for 1.9.1 should have counted 1.9.5, 1.9.9, 2.0.0 and build 40 but got %d, %s", behind, err)
+	}
+	if store.read != 4 {
+		t.Errorf("BuildsBehind should have stopped reading the index after 1.9.1 but read %d releases", store.read)
+	}
+
+	unindexed, err := BuildsBehind(store.MemoryStore, "test product", "master", 31, "1.9.1", nil)
+	if err != nil || unindexed != behind {
+		t.Errorf("BuildsBehind without the semver index should have been %d too but got %d, %s", behind, unindexed, err)
+	}
+
+	latest, err := BuildsBehind(store, "test product", "master", 40, "2.0.0", nil)
+	if err != nil || latest != 0 {
+		t.Errorf("BuildsBehind for 2.0.0 should have been 0 but got %d, %s", latest, err)
+	}
+}
+
+func TestUpdateStatus_Semver(t *testing.T) {
+	store := &semverIndexedMemoryStore{MemoryStore: NewMemoryStore()}
+	populateSemverBranch(t, store)
+	latest, _ := MostRecentReleaseBySemver(store, "test product", "master")
+
+	tests := []struct {
+		currentBuildId int
+		currentSemver  string
+		accept         func(ev *NewReleaseEvent) bool
+		expected       string
+	}{
+		{31, "1.9.1", nil, UpdateRequired},
+		{31, "1.9.1", func(ev *NewReleaseEvent) bool { return ev.BuildId != 29 }, UpdateOptional},
+		{30, "2.0.0", nil, UpdateNone},
+		{31, "", nil, UpdateNone},
+	}
+	for _, test := range tests {
+		status, err := UpdateStatus(store, latest, nil, test.currentBuildId, test.currentSemver, test.accept)
+		if err != nil {
+			t.Errorf("UpdateStatus for build %d (%s) should have succeeded but got %s", test.currentBuildId, test.currentSemver, err)
+		} else if status != test.expected {
+			t.Errorf("UpdateStatus for build %d (%s) should have been %s but got %s", test.currentBuildId, test.currentSemver, test.expected, status)
+		}
+	}
+}
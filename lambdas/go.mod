@@ -4,6 +4,8 @@ require (
 	github.com/aws/aws-lambda-go v1.13.2
 	github.com/aws/aws-sdk-go v1.25.21
 	github.com/davecgh/go-spew v1.1.0
+	github.com/yuin/goldmark v1.2.1
+	go.etcd.io/bbolt v1.3.5
 )
 
 go 1.13
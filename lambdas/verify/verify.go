@@ -0,0 +1,169 @@
+/**
+Package verify checks the Ed25519 signatures that the versions API puts on its lookup responses, so that a client can
+be sure a response came from the service and was not changed by a CDN or proxy on the way. The signature covers the
+request that the response answers and the time it was made as well as the body, so a genuine response can't be
+replayed as the answer to another request, or long after it was made, to hold a client back on an old release.
+It only depends on the standard library so that client applications can embed it.
+
+Usage:
+
+	publicKey, _ := verify.ParsePublicKey("{base64-encoded public key}")
+	response, _ := http.Get("https://versions.example.com/lookup?productName=myProduct&branch=master")
+	body, err := verify.HTTPResponse(publicKey, response, 5*time.Minute)
+	if err != nil {
+		//don't trust the body
+	}
+*/
+package verify
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//header carrying the base64-encoded detached signature of the response, see Payload
+const SignatureHeader = "X-Signature"
+
+//header carrying the request that the response was signed for, see CanonicalRequest
+const RequestHeader = "X-Signature-Request"
+
+//header carrying the time that the response was signed, in RFC3339 format
+const TimestampHeader = "X-Signature-Timestamp"
+
+//header carrying the KeyId of the key that made the signature, to help with key rotation
+const KeyIdHeader = "X-Signature-Key-Id"
+
+var ErrNoSignature = errors.New("response is not signed")
+var ErrBadSignature = errors.New("response signature does not match")
+var ErrWrongRequest = errors.New("response was signed for a different request")
+var ErrStaleSignature = errors.New("response signature is too old")
+
+//how far ahead of the client's clock a signature can be before it is rejected
+const clockSkew = time.Minute
+
+/**
+parse a base64-encoded Ed25519 public key
+*/
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	raw, decodeErr := base64.StdEncoding.DecodeString(encoded)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("public key is not valid base64: %s", decodeErr)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key should be %d bytes but is %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+/**
+a short identifier for a public key, the first 8 bytes of its SHA-256 in hex
+*/
+func KeyId(publicKey ed25519.PublicKey) string {
+	digest := sha256.Sum256(publicKey)
+	return hex.EncodeToString(digest[:8])
+}
+
+/**
+the canonical form of a request that is signed along with its response: the path, then a ? and the query string with
+its keys sorted if there is one
+*/
+func CanonicalRequest(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+/**
+the bytes that are signed for a response: the canonical request it answers, the time it was signed and the body,
+separated by newlines
+*/
+func Payload(request string, timestamp string, body []byte) []byte {
+	payload := make([]byte, 0, len(request)+len(timestamp)+len(body)+2)
+	payload = append(payload, request...)
+	payload = append(payload, '\n')
+	payload = append(payload, timestamp...)
+	payload = append(payload, '\n')
+	return append(payload, body...)
+}
+
+/**
+check that signedRequest, as sent in RequestHeader, is the request that was made. The service doesn't see a stage
+or base path that API Gateway strips off, so the signed path only has to be the end of the requested one.
+*/
+func RequestMatches(signedRequest string, requested *url.URL) bool {
+	signedPath := signedRequest
+	signedQuery := ""
+	if i := strings.Index(signedRequest, "?"); i >= 0 {
+		signedPath = signedRequest[:i]
+		signedQuery = signedRequest[i+1:]
+	}
+	if !strings.HasPrefix(signedPath, "/") || !strings.HasSuffix(requested.Path, signedPath) {
+		return false
+	}
+	return signedQuery == requested.Query().Encode()
+}
+
+/**
+check that timestamp, as sent in TimestampHeader, is no older than maxAge
+*/
+func Fresh(timestamp string, maxAge time.Duration) bool {
+	signedAt, parseErr := time.Parse(time.RFC3339, timestamp)
+	if parseErr != nil {
+		return false
+	}
+	age := time.Since(signedAt)
+	return age <= maxAge && age >= -clockSkew
+}
+
+/**
+check a base64-encoded detached signature of payload
+*/
+func Signature(publicKey ed25519.PublicKey, payload []byte, signature string) error {
+	if signature == "" {
+		return ErrNoSignature
+	}
+	raw, decodeErr := base64.StdEncoding.DecodeString(signature)
+	if decodeErr != nil || len(raw) != ed25519.SignatureSize {
+		return ErrBadSignature
+	}
+	if !ed25519.Verify(publicKey, payload, raw) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+/**
+read the body of an HTTP response and check it against the signature headers. The signature must be good, must have
+been made for the request that response.Request made and must be no older than maxAge.
+returns the body if it can be trusted, or nil and an error if not
+*/
+func HTTPResponse(publicKey ed25519.PublicKey, response *http.Response, maxAge time.Duration) ([]byte, error) {
+	defer response.Body.Close()
+	body, readErr := ioutil.ReadAll(response.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	signedRequest := response.Header.Get(RequestHeader)
+	timestamp := response.Header.Get(TimestampHeader)
+	if verifyErr := Signature(publicKey, Payload(signedRequest, timestamp, body), response.Header.Get(SignatureHeader)); verifyErr != nil {
+		return nil, verifyErr
+	}
+	if response.Request == nil || !RequestMatches(signedRequest, response.Request.URL) {
+		return nil, ErrWrongRequest
+	}
+	if !Fresh(timestamp, maxAge) {
+		return nil, ErrStaleSignature
+	}
+	return body, nil
+}
@@ -0,0 +1,126 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	body := []byte(`[{"buildId":12}]`)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, body))
+
+	if err := Signature(publicKey, body, signature); err != nil {
+		t.Errorf("good signature should have verified but got %s", err)
+	}
+	if err := Signature(publicKey, []byte(`[{"buildId":13}]`), signature); err != ErrBadSignature {
+		t.Errorf("tampered body should have returned ErrBadSignature but got %v", err)
+	}
+	if err := Signature(publicKey, body, ""); err != ErrNoSignature {
+		t.Errorf("missing signature should have returned ErrNoSignature but got %v", err)
+	}
+	if err := Signature(publicKey, body, "not base64!"); err != ErrBadSignature {
+		t.Errorf("malformed signature should have returned ErrBadSignature but got %v", err)
+	}
+
+	otherPublicKey, _, _ := ed25519.GenerateKey(nil)
+	if err := Signature(otherPublicKey, body, signature); err != ErrBadSignature {
+		t.Errorf("signature from another key should have returned ErrBadSignature but got %v", err)
+	}
+}
+
+/**
+make a response to requestUrl signed by privateKey, as the service would
+*/
+func signedResponse(privateKey ed25519.PrivateKey, requestUrl string, signedRequest string, signedAt time.Time, body string) *http.Response {
+	request, _ := http.NewRequest("GET", requestUrl, nil)
+	timestamp := signedAt.UTC().Format(time.RFC3339)
+	response := &http.Response{
+		Header:  http.Header{},
+		Body:    ioutil.NopCloser(strings.NewReader(body)),
+		Request: request,
+	}
+	response.Header.Set(SignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, Payload(signedRequest, timestamp, []byte(body)))))
+	response.Header.Set(RequestHeader, signedRequest)
+	response.Header.Set(TimestampHeader, timestamp)
+	return response
+}
+
+func TestHTTPResponse(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	body := `[{"buildId":12}]`
+	signedRequest := "/lookup?branch=master&productName=test+product"
+
+	response := signedResponse(privateKey, "https://versions.example.com/Prod/lookup?productName=test%20product&branch=master", signedRequest, time.Now(), body)
+	verified, err := HTTPResponse(publicKey, response, time.Minute)
+	if err != nil {
+		t.Fatalf("good response should have verified but got %s", err)
+	}
+	if string(verified) != body {
+		t.Errorf("verified body was wrong, got %s", string(verified))
+	}
+
+	tests := []struct {
+		name        string
+		response    *http.Response
+		expectedErr error
+	}{
+		{"another branch", signedResponse(privateKey, "https://versions.example.com/lookup?productName=test+product&branch=develop", signedRequest, time.Now(), body), ErrWrongRequest},
+		{"another endpoint", signedResponse(privateKey, "https://versions.example.com/update?productName=test+product&branch=master", signedRequest, time.Now(), body), ErrWrongRequest},
+		{"an old response", signedResponse(privateKey, "https://versions.example.com/lookup?productName=test+product&branch=master", signedRequest, time.Now().Add(-time.Hour), body), ErrStaleSignature},
+		{"a response from the future", signedResponse(privateKey, "https://versions.example.com/lookup?productName=test+product&branch=master", signedRequest, time.Now().Add(time.Hour), body), ErrStaleSignature},
+	}
+	for _, test := range tests {
+		if _, err := HTTPResponse(publicKey, test.response, time.Minute); err != test.expectedErr {
+			t.Errorf("%s should have returned %v but got %v", test.name, test.expectedErr, err)
+		}
+	}
+
+	//changing the signed request to match the one that was made must break the signature
+	swapped := signedResponse(privateKey, "https://versions.example.com/lookup?productName=test+product&branch=develop", signedRequest, time.Now(), body)
+	swapped.Header.Set(RequestHeader, "/lookup?branch=develop&productName=test+product")
+	if _, err := HTTPResponse(publicKey, swapped, time.Minute); err != ErrBadSignature {
+		t.Errorf("response with a changed request header should have returned ErrBadSignature but got %v", err)
+	}
+}
+
+func TestRequestMatches(t *testing.T) {
+	tests := []struct {
+		signedRequest string
+		requestUrl    string
+		expected      bool
+	}{
+		{"/lookup?branch=master&productName=p", "https://example.com/lookup?productName=p&branch=master", true},
+		{"/lookup?branch=master&productName=p", "https://example.com/Prod/lookup?branch=master&productName=p", true},
+		{"/lookup", "https://example.com/lookup", true},
+		{"/lookup", "https://example.com/lookup?branch=master", false},
+		{"/lookup?branch=master&productName=p", "https://example.com/lookup?branch=master&productName=p&os=linux", false},
+		{"/lookup?branch=master", "https://example.com/other-lookup?branch=master", false},
+		{"?branch=master", "https://example.com/lookup?branch=master", false},
+	}
+	for _, test := range tests {
+		requested, _ := url.Parse(test.requestUrl)
+		if RequestMatches(test.signedRequest, requested) != test.expected {
+			t.Errorf("RequestMatches(%s, %s) should have returned %t", test.signedRequest, test.requestUrl, test.expected)
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+	parsed, err := ParsePublicKey(base64.StdEncoding.EncodeToString(publicKey))
+	if err != nil || !bytes.Equal(parsed, publicKey) {
+		t.Errorf("public key did not parse back to itself: %s", err)
+	}
+
+	if _, err := ParsePublicKey(base64.StdEncoding.EncodeToString([]byte("too short"))); err == nil {
+		t.Errorf("short public key should not have parsed")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"log"
+	"os"
+)
+
+/**
+the composite key for the branch index. This must match common.ProductBranchKey in the lambdas
+*/
+func ProductBranchKey(productName string, branch string) string {
+	return productName + "#" + branch
+}
+
+/**
+returns the string value of an attribute of a scanned item, and false if the item doesn't have it. Legacy records
+can be missing attributes entirely, so the map entry has to be checked before reading it.
+*/
+func stringAttribute(item map[string]*dynamodb.AttributeValue, name string) (string, bool) {
+	value, haveValue := item[name]
+	if !haveValue || value == nil || value.S == nil {
+		return "", false
+	}
+	return *value.S, true
+}
+
+/**
+returns the buildId of a scanned item for logging, or "(unknown)" if it doesn't have one
+*/
+func buildIdOf(item map[string]*dynamodb.AttributeValue) string {
+	value, haveValue := item["buildId"]
+	if !haveValue || value == nil || value.N == nil {
+		return "(unknown)"
+	}
+	return *value.N
+}
+
+/**
+set the productBranch attribute on a single existing record
+returns false if the record was skipped because it has no productName or branch
+*/
+func BackfillRecord(client dynamodbiface.DynamoDBAPI, tableName string, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	productName, haveProductName := stringAttribute(item, "productName")
+	branch, haveBranch := stringAttribute(item, "branch")
+	if !haveProductName || !haveBranch {
+		log.Printf("Record for build %s is missing productName or branch, skipping", buildIdOf(item))
+		return false, nil
+	}
+
+	req := dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"productName": item["productName"],
+			"buildId":     item["buildId"],
+		},
+		UpdateExpression:    aws.String("SET productBranch=:productBranchSubst"),
+		ConditionExpression: aws.String("attribute_exists(productName)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":productBranchSubst": {S: aws.String(ProductBranchKey(productName, branch))},
+		},
+	}
+
+	_, err := client.UpdateItem(&req)
+	return err == nil, err
+}
+
+/**
+scan the whole table for records without a productBranch attribute and fill it in
+returns the number of records found and the number that were updated. Records without a productName or branch are
+skipped, so they are found but not updated.
+*/
+func Backfill(client dynamodbiface.DynamoDBAPI, tableName string, dryRun bool) (int, int, error) {
+	found := 0
+	updated := 0
+	var updateErr error
+
+	req := dynamodb.ScanInput{
+		TableName:            aws.String(tableName),
+		ProjectionExpression: aws.String("productName, buildId, branch"),
+		FilterExpression:     aws.String("attribute_not_exists(productBranch)"),
+	}
+
+	scanErr := client.ScanPages(&req, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			found += 1
+			productName, _ := stringAttribute(item, "productName")
+			if dryRun {
+				branch, _ := stringAttribute(item, "branch")
+				log.Printf("Would update %s build %s on branch %s", productName, buildIdOf(item), branch)
+				continue
+			}
+			var didUpdate bool
+			didUpdate, updateErr = BackfillRecord(client, tableName, item)
+			if updateErr != nil {
+				log.Printf("Could not update %s build %s: %s", productName, buildIdOf(item), updateErr)
+				return false
+			}
+			if didUpdate {
+				updated += 1
+			}
+		}
+		return true
+	})
+
+	if scanErr != nil {
+		return found, updated, scanErr
+	}
+	return found, updated, updateErr
+}
+
+func main() {
+	var tableName = flag.String("table", "", "Name of the Dynamo data table to backfill")
+	var dryRun = flag.Bool("dry-run", false, "Only list the records that would be updated")
+	flag.Parse()
+
+	if *tableName == "" {
+		println("You must specify a table name in the --table argument")
+		os.Exit(1)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	client := dynamodb.New(sess)
+
+	found, updated, err := Backfill(client, *tableName, *dryRun)
+	if err != nil {
+		log.Fatalf("Backfill stopped after updating %d of %d records: %s", updated, found, err)
+	}
+	log.Printf("Found %d records without a branch index key, updated %d", found, updated)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"testing"
+)
+
+type MockedDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	items   []map[string]*dynamodb.AttributeValue
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (m *MockedDynamo) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	fn(&dynamodb.ScanOutput{Items: m.items}, true)
+	return nil
+}
+
+func (m *MockedDynamo) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.updates = append(m.updates, input)
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func TestBackfill(t *testing.T) {
+	client := &MockedDynamo{items: []map[string]*dynamodb.AttributeValue{
+		{"productName": {S: aws.String("test product")}, "buildId": {N: aws.String("12")}, "branch": {S: aws.String("master")}},
+		//a legacy record that was logged without a branch
+		{"productName": {S: aws.String("test product")}, "buildId": {N: aws.String("11")}},
+		//and one that has lost everything but its key
+		{"buildId": {N: aws.String("10")}},
+		{},
+	}}
+
+	found, updated, err := Backfill(client, "testtable", false)
+	if err != nil {
+		t.Fatalf("backfill should have succeeded but got %s", err)
+	}
+	if found != 4 || updated != 1 {
+		t.Errorf("backfill should have found 4 records and updated 1 but found %d and updated %d", found, updated)
+	}
+	if len(client.updates) != 1 || *client.updates[0].ExpressionAttributeValues[":productBranchSubst"].S != "test product#master" {
+		t.Errorf("backfill should only have updated build 12 but made %v", client.updates)
+	}
+
+	dryRunFound, _, dryRunErr := Backfill(client, "testtable", true)
+	if dryRunErr != nil || dryRunFound != 4 {
+		t.Errorf("dry run should have listed all 4 records but got %d, %s", dryRunFound, dryRunErr)
+	}
+	if len(client.updates) != 1 {
+		t.Errorf("dry run should not have updated anything")
+	}
+}
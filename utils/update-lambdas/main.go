@@ -12,10 +12,37 @@ import (
 	"regexp"
 )
 
-var FunctionSourceMapping = map[string]string{"ReceiveVersion": "receive-version.zip", "LookupVersion": "lookup-version.zip"}
+var FunctionSourceMapping = map[string]string{
+	"ReceiveVersion":  "receive-version.zip",
+	"LookupVersion":   "lookup-version.zip",
+	"ListReleases":    "list-releases.zip",
+	"BranchSettings":  "branch-settings.zip",
+	"WithdrawRelease": "withdraw-release.zip",
+	"SetRollout":      "set-rollout.zip",
+	"ManageChannels":  "manage-channels.zip",
+	"PromoteRelease":  "promote-release.zip",
+	"ManageProducts":  "manage-products.zip",
+	"Appcast":         "appcast.zip",
+	"ElectronUpdates": "electron-updates.zip",
+	"ReleaseFeed":     "release-feed.zip",
+	"Download":        "download.zip",
+	"DownloadStats":   "download-stats.zip",
+}
 var FunctionNameRegexMapping = map[string]*regexp.Regexp{
-	"ReceiveVersion": regexp.MustCompile("ReceiveVersion"),
-	"LookupVersion":  regexp.MustCompile("LookupVersion"),
+	"ReceiveVersion":  regexp.MustCompile("ReceiveVersion"),
+	"LookupVersion":   regexp.MustCompile("LookupVersion"),
+	"ListReleases":    regexp.MustCompile("ListReleases"),
+	"BranchSettings":  regexp.MustCompile("BranchSettings"),
+	"WithdrawRelease": regexp.MustCompile("WithdrawRelease"),
+	"SetRollout":      regexp.MustCompile("SetRollout"),
+	"ManageChannels":  regexp.MustCompile("ManageChannels"),
+	"PromoteRelease":  regexp.MustCompile("PromoteRelease"),
+	"ManageProducts":  regexp.MustCompile("ManageProducts"),
+	"Appcast":         regexp.MustCompile("Appcast"),
+	"ElectronUpdates": regexp.MustCompile("ElectronUpdates"),
+	"ReleaseFeed":     regexp.MustCompile("ReleaseFeed"),
+	"Download":        regexp.MustCompile("-Download-"), //the stack name may well contain Download too
+	"DownloadStats":   regexp.MustCompile("DownloadStats"),
 }
 
 func LinkupLambdaTargets(actualLambdaFuncs []*string) map[string]string {